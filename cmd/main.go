@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
@@ -8,7 +11,6 @@ import (
 	"github.com/RakibulBh/AI-pr-reviewer/internal/config"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
@@ -23,54 +25,89 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	env := os.Getenv("ENV")
-
-	// Extract the stored private key
-	var keyData []byte
-	var err error
-	if env == "production" {
-		keyDataStr := os.Getenv("GITHUB_BOT_PRIVATE_KEY")
-		if keyDataStr == "" {
-			slog.Error("GITHUB_BOT_PRIVATE_KEY environment variable is not set")
-			os.Exit(1)
-		}
-		keyData = []byte(keyDataStr)
-	} else {
-		keyData, err = os.ReadFile("docs/credentials/bibi-the-monkey-code-reviewer.2025-08-29.private-key.pem")
-		if err != nil {
-			slog.Error("failed to read private key", "err", err)
-			os.Exit(1)
-		}
+	// Refuse to start with a broken config, every problem is reported at once
+	appConfig, err := config.LoadConfig(os.Args[1:], os.LookupEnv)
+	if appConfig != nil && appConfig.PrintConfig {
+		appConfig.Print(os.Stdout)
 	}
-
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyData)
 	if err != nil {
-		slog.Error("failed to parse private key", "err", err)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-
-	appIDStr := os.Getenv("APP_ID")
-	if appIDStr == "" {
-		slog.Error("APP_ID environment variable is not set")
-	}
-
-	appID, err := strconv.Atoi(appIDStr)
-	if err != nil {
-		slog.Error("Invalid APP_ID", "APP_ID", appID)
+	if appConfig.PrintConfig {
+		return
 	}
+	slog.Info("configuration loaded", "config", appConfig)
 
 	config.Bootstrap(&config.BootstrapConfig{
 		R:            r,
-		GeminiApiKey: os.Getenv("GEMINI_API_KEY"),
-		Port:         os.Getenv("PORT"),
-		Env:          env,
+		GeminiApiKey: appConfig.LLM.GeminiApiKey,
+		Port:         strconv.Itoa(appConfig.Port),
+		Env:          appConfig.Env,
+
+		// LLM provider
+		LLMProvider:        appConfig.LLM.Provider,
+		LLMModel:           appConfig.LLM.Model,
+		OllamaURL:          appConfig.LLM.OllamaURL,
+		LLMContextCacheTTL: appConfig.LLM.ContextCacheTTL,
+
+		// Costs and budgets
+		Budget:           appConfig.Budget,
+		LLMPrices:        appConfig.Prices,
+		LLMFallbackModel: appConfig.LLM.FallbackModel,
 
 		// Github Repostored private key
-		GithubWebhookSecret: os.Getenv("GITHUB_REPO_WEBHOOK_SECRET"),
+		GithubWebhookSecret: appConfig.WebhookSecret,
+		GithubAPIURL:        appConfig.GithubAPIURL,
 
 		// Github Bot
-		GithubBotPrivateKey: privateKey,
-		AppID:               int64(appID),
-	})
+		GithubBotPrivateKey: appConfig.PrivateKey,
+		AppID:               appConfig.AppID,
 
+		// Secret rotation
+		SecretsReloadInterval: appConfig.SecretsReloadInterval,
+
+		// Secret redaction
+		Redactor: appConfig.Redactor,
+
+		// Checks on generated comments
+		OutputPolicy: appConfig.OutputPolicy,
+
+		// Prompt templates
+		PromptVersion: appConfig.PromptVersion,
+
+		// Review cache
+		ReviewCacheTTL: appConfig.ReviewCacheTTL,
+		ReviewCacheDir: appConfig.ReviewCacheDir,
+
+		// Dry-run mode
+		DryRun: appConfig.DryRun,
+
+		// Review history storage
+		StorageDriver: appConfig.StorageDriver,
+		StorageDSN:    appConfig.StorageDSN,
+
+		// Developer feedback
+		FeedbackPollInterval: appConfig.FeedbackPollInterval,
+
+		// Installations
+		WelcomeNewRepositories: appConfig.WelcomeNewRepositories,
+
+		// Background reviews
+		ReviewWorkers:   appConfig.ReviewWorkers,
+		ReviewQueueSize: appConfig.ReviewQueueSize,
+		ReviewDelays:    appConfig.ReviewDelays,
+
+		// HTTPS and the admin API
+		TLSCertFile:    appConfig.TLSCertFile,
+		TLSKeyFile:     appConfig.TLSKeyFile,
+		AdminToken:     appConfig.AdminToken,
+		AdminClientCAs: appConfig.AdminClientCAs,
+
+		// Tracing
+		TracesExporter: appConfig.TracesExporter,
+	})
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/config"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/report"
+)
+
+// Where the labelled pull requests live, relative to the root of this repository
+const DEFAULT_EVAL_FIXTURES_DIR = "eval/fixtures"
+
+func runEval(args []string) error {
+	if len(args) > 0 && args[0] == "compare" {
+		return runEvalCompare(args[1:])
+	}
+
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage:
+  reviewer eval [flags]                                 score the review pipeline on the fixtures
+  reviewer eval -format json -output run.json [flags]   save the run to compare it later
+  reviewer eval compare [flags] BASE.json CANDIDATE.json
+
+Each fixture is a directory with the diff of a pull request (`+utils.EVAL_DIFF_FILE+`) and the findings
+a good review raises (`+utils.EVAL_EXPECTED_FILE+`). Comments on the same file within -line-tolerance lines
+of a finding count as finding it.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	fixturesDir := flags.String("fixtures", DEFAULT_EVAL_FIXTURES_DIR, "directory of fixtures")
+	rulesDir := flags.String("rules-dir", filepath.Join("docs", "repository_rules"), "directory containing main.md and other rule files")
+	provider := flags.String("provider", defaultProvider(), "LLM provider, gemini, ollama or fake")
+	llmModel := flags.String("model", os.Getenv("LLM_MODEL"), "model name, defaults to the provider's default model")
+	ollamaURL := flags.String("ollama-url", envOrDefault("OLLAMA_URL", repository.DEFAULT_OLLAMA_URL), "address of the local ollama server")
+	promptVersion := flags.String("prompt-version", utils.DEFAULT_PROMPT_VERSION, "version of the bundled prompt templates: "+strings.Join(utils.PromptVersions(), ", "))
+	promptsDir := flags.String("prompts-dir", "", "directory of prompt templates and config.yml overriding the bundled ones")
+	profilesFile := flags.String("profiles", "", "language profiles adding to the bundled ones")
+	label := flags.String("label", "", "name of this configuration in reports, like baseline or new-prompt")
+	lineTolerance := flags.Int("line-tolerance", usecase.DEFAULT_EVAL_LINE_TOLERANCE, "how many lines a comment may be from an expected finding and still match it")
+	baseline := flags.String("baseline", "", "JSON run to compare this run with, the comparison is written after the report")
+	format := flags.String("format", report.FORMAT_TEXT, "output format: "+strings.Join(report.EvalFormats, ", "))
+	output := flags.String("output", "", "write the report to a file instead of stdout")
+	flags.Parse(args)
+
+	if *lineTolerance < 0 {
+		return fmt.Errorf("-line-tolerance can't be negative")
+	}
+	utils.RepositoryRulesDir = *rulesDir
+	prompt, err := loadPrompt(*promptVersion, *promptsDir)
+	if err != nil {
+		return err
+	}
+	profiles, err := usecase.ReadLocalLanguageProfiles(*profilesFile)
+	if err != nil {
+		return err
+	}
+
+	fixtures, err := utils.LoadEvalFixtures(*fixturesDir)
+	if err != nil {
+		return err
+	}
+
+	var base *model.EvalRun
+	if *baseline != "" {
+		base, err = readEvalRun(*baseline)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	llm, err := config.NewLLMRepository(ctx, config.LLMConfig{
+		Provider:     *provider,
+		Model:        *llmModel,
+		GeminiApiKey: os.Getenv("GEMINI_API_KEY"),
+		OllamaURL:    *ollamaURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The whole local pipeline is evaluated, redaction and output checks included. Nothing is cached,
+	// every run measures what the model generates now.
+	review := usecase.NewLocalReviewUsecase(nil, llm, utils.NewRedactor(nil), usecase.NewOutputPolicy(usecase.DEFAULT_ALLOWED_LINK_HOSTS, nil), prompt, profiles, nil)
+	evalUsecase := usecase.NewEvalUsecase(review, model.EvalConfig{
+		Label:         *label,
+		Provider:      *provider,
+		Model:         llm.Model(),
+		PromptVersion: review.PromptVersion(),
+		LineTolerance: *lineTolerance,
+	})
+
+	run, err := evalUsecase.Run(ctx, fixtures)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	if err := report.RenderEval(out, *format, run); err != nil {
+		return err
+	}
+	if base == nil {
+		return nil
+	}
+
+	// A JSON report stays a single document, the comparison goes to stderr
+	comparisonOut := out
+	if *format == report.FORMAT_JSON {
+		comparisonOut = os.Stderr
+	} else {
+		fmt.Fprintln(out)
+	}
+	comparisonFormat := *format
+	if comparisonFormat == report.FORMAT_JSON {
+		comparisonFormat = report.FORMAT_TEXT
+	}
+	return report.RenderEvalComparison(comparisonOut, comparisonFormat, usecase.CompareEvalRuns(*base, run))
+}
+
+func runEvalCompare(args []string) error {
+	flags := flag.NewFlagSet("eval compare", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage:
+  reviewer eval compare [flags] BASE.json CANDIDATE.json
+
+Compares two runs saved with reviewer eval -format json.
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	format := flags.String("format", report.FORMAT_TEXT, "output format: "+strings.Join(report.EvalFormats, ", "))
+	output := flags.String("output", "", "write the comparison to a file instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected the base and candidate runs, got %d argument(s)", flags.NArg())
+	}
+
+	base, err := readEvalRun(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	candidate, err := readEvalRun(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := openOutput(*output)
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	return report.RenderEvalComparison(out, *format, usecase.CompareEvalRuns(*base, *candidate))
+}
+
+func readEvalRun(path string) (*model.EvalRun, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read evaluation run: %w", err)
+	}
+
+	var run model.EvalRun
+	if err := json.Unmarshal(content, &run); err != nil {
+		return nil, fmt.Errorf("invalid evaluation run %s, save runs with -format json: %w", path, err)
+	}
+	return &run, nil
+}
+
+// openOutput returns stdout, or the file when path is set
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return file, func() { file.Close() }, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `reviewer reviews code changes locally, using the same prompts and LLM providers as the github bot.
+
+Usage:
+  reviewer review [flags] [git diff arguments]
+  reviewer eval [flags]
+  reviewer secrets <keygen|encrypt> [flags]
+
+Commands:
+  review    Review the working tree, a range such as main...HEAD, or a patch file
+  eval      Score the reviews of labelled pull requests, and compare runs of different models or prompts
+  secrets   Generate a key and encrypt secrets for the server's encrypted: secret references
+
+Run "reviewer <command> -h" for the flags of a command.
+`
+
+func main() {
+	godotenv.Load()
+
+	// Keep the terminal for review output, only warnings and errors are logged
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "review":
+		err = runReview(os.Args[2:])
+	case "eval":
+		err = runEval(os.Args[2:])
+	case "secrets":
+		err = runSecrets(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
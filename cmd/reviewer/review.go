@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/config"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/report"
+)
+
+func runReview(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), `Usage:
+  reviewer review [flags]                 review uncommitted changes (git diff)
+  reviewer review [flags] main...HEAD     review a range, any git diff arguments are accepted
+  reviewer review [flags] --staged        review staged changes
+  reviewer review [flags] --patch FILE    review a patch file, use - to read from stdin
+
+Flags:
+`)
+		flags.PrintDefaults()
+	}
+
+	patchFile := flags.String("patch", "", "review a patch file instead of running git diff, - reads from stdin")
+	staged := flags.Bool("staged", false, "review staged changes")
+	dir := flags.String("dir", ".", "git checkout to review")
+	rulesDir := flags.String("rules-dir", "", "directory containing main.md and other rule files (default <dir>/docs/repository_rules)")
+	provider := flags.String("provider", defaultProvider(), "LLM provider, gemini, ollama or fake")
+	llmModel := flags.String("model", os.Getenv("LLM_MODEL"), "model name, defaults to the provider's default model")
+	ollamaURL := flags.String("ollama-url", envOrDefault("OLLAMA_URL", repository.DEFAULT_OLLAMA_URL), "address of the local ollama server")
+	promptVersion := flags.String("prompt-version", utils.DEFAULT_PROMPT_VERSION, "version of the bundled prompt templates: "+strings.Join(utils.PromptVersions(), ", "))
+	promptsDir := flags.String("prompts-dir", "", "directory of prompt templates and config.yml overriding the bundled ones (default <dir>/"+utils.REPOSITORY_PROMPTS_DIR+")")
+	profilesFile := flags.String("profiles", "", "language profiles adding to the bundled ones (default <dir>/"+utils.REPOSITORY_LANGUAGE_PROFILES_FILE+")")
+	cacheDir := flags.String("cache-dir", os.Getenv("REVIEW_CACHE_DIR"), "directory to cache reviews in, chunks reviewed before aren't sent to the LLM again")
+	cacheTTL := flags.Duration("cache-ttl", usecase.DEFAULT_REVIEW_CACHE_TTL, "how long cached reviews are reused")
+	secretPatterns := flags.String("secret-patterns", os.Getenv("REDACTION_PATTERNS_FILE"), "file of extra regular expressions, one per line, matching secrets to mask before the diff is sent to the LLM")
+	format := flags.String("format", report.FORMAT_TEXT, "output format: "+strings.Join(report.Formats, ", "))
+	output := flags.String("output", "", "write the report to a file instead of stdout")
+	flags.Parse(args)
+
+	if *rulesDir == "" {
+		*rulesDir = filepath.Join(*dir, "docs", "repository_rules")
+	}
+	utils.RepositoryRulesDir = *rulesDir
+	if *promptsDir == "" {
+		*promptsDir = filepath.Join(*dir, filepath.FromSlash(utils.REPOSITORY_PROMPTS_DIR))
+	}
+	prompt, err := loadPrompt(*promptVersion, *promptsDir)
+	if err != nil {
+		return err
+	}
+	if *profilesFile == "" {
+		*profilesFile = filepath.Join(*dir, filepath.FromSlash(utils.REPOSITORY_LANGUAGE_PROFILES_FILE))
+	}
+	profiles, err := usecase.ReadLocalLanguageProfiles(*profilesFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	llm, err := config.NewLLMRepository(ctx, config.LLMConfig{
+		Provider:     *provider,
+		Model:        *llmModel,
+		GeminiApiKey: os.Getenv("GEMINI_API_KEY"),
+		OllamaURL:    *ollamaURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	var extraRules []utils.SecretRule
+	if *secretPatterns != "" {
+		extraRules, err = utils.LoadSecretRules(*secretPatterns)
+		if err != nil {
+			return err
+		}
+	}
+
+	var reviewCache *usecase.ReviewCache
+	if *cacheDir != "" && *cacheTTL > 0 {
+		diskCache, err := repository.NewDiskReviewCacheRepository(*cacheDir)
+		if err != nil {
+			return err
+		}
+		reviewCache = usecase.NewReviewCache(repository.NewMemoryReviewCacheRepository(repository.DEFAULT_REVIEW_CACHE_ENTRIES), diskCache, *cacheTTL)
+	}
+
+	localReviewUsecase := usecase.NewLocalReviewUsecase(repository.NewGitRepository(*dir), llm, utils.NewRedactor(extraRules), usecase.NewOutputPolicy(usecase.DEFAULT_ALLOWED_LINK_HOSTS, nil), prompt, profiles, reviewCache)
+
+	var reviews []model.ReviewCommentRequest
+	switch {
+	case *patchFile != "":
+		diff, err := readPatch(*patchFile)
+		if err != nil {
+			return err
+		}
+		reviews, err = localReviewUsecase.ReviewDiff(ctx, diff)
+		if err != nil {
+			return err
+		}
+	default:
+		diffArgs := flags.Args()
+		if *staged {
+			diffArgs = append([]string{"--staged"}, diffArgs...)
+		}
+		reviews, err = localReviewUsecase.ReviewGitDiff(ctx, diffArgs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return report.Render(out, *format, reviews, report.Metadata{})
+}
+
+func readPatch(path string) (string, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read patch from stdin: %w", err)
+		}
+		return string(content), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch file %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// loadPrompt checks the prompt version, and reads the overrides in dir when it exists
+func loadPrompt(version string, dir string) (model.PromptContext, error) {
+	overrides, err := usecase.ReadLocalPromptOverrides(dir)
+	if err != nil {
+		return model.PromptContext{}, err
+	}
+	if _, err := utils.LoadPromptTemplates(version, overrides); err != nil {
+		return model.PromptContext{}, err
+	}
+
+	return model.PromptContext{PromptVersion: version, PromptOverrides: overrides}, nil
+}
+
+// defaultProvider prefers an explicit LLM_PROVIDER, then gemini when a key is available, and a local model otherwise
+func defaultProvider() string {
+	if provider := os.Getenv("LLM_PROVIDER"); provider != "" {
+		return provider
+	}
+	if os.Getenv("GEMINI_API_KEY") != "" {
+		return config.GEMINI_PROVIDER
+	}
+	return config.OLLAMA_PROVIDER
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+)
+
+const secretsUsage = `Usage:
+  reviewer secrets keygen > secrets.key                                   generate an encryption key
+  reviewer secrets encrypt --key-file secrets.key < key.pem > key.pem.enc  encrypt a secret read from stdin
+
+The server reads encrypted secrets from references like encrypted:/path/key.pem.enc, with the key in SECRETS_KEY_FILE.
+`
+
+func runSecrets(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, secretsUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "keygen":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, secrets.EncodeKey(key))
+		return nil
+	case "encrypt":
+		return runEncryptSecret(args[1:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, secretsUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown secrets command %q\n\n%s", args[0], secretsUsage)
+		os.Exit(2)
+		return nil
+	}
+}
+
+func runEncryptSecret(args []string) error {
+	flags := flag.NewFlagSet("secrets encrypt", flag.ExitOnError)
+	keyFile := flags.String("key-file", os.Getenv("SECRETS_KEY_FILE"), "file containing the encryption key")
+	flags.Parse(args)
+
+	if *keyFile == "" {
+		return fmt.Errorf("--key-file is required")
+	}
+	key, err := secrets.LoadKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	secret, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read secret from stdin: %w", err)
+	}
+
+	encrypted, err := secrets.Encrypt(secret, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(encrypted)
+	return err
+}
@@ -2,15 +2,20 @@ package config
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
 	httpPackage "github.com/RakibulBh/AI-pr-reviewer/internal/delivery/http"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/delivery/http/route"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -20,48 +25,264 @@ type BootstrapConfig struct {
 	GeminiApiKey string
 	Env          string
 
+	// LLM provider, gemini by default or ollama for a local model
+	LLMProvider string
+	LLMModel    string
+	OllamaURL   string
+	// How long review contexts are cached on the provider, 0 disables caching
+	LLMContextCacheTTL time.Duration
+
+	// Monthly budgets, the price table calls are costed with, and the smaller model used once a budget is exceeded
+	Budget           *usecase.BudgetPolicy
+	LLMPrices        usecase.PriceTable
+	LLMFallbackModel string
+
 	// Github Repo
-	GithubWebhookSecret string
+	GithubWebhookSecret *secrets.Secret
+	// URL of the github REST API, api.github.com when empty
+	GithubAPIURL string
 
 	// Github Bot
-	GithubBotPrivateKey *rsa.PrivateKey
+	GithubBotPrivateKey *secrets.Secret
 	AppID               int64
+
+	// How often the secrets above are re-read to pick up rotations, 0 disables reloading
+	SecretsReloadInterval time.Duration
+
+	// Masks secrets in code before it is sent to the LLM, nil disables redaction
+	Redactor *utils.Redactor
+
+	// Links and mentions allowed in what the LLM generates
+	OutputPolicy *usecase.OutputPolicy
+
+	// Version of the bundled prompt templates, the default version when empty
+	PromptVersion string
+
+	// How long reviews of chunks are reused, 0 disables the cache, and the directory they are also kept in
+	ReviewCacheTTL time.Duration
+	ReviewCacheDir string
+
+	// Dry-run (shadow) mode, reviews run but nothing is posted to github
+	DryRun *usecase.DryRunPolicy
+
+	// Review history storage, sqlite by default
+	StorageDriver string
+	StorageDSN    string
+
+	// How often reactions on posted comments are synced, 0 disables polling
+	FeedbackPollInterval time.Duration
+
+	// Open a welcome issue on repositories the app is added to, except on installations on all repositories
+	WelcomeNewRepositories bool
+
+	// Background reviews, 0 uses the defaults
+	ReviewWorkers   int
+	ReviewQueueSize int
+	// Pauses between github writes during a review
+	ReviewDelays usecase.ReviewDelays
+
+	// HTTPS is served when the certificate is set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Admin API credentials, the API is disabled when both are nil
+	AdminToken     *secrets.Secret
+	AdminClientCAs *x509.CertPool
+
+	// Where traces are exported, otlp, stdout or none
+	TracesExporter string
 }
 
-func Bootstrap(appConfig *BootstrapConfig) {
-	// configs
-	slog.Info("Github Hook Listener has been succsessfully connected")
-	client, err := NewGeminiClient(context.Background(), appConfig.GeminiApiKey)
-	if err != nil {
-		slog.Error("error creating gemini client", "err", err)
+// App is the wired server, Bootstrap serves it and the end to end tests drive it in-process
+type App struct {
+	// Reviews run in the background, tests wait for the queue to empty
+	ReviewQueue *usecase.ReviewQueue
 
+	stop    context.CancelFunc
+	closers []func() error
+}
+
+// Close stops the background work of the app and closes its storage
+func (a *App) Close() error {
+	a.stop()
+
+	var firstErr error
+	for _, closer := range a.closers {
+		if err := closer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	slog.Info("LLM client has been created and connected")
+	return firstErr
+}
 
-	// Setup logger
-	SetupLogger(appConfig.Env)
+// NewApp creates the repositories, usecases and controllers, and registers the routes on appConfig.R.
+// Background work such as the review workers runs until the app is closed.
+func NewApp(appConfig *BootstrapConfig) (*App, error) {
+	ctx, stop := context.WithCancel(context.Background())
+	app := &App{stop: stop}
+
+	llmRepository, err := NewLLMRepository(ctx, LLMConfig{
+		Provider:        appConfig.LLMProvider,
+		Model:           appConfig.LLMModel,
+		GeminiApiKey:    appConfig.GeminiApiKey,
+		OllamaURL:       appConfig.OllamaURL,
+		ContextCacheTTL: appConfig.LLMContextCacheTTL,
+	})
+	if err != nil {
+		app.Close()
+		return nil, fmt.Errorf("error creating LLM client: %w", err)
+	}
+	llmRepository = usecase.NewInstrumentedLLM(llmRepository)
+	slog.Info("LLM client has been created")
 
 	// setup repositories
-	githubRepository := repository.NewGithubRepository(appConfig.GithubWebhookSecret, appConfig.GithubBotPrivateKey)
-	geminiRepository := repository.NewGeminiRepository(client, context.Background())
+	githubRepository, err := repository.NewGithubAPIRepository(appConfig.GithubWebhookSecret, appConfig.GithubBotPrivateKey, appConfig.GithubAPIURL)
+	if err != nil {
+		app.Close()
+		return nil, err
+	}
+	reviewRepository, err := NewReviewRepository(ctx, appConfig.StorageDriver, appConfig.StorageDSN)
+	if err != nil {
+		app.Close()
+		return nil, fmt.Errorf("error opening review history storage: %w", err)
+	}
+	app.closers = append(app.closers, reviewRepository.Close)
 
 	// setup use cases
-	githubUsecase := usecase.NewGithubUsecase(githubRepository, geminiRepository, appConfig.AppID, appConfig.GithubBotPrivateKey)
+	var fallbackLLM usecase.LLMRepository
+	if appConfig.LLMFallbackModel != "" {
+		fallbackLLM, err = NewLLMRepository(ctx, LLMConfig{
+			Provider:        appConfig.LLMProvider,
+			Model:           appConfig.LLMFallbackModel,
+			GeminiApiKey:    appConfig.GeminiApiKey,
+			OllamaURL:       appConfig.OllamaURL,
+			ContextCacheTTL: appConfig.LLMContextCacheTTL,
+		})
+		if err != nil {
+			slog.Error("error creating fallback LLM client, reviews over budget will be skipped", "err", err)
+		} else {
+			fallbackLLM = usecase.NewInstrumentedLLM(fallbackLLM)
+		}
+	}
+	// Identical chunks reviewed again reuse the comments generated the first time
+	var reviewCache *usecase.ReviewCache
+	if appConfig.ReviewCacheTTL > 0 {
+		var diskCache repository.ReviewCacheRepository
+		if appConfig.ReviewCacheDir != "" {
+			diskCache, err = repository.NewDiskReviewCacheRepository(appConfig.ReviewCacheDir)
+			if err != nil {
+				app.Close()
+				return nil, err
+			}
+		}
+		reviewCache = usecase.NewReviewCache(repository.NewMemoryReviewCacheRepository(repository.DEFAULT_REVIEW_CACHE_ENTRIES), diskCache, appConfig.ReviewCacheTTL)
+	}
+
+	budgetUsecase := usecase.NewBudgetUsecase(reviewRepository, appConfig.LLMPrices, appConfig.Budget, fallbackLLM)
+	githubUsecase := usecase.NewGithubUsecase(usecase.GithubUsecaseDependencies{
+		Github:        githubRepository,
+		LLM:           llmRepository,
+		Reviews:       reviewRepository,
+		Feedback:      reviewRepository,
+		Installations: reviewRepository,
+		Budget:        budgetUsecase,
+		Redactor:      appConfig.Redactor,
+		OutputPolicy:  appConfig.OutputPolicy,
+		PromptVersion: appConfig.PromptVersion,
+		ReviewCache:   reviewCache,
+		DryRun:        appConfig.DryRun,
+		Delays:        appConfig.ReviewDelays,
+		AppID:         appConfig.AppID,
+		PrivateKey:    appConfig.GithubBotPrivateKey,
+	})
+
+	// Pick up rotated secrets without a restart
+	if appConfig.SecretsReloadInterval > 0 {
+		go appConfig.GithubWebhookSecret.Watch(ctx, "github.webhook_secret", appConfig.SecretsReloadInterval)
+		go appConfig.GithubBotPrivateKey.Watch(ctx, "github.private_key", appConfig.SecretsReloadInterval)
+		if appConfig.AdminToken != nil {
+			go appConfig.AdminToken.Watch(ctx, "admin.token", appConfig.SecretsReloadInterval)
+		}
+	}
+
+	// Reactions don't trigger webhooks, poll them in the background
+	if appConfig.FeedbackPollInterval > 0 {
+		go githubUsecase.PollFeedback(ctx, appConfig.FeedbackPollInterval)
+	}
+
+	reviewHistoryUsecase := usecase.NewReviewHistoryUsecase(reviewRepository, reviewRepository)
+
+	// Reviews run in the background on a bounded number of workers
+	reviewQueue := usecase.NewReviewQueue(appConfig.ReviewWorkers, appConfig.ReviewQueueSize, usecase.DEFAULT_REVIEW_TIMEOUT)
+	reviewQueue.Start(ctx)
+	app.ReviewQueue = reviewQueue
+
+	readinessUsecase := usecase.NewReadinessUsecase(llmRepository, githubUsecase, reviewRepository, reviewQueue)
+	installationUsecase := usecase.NewInstallationUsecase(githubUsecase, reviewRepository, appConfig.DryRun, appConfig.WelcomeNewRepositories)
+	adminUsecase := usecase.NewAdminUsecase(githubUsecase, installationUsecase, budgetUsecase, reviewQueue, appConfig.DryRun)
+
+	// Catch up with installations which changed while the server was down
+	go func() {
+		err := installationUsecase.Sync(ctx)
+		if err != nil {
+			slog.Warn("error syncing installations from github", "err", err)
+		}
+	}()
 
 	// setup controller
-	githubController := httpPackage.NewGithubController(githubUsecase, appConfig.GithubWebhookSecret)
-	healthController := httpPackage.NewHealthController()
+	githubController := httpPackage.NewGithubController(githubUsecase, installationUsecase, reviewQueue, appConfig.GithubWebhookSecret)
+	healthController := httpPackage.NewHealthController(readinessUsecase)
+	reportController := httpPackage.NewReportController()
+	shadowController := httpPackage.NewShadowController(reviewHistoryUsecase)
+	feedbackController := httpPackage.NewFeedbackController(reviewHistoryUsecase)
+	adminController := httpPackage.NewAdminController(reviewHistoryUsecase, adminUsecase)
 
 	// setup middleware
 	routeConfig := route.RouteConfig{
-		R:                appConfig.R,
-		GithubController: githubController,
-		HealthController: healthController,
+		R:                  appConfig.R,
+		GithubController:   githubController,
+		HealthController:   healthController,
+		ReportController:   reportController,
+		ShadowController:   shadowController,
+		FeedbackController: feedbackController,
+		AdminController:    adminController,
+	}
+	if appConfig.AdminToken != nil || appConfig.AdminClientCAs != nil {
+		routeConfig.AdminAuth = httpPackage.AdminAuth(appConfig.AdminToken)
+	} else {
+		slog.Info("admin API is disabled, set an admin token or client CA to enable it")
 	}
 
-	// Setup routes and start server
+	// Setup routes
 	routeConfig.Setup()
 
+	return app, nil
+}
+
+func Bootstrap(appConfig *BootstrapConfig) {
+	// Setup logger
+	SetupLogger(appConfig.Env)
+
+	// Setup tracing
+	shutdownTracing, err := SetupTracing(context.Background(), appConfig.TracesExporter)
+	if err != nil {
+		slog.Error("error setting up tracing", "err", err)
+		os.Exit(1)
+	}
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			slog.Warn("error flushing traces", "err", err)
+		}
+	}()
+
+	app, err := NewApp(appConfig)
+	if err != nil {
+		slog.Error("error setting up the app", "err", err)
+		os.Exit(1)
+	}
+	defer app.Close()
+
 	// Configure server with appropriate timeouts
 	server := &http.Server{
 		Addr:         ":" + appConfig.Port,
@@ -71,7 +292,18 @@ func Bootstrap(appConfig *BootstrapConfig) {
 		IdleTimeout:  120 * time.Second, // Time to keep connections alive
 	}
 
-	err = server.ListenAndServe()
+	if appConfig.TLSCertFile != "" {
+		// Client certificates are optional for the webhook, AdminAuth checks them for the admin API.
+		// Without admin CAs they are never requested, otherwise certificates from the system roots would pass.
+		server.TLSConfig = &tls.Config{}
+		if appConfig.AdminClientCAs != nil {
+			server.TLSConfig.ClientCAs = appConfig.AdminClientCAs
+			server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		err = server.ListenAndServeTLS(appConfig.TLSCertFile, appConfig.TLSKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
 	if err != nil {
 		slog.Warn("major error starting server", "error", err)
 		return
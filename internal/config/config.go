@@ -0,0 +1,666 @@
+package config
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const DEVELOPMENT_ENV = "development"
+const PRODUCTION_ENV = "production"
+
+// Where the value of a setting came from, later sources override earlier ones
+const (
+	SOURCE_DEFAULT = "default"
+	SOURCE_FILE    = "file"
+	SOURCE_ENV     = "env"
+	SOURCE_FLAG    = "flag"
+)
+
+// Shown instead of the value of secret settings
+const REDACTED = "[REDACTED]"
+
+// Environment variable pointing at the config file, the --config flag takes precedence
+const CONFIG_FILE_ENV = "CONFIG_FILE"
+
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+// setting describes a single config value and the names it goes by in each source
+type setting struct {
+	// key in the config file, nested keys are joined with dots
+	key          string
+	env          string
+	flag         string
+	defaultValue string
+	usage        string
+	secret       bool
+}
+
+var settings = []setting{
+	{key: "env", env: "ENV", flag: "env", defaultValue: DEVELOPMENT_ENV, usage: "development or production"},
+	{key: "port", env: "PORT", flag: "port", defaultValue: "8080", usage: "port the server listens on"},
+	{key: "github.app_id", env: "APP_ID", flag: "github-app-id", usage: "ID of the github app"},
+	{key: "github.private_key", env: "GITHUB_BOT_PRIVATE_KEY", flag: "github-private-key", usage: "PEM encoded private key of the github app", secret: true},
+	{key: "github.private_key_file", env: "GITHUB_BOT_PRIVATE_KEY_FILE", flag: "github-private-key-file", usage: "file containing the PEM encoded private key of the github app"},
+	{key: "github.webhook_secret", env: "GITHUB_REPO_WEBHOOK_SECRET", flag: "github-webhook-secret", usage: "secret github signs webhooks with", secret: true},
+	{key: "github.api_url", env: "GITHUB_API_URL", flag: "github-api-url", defaultValue: repository.DEFAULT_GITHUB_API_URL, usage: "URL of the github REST API, for github enterprise servers"},
+	{key: "llm.provider", env: "LLM_PROVIDER", flag: "llm-provider", defaultValue: GEMINI_PROVIDER, usage: "gemini or ollama"},
+	{key: "llm.model", env: "LLM_MODEL", flag: "llm-model", usage: "model reviews are generated with, the provider's default when empty"},
+	{key: "llm.gemini_api_key", env: "GEMINI_API_KEY", flag: "gemini-api-key", usage: "API key for gemini", secret: true},
+	{key: "llm.ollama_url", env: "OLLAMA_URL", flag: "ollama-url", defaultValue: repository.DEFAULT_OLLAMA_URL, usage: "URL of the ollama server"},
+	{key: "llm.fallback_model", env: "LLM_FALLBACK_MODEL", flag: "llm-fallback-model", usage: "smaller model used once a budget is exceeded, " + repository.DEFAULT_GEMINI_FALLBACK_MODEL + " for gemini when empty"},
+	{key: "llm.context_cache_ttl", env: "LLM_CONTEXT_CACHE_TTL", flag: "llm-context-cache-ttl", defaultValue: repository.DEFAULT_REVIEW_CONTEXT_TTL.String(), usage: "how long the system prompt and rules shared by the chunks of a pull request are cached on the provider, gemini caches them explicitly, 0 sends them with every chunk"},
+	{key: "llm.prices", env: "LLM_PRICES", flag: "llm-prices", usage: "comma separated model=prompt/completion[/cached] prices in USD per million tokens, added to the built in gemini prices, cached prompt tokens cost the prompt price when no cached price is given"},
+	{key: "dry_run.enabled", env: "DRY_RUN", flag: "dry-run", defaultValue: "false", usage: "run every review in dry-run mode, nothing is posted to github"},
+	{key: "dry_run.installations", env: "DRY_RUN_INSTALLATIONS", flag: "dry-run-installations", usage: "comma separated installation IDs reviewed in dry-run mode"},
+	{key: "dry_run.repos", env: "DRY_RUN_REPOS", flag: "dry-run-repos", usage: "comma separated owner/repo reviewed in dry-run mode"},
+	{key: "storage.driver", env: "STORAGE_DRIVER", flag: "storage-driver", defaultValue: repository.SQLITE_DIALECT, usage: "sqlite or postgres"},
+	{key: "storage.dsn", env: "STORAGE_DSN", flag: "storage-dsn", usage: "database to store reviews in, " + DEFAULT_SQLITE_DSN + " for sqlite when empty", secret: true},
+	{key: "redaction.enabled", env: "REDACT_SECRETS", flag: "redact-secrets", defaultValue: "true", usage: "mask secrets in diffs before they are sent to the LLM, and flag the lines adding them"},
+	{key: "redaction.patterns_file", env: "REDACTION_PATTERNS_FILE", flag: "redaction-patterns-file", usage: "file of extra regular expressions, one per line, matching secrets to mask"},
+	{key: "feedback.poll_interval", env: "FEEDBACK_POLL_INTERVAL", flag: "feedback-poll-interval", defaultValue: "1h", usage: "how often reactions on posted comments are synced, 0 disables polling"},
+	{key: "reviews.workers", env: "REVIEW_WORKERS", flag: "review-workers", defaultValue: strconv.Itoa(usecase.DEFAULT_REVIEW_WORKERS), usage: "number of reviews running at the same time"},
+	{key: "reviews.queue_size", env: "REVIEW_QUEUE_SIZE", flag: "review-queue-size", defaultValue: strconv.Itoa(usecase.DEFAULT_REVIEW_QUEUE_SIZE), usage: "number of reviews waiting for a worker before webhooks are refused"},
+	{key: "reviews.allowed_link_hosts", env: "REVIEW_ALLOWED_LINK_HOSTS", flag: "review-allowed-link-hosts", defaultValue: strings.Join(usecase.DEFAULT_ALLOWED_LINK_HOSTS, ","), usage: "comma separated hosts, subdomains included, review comments may link to, comments with other links are dropped"},
+	{key: "reviews.prompt_version", env: "REVIEW_PROMPT_VERSION", flag: "review-prompt-version", defaultValue: utils.DEFAULT_PROMPT_VERSION, usage: "version of the bundled prompt templates, repositories can override templates in " + utils.REPOSITORY_PROMPTS_DIR},
+	{key: "reviews.cache_ttl", env: "REVIEW_CACHE_TTL", flag: "review-cache-ttl", defaultValue: usecase.DEFAULT_REVIEW_CACHE_TTL.String(), usage: "how long the comments generated for a chunk of a diff are reused when the same chunk is reviewed again, 0 disables the cache"},
+	{key: "reviews.cache_dir", env: "REVIEW_CACHE_DIR", flag: "review-cache-dir", usage: "directory the review cache is also kept in so it survives restarts, memory only when empty"},
+	{key: "reviews.allowed_mentions", env: "REVIEW_ALLOWED_MENTIONS", flag: "review-allowed-mentions", usage: "comma separated logins or org/team names review comments may @-mention, comments with other mentions are dropped"},
+	{key: "reviews.comment_delay", env: "REVIEW_COMMENT_DELAY", flag: "review-comment-delay", defaultValue: usecase.DEFAULT_REVIEW_DELAYS.Comment.String(), usage: "pause between posting review comments, keeps reviews under github's secondary rate limits"},
+	{key: "reviews.page_delay", env: "REVIEW_PAGE_DELAY", flag: "review-page-delay", defaultValue: usecase.DEFAULT_REVIEW_DELAYS.Page.String(), usage: "pause between reviewing pages of changed files"},
+	{key: "budgets.installation_monthly_usd", env: "BUDGET_INSTALLATION_MONTHLY_USD", flag: "budget-installation-monthly-usd", defaultValue: "0", usage: "monthly LLM budget of each installation in USD, 0 is unlimited"},
+	{key: "budgets.repo_monthly_usd", env: "BUDGET_REPO_MONTHLY_USD", flag: "budget-repo-monthly-usd", defaultValue: "0", usage: "monthly LLM budget of each repository in USD, 0 is unlimited"},
+	{key: "budgets.overrides", env: "BUDGET_OVERRIDES", flag: "budget-overrides", usage: "comma separated installationID=USD or owner/repo=USD monthly budgets replacing the defaults"},
+	{key: "budgets.exceeded_action", env: "BUDGET_EXCEEDED_ACTION", flag: "budget-exceeded-action", defaultValue: model.BUDGET_ACTION_SMALLER_MODEL, usage: "what happens to reviews over budget: smaller_model, summary_only or skip (posting a notice)"},
+	{key: "secrets.key_file", env: "SECRETS_KEY_FILE", flag: "secrets-key-file", usage: "file containing the key of " + secrets.ENCRYPTED_PREFIX + " secrets"},
+	{key: "secrets.reload_interval", env: "SECRETS_RELOAD_INTERVAL", flag: "secrets-reload-interval", defaultValue: secrets.DEFAULT_RELOAD_INTERVAL.String(), usage: "how often secrets are re-read to pick up rotations, 0 disables reloading"},
+	{key: "secrets.rotation_window", env: "SECRETS_ROTATION_WINDOW", flag: "secrets-rotation-window", defaultValue: secrets.DEFAULT_ROTATION_WINDOW.String(), usage: "how long the previous webhook secret is still accepted after it changed"},
+	{key: "installations.welcome", env: "WELCOME_NEW_REPOSITORIES", flag: "welcome-new-repositories", defaultValue: "false", usage: "open a welcome issue explaining the bot on repositories it is added to, unless it is installed on all repositories"},
+	{key: "tls.cert_file", env: "TLS_CERT_FILE", flag: "tls-cert-file", usage: "certificate to serve HTTPS with, plain HTTP when empty"},
+	{key: "tls.key_file", env: "TLS_KEY_FILE", flag: "tls-key-file", usage: "private key of the TLS certificate"},
+	{key: "admin.token", env: "ADMIN_TOKEN", flag: "admin-token", usage: "bearer token of the /admin API, the API is disabled without it or admin.client_ca_file", secret: true},
+	{key: "admin.client_ca_file", env: "ADMIN_CLIENT_CA_FILE", flag: "admin-client-ca-file", usage: "CA certificates the /admin API accepts client certificates (mTLS) from, needs TLS"},
+	{key: "tracing.exporter", env: "TRACES_EXPORTER", flag: "traces-exporter", defaultValue: NONE_EXPORTER, usage: "otlp, stdout or none"},
+}
+
+// settingValue is the effective raw value of a setting
+type settingValue struct {
+	value string
+	// source and, for anything but the default, the name it was set with
+	source string
+	name   string
+}
+
+// Config is the validated configuration of the server
+type Config struct {
+	Env                  string
+	Port                 int
+	AppID                int64
+	PrivateKey           *secrets.Secret
+	WebhookSecret        *secrets.Secret
+	GithubAPIURL         string
+	LLM                  LLMConfig
+	DryRun               *usecase.DryRunPolicy
+	StorageDriver        string
+	StorageDSN           string
+	FeedbackPollInterval time.Duration
+	ReviewWorkers        int
+	ReviewQueueSize      int
+	ReviewDelays         usecase.ReviewDelays
+	TracesExporter       string
+
+	// Masks secrets in code sent to the LLM, nil when redaction is disabled
+	Redactor *utils.Redactor
+
+	// Links and mentions allowed in what the LLM generates
+	OutputPolicy *usecase.OutputPolicy
+
+	// Version of the bundled prompt templates reviews are generated with
+	PromptVersion string
+
+	// How long reviews of chunks are reused, 0 disables the cache, and where they are kept besides memory
+	ReviewCacheTTL time.Duration
+	ReviewCacheDir string
+
+	// What LLM calls cost, and the monthly budgets checked before reviewing
+	Prices usecase.PriceTable
+	Budget *usecase.BudgetPolicy
+
+	WelcomeNewRepositories bool
+
+	// HTTPS, and the credentials of the admin API, it is disabled when both are nil
+	TLSCertFile    string
+	TLSKeyFile     string
+	AdminToken     *secrets.Secret
+	AdminClientCAs *x509.CertPool
+
+	// How often file based secrets are re-read, and how long the previous webhook secret is accepted after a change
+	SecretsReloadInterval time.Duration
+	SecretsRotationWindow time.Duration
+
+	// PrintConfig asks for the effective configuration to be printed instead of starting the server
+	PrintConfig bool
+
+	values map[string]settingValue
+}
+
+// ConfigProblem is a single invalid or missing setting
+type ConfigProblem struct {
+	Key     string
+	Env     string
+	Message string
+}
+
+// ConfigError reports every problem found in the configuration at once
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	var builder strings.Builder
+	builder.WriteString("invalid configuration:")
+	for _, problem := range e.Problems {
+		if problem.Env != "" {
+			fmt.Fprintf(&builder, "\n  - %s (%s): %s", problem.Key, problem.Env, problem.Message)
+		} else {
+			fmt.Fprintf(&builder, "\n  - %s: %s", problem.Key, problem.Message)
+		}
+	}
+	return builder.String()
+}
+
+// LoadConfig loads the defaults, then the config file, then environment variables, then the flags in args,
+// later sources override earlier ones. The config is returned with a *ConfigError listing every problem
+// when it is invalid, so it can still be printed.
+func LoadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	values := map[string]settingValue{}
+	for _, s := range settings {
+		values[s.key] = settingValue{value: s.defaultValue, source: SOURCE_DEFAULT}
+	}
+
+	// Flags are parsed first to find the config file, but applied last
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML config file, also read from $"+CONFIG_FILE_ENV)
+	printConfig := flags.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		flags.Func(s.flag, s.usage+" ($"+s.env+")", func(value string) error {
+			flagValues[s.key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	var problems []ConfigProblem
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(CONFIG_FILE_ENV)
+	}
+	if path != "" {
+		fileValues, err := readConfigFile(path)
+		if err != nil {
+			problems = append(problems, ConfigProblem{Key: "config", Message: err.Error()})
+		}
+		for key, value := range fileValues {
+			if _, ok := values[key]; !ok {
+				problems = append(problems, ConfigProblem{Key: key, Message: "unknown setting in " + path})
+				continue
+			}
+			values[key] = settingValue{value: value, source: SOURCE_FILE, name: path}
+		}
+	}
+
+	// Empty variables count as unset, like the blank lines of a .env file
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			values[s.key] = settingValue{value: value, source: SOURCE_ENV, name: s.env}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.key]; ok {
+			values[s.key] = settingValue{value: value, source: SOURCE_FLAG, name: "--" + s.flag}
+		}
+	}
+
+	config := &Config{
+		PrintConfig: *printConfig,
+		values:      values,
+	}
+	problems = append(problems, config.parse()...)
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool {
+			return settingIndex(problems[i].Key) < settingIndex(problems[j].Key)
+		})
+		return config, &ConfigError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+// parse converts and validates the raw values, returning every problem found
+func (c *Config) parse() []ConfigProblem {
+	var problems []ConfigProblem
+	problem := func(key string, format string, args ...any) {
+		problems = append(problems, ConfigProblem{Key: key, Env: settingEnv(key), Message: fmt.Sprintf(format, args...)})
+	}
+	value := func(key string) string {
+		return strings.TrimSpace(c.values[key].value)
+	}
+
+	c.Env = value("env")
+	if c.Env != DEVELOPMENT_ENV && c.Env != PRODUCTION_ENV {
+		problem("env", "must be %s or %s, got %q", DEVELOPMENT_ENV, PRODUCTION_ENV, c.Env)
+	}
+
+	port, err := strconv.Atoi(value("port"))
+	if err != nil || port < 1 || port > 65535 {
+		problem("port", "must be a number between 1 and 65535, got %q", value("port"))
+	}
+	c.Port = port
+
+	if value("github.app_id") == "" {
+		problem("github.app_id", "is not set")
+	} else {
+		c.AppID, err = strconv.ParseInt(value("github.app_id"), 10, 64)
+		if err != nil || c.AppID <= 0 {
+			problem("github.app_id", "must be a positive number, got %q", value("github.app_id"))
+		}
+	}
+
+	// Secrets are either given directly or as a reference to where they are stored, see secrets.ParseSource
+	var encryptionKey *[secrets.KEY_SIZE]byte
+	if keyFile := value("secrets.key_file"); keyFile != "" {
+		encryptionKey, err = secrets.LoadKeyFile(keyFile)
+		if err != nil {
+			problem("secrets.key_file", "%v", err)
+		}
+	}
+	c.SecretsReloadInterval, err = time.ParseDuration(value("secrets.reload_interval"))
+	if err != nil || c.SecretsReloadInterval < 0 {
+		problem("secrets.reload_interval", "must be a positive duration like 30s, got %q", value("secrets.reload_interval"))
+	}
+	c.SecretsRotationWindow, err = time.ParseDuration(value("secrets.rotation_window"))
+	if err != nil || c.SecretsRotationWindow < 0 {
+		problem("secrets.rotation_window", "must be a positive duration like 1h, got %q", value("secrets.rotation_window"))
+	}
+	loadSecret := func(key string, reference string, validate func([]byte) error) *secrets.Secret {
+		source, err := secrets.ParseSource(reference, encryptionKey)
+		if err != nil {
+			problem(key, "%v", err)
+			return nil
+		}
+		secret, err := secrets.New(source, validate, c.SecretsRotationWindow)
+		if err != nil {
+			problem(key, "%v", err)
+			return nil
+		}
+		return secret
+	}
+
+	privateKey := c.values["github.private_key"].value
+	if keyFile := value("github.private_key_file"); keyFile != "" {
+		if privateKey != "" {
+			problem("github.private_key", "only one of github.private_key and github.private_key_file can be set")
+		}
+		privateKey = secrets.FILE_PREFIX + keyFile
+	}
+	if privateKey == "" {
+		problem("github.private_key", "is not set, set it or github.private_key_file")
+	} else {
+		c.PrivateKey = loadSecret("github.private_key", privateKey, validatePrivateKey)
+	}
+
+	if webhookSecret := c.values["github.webhook_secret"].value; webhookSecret == "" {
+		problem("github.webhook_secret", "is not set, webhooks can't be verified without it")
+	} else {
+		c.WebhookSecret = loadSecret("github.webhook_secret", webhookSecret, nil)
+	}
+
+	c.GithubAPIURL = value("github.api_url")
+	if githubURL, err := url.Parse(c.GithubAPIURL); err != nil || (githubURL.Scheme != "http" && githubURL.Scheme != "https") || githubURL.Host == "" {
+		problem("github.api_url", "must be an http or https URL, got %q", c.GithubAPIURL)
+	}
+
+	c.TLSCertFile = value("tls.cert_file")
+	c.TLSKeyFile = value("tls.key_file")
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problem("tls.cert_file", "tls.cert_file and tls.key_file must be set together")
+	}
+
+	if token := c.values["admin.token"].value; token != "" {
+		c.AdminToken = loadSecret("admin.token", token, nil)
+	}
+	if caFile := value("admin.client_ca_file"); caFile != "" {
+		if c.TLSCertFile == "" {
+			problem("admin.client_ca_file", "client certificates need TLS, set tls.cert_file and tls.key_file")
+		}
+		caData, err := os.ReadFile(caFile)
+		if err != nil {
+			problem("admin.client_ca_file", "failed to read CA certificates: %v", err)
+		} else {
+			c.AdminClientCAs = x509.NewCertPool()
+			if !c.AdminClientCAs.AppendCertsFromPEM(caData) {
+				problem("admin.client_ca_file", "no PEM encoded certificates found in %s", caFile)
+			}
+		}
+	}
+
+	c.LLM = LLMConfig{
+		Provider:      value("llm.provider"),
+		Model:         value("llm.model"),
+		FallbackModel: value("llm.fallback_model"),
+		OllamaURL:     value("llm.ollama_url"),
+	}
+	c.LLM.ContextCacheTTL, err = time.ParseDuration(value("llm.context_cache_ttl"))
+	if err != nil || c.LLM.ContextCacheTTL < 0 {
+		problem("llm.context_cache_ttl", "must be a duration like 1h, or 0 to disable context caching, got %q", value("llm.context_cache_ttl"))
+	}
+	switch c.LLM.Provider {
+	case GEMINI_PROVIDER:
+		if apiKey := value("llm.gemini_api_key"); apiKey == "" {
+			problem("llm.gemini_api_key", "is not set, it is required by the %s provider", GEMINI_PROVIDER)
+		} else if secret := loadSecret("llm.gemini_api_key", apiKey, nil); secret != nil {
+			c.LLM.GeminiApiKey = string(secret.Value())
+		}
+		if !isGeminiModel(c.LLM.Model) {
+			problem("llm.model", "%q is not a gemini model", c.LLM.Model)
+		}
+		if c.LLM.FallbackModel == "" {
+			c.LLM.FallbackModel = repository.DEFAULT_GEMINI_FALLBACK_MODEL
+		}
+		if !isGeminiModel(c.LLM.FallbackModel) {
+			problem("llm.fallback_model", "%q is not a gemini model", c.LLM.FallbackModel)
+		}
+	case OLLAMA_PROVIDER:
+		ollamaURL, err := url.Parse(c.LLM.OllamaURL)
+		if err != nil || (ollamaURL.Scheme != "http" && ollamaURL.Scheme != "https") || ollamaURL.Host == "" {
+			problem("llm.ollama_url", "must be an http or https URL, got %q", c.LLM.OllamaURL)
+		}
+	default:
+		problem("llm.provider", "must be %s or %s, got %q", GEMINI_PROVIDER, OLLAMA_PROVIDER, c.LLM.Provider)
+	}
+	if c.LLM.Model != "" && !modelNamePattern.MatchString(c.LLM.Model) {
+		problem("llm.model", "%q is not a valid model name", c.LLM.Model)
+	}
+	if c.LLM.FallbackModel != "" && !modelNamePattern.MatchString(c.LLM.FallbackModel) {
+		problem("llm.fallback_model", "%q is not a valid model name", c.LLM.FallbackModel)
+	}
+
+	c.Prices, err = usecase.ParsePriceTable(value("llm.prices"))
+	if err != nil {
+		problem("llm.prices", "%v", err)
+	}
+	budgetsValid := true
+	for _, key := range []string{"budgets.installation_monthly_usd", "budgets.repo_monthly_usd"} {
+		if amount, err := strconv.ParseFloat(value(key), 64); err != nil || amount < 0 {
+			problem(key, "must be an amount in USD, 0 for unlimited, got %q", value(key))
+			budgetsValid = false
+		}
+	}
+	budgetActions := []string{model.BUDGET_ACTION_SMALLER_MODEL, model.BUDGET_ACTION_SUMMARY_ONLY, model.BUDGET_ACTION_SKIP}
+	if !slices.Contains(budgetActions, value("budgets.exceeded_action")) {
+		problem("budgets.exceeded_action", "must be %s, got %q", strings.Join(budgetActions, ", "), value("budgets.exceeded_action"))
+		budgetsValid = false
+	}
+	if budgetsValid {
+		c.Budget, err = usecase.ParseBudgetPolicy(value("budgets.installation_monthly_usd"), value("budgets.repo_monthly_usd"), value("budgets.overrides"), value("budgets.exceeded_action"))
+		if err != nil {
+			problem("budgets.overrides", "%v", err)
+		}
+	}
+	if c.Budget != nil && c.Budget.Enabled() {
+		if c.Budget.Action() == model.BUDGET_ACTION_SMALLER_MODEL && c.LLM.FallbackModel == "" {
+			problem("llm.fallback_model", "is not set, it is required by the %s budget action", model.BUDGET_ACTION_SMALLER_MODEL)
+		}
+		// Models without a price cost nothing, so their budget would never run out
+		if c.LLM.Provider == GEMINI_PROVIDER && c.Prices != nil {
+			reviewModel := c.LLM.Model
+			if reviewModel == "" {
+				reviewModel = repository.DEFAULT_GEMINI_MODEL
+			}
+			for _, llmModel := range []string{reviewModel, c.LLM.FallbackModel} {
+				if !c.Prices.Has(llmModel) {
+					problem("llm.prices", "no price for %q, budgets can't be enforced without one", llmModel)
+				}
+			}
+		}
+	}
+
+	dryRun := value("dry_run.enabled")
+	if _, err := strconv.ParseBool(dryRun); err != nil {
+		problem("dry_run.enabled", "must be true or false, got %q", dryRun)
+		dryRun = "false"
+	}
+	c.DryRun, err = usecase.ParseDryRunPolicy(dryRun, value("dry_run.installations"), value("dry_run.repos"))
+	if err != nil {
+		problem("dry_run.installations", "must be a comma separated list of installation IDs, got %q", value("dry_run.installations"))
+	}
+
+	c.StorageDriver = value("storage.driver")
+	if dsn := value("storage.dsn"); dsn != "" {
+		if secret := loadSecret("storage.dsn", dsn, nil); secret != nil {
+			c.StorageDSN = string(secret.Value())
+		}
+	}
+	switch c.StorageDriver {
+	case repository.SQLITE_DIALECT:
+	case repository.POSTGRES_DIALECT:
+		if c.StorageDSN == "" {
+			problem("storage.dsn", "is not set, it is required by the %s driver", repository.POSTGRES_DIALECT)
+		}
+	default:
+		problem("storage.driver", "must be %s or %s, got %q", repository.SQLITE_DIALECT, repository.POSTGRES_DIALECT, c.StorageDriver)
+	}
+
+	redact, err := strconv.ParseBool(value("redaction.enabled"))
+	if err != nil {
+		problem("redaction.enabled", "must be true or false, got %q", value("redaction.enabled"))
+	}
+	if redact {
+		var extraRules []utils.SecretRule
+		if patternsFile := value("redaction.patterns_file"); patternsFile != "" {
+			extraRules, err = utils.LoadSecretRules(patternsFile)
+			if err != nil {
+				problem("redaction.patterns_file", "%v", err)
+			}
+		}
+		c.Redactor = utils.NewRedactor(extraRules)
+	}
+
+	linkHosts, err := usecase.ParseLinkHosts(value("reviews.allowed_link_hosts"))
+	if err != nil {
+		problem("reviews.allowed_link_hosts", "%v", err)
+	}
+	mentions, err := usecase.ParseMentions(value("reviews.allowed_mentions"))
+	if err != nil {
+		problem("reviews.allowed_mentions", "%v", err)
+	}
+	c.OutputPolicy = usecase.NewOutputPolicy(linkHosts, mentions)
+
+	c.PromptVersion = value("reviews.prompt_version")
+	if !slices.Contains(utils.PromptVersions(), c.PromptVersion) {
+		problem("reviews.prompt_version", "must be one of %s, got %q", strings.Join(utils.PromptVersions(), ", "), c.PromptVersion)
+	}
+
+	c.ReviewCacheTTL, err = time.ParseDuration(value("reviews.cache_ttl"))
+	if err != nil || c.ReviewCacheTTL < 0 {
+		problem("reviews.cache_ttl", "must be a duration like 24h, or 0 to disable the cache, got %q", value("reviews.cache_ttl"))
+	}
+	c.ReviewCacheDir = value("reviews.cache_dir")
+
+	c.FeedbackPollInterval, err = time.ParseDuration(value("feedback.poll_interval"))
+	if err != nil || c.FeedbackPollInterval < 0 {
+		problem("feedback.poll_interval", "must be a positive duration like 30m or 1h, got %q", value("feedback.poll_interval"))
+	}
+
+	c.ReviewWorkers, err = strconv.Atoi(value("reviews.workers"))
+	if err != nil || c.ReviewWorkers < 1 {
+		problem("reviews.workers", "must be a number greater than 0, got %q", value("reviews.workers"))
+	}
+	c.ReviewQueueSize, err = strconv.Atoi(value("reviews.queue_size"))
+	if err != nil || c.ReviewQueueSize < 1 {
+		problem("reviews.queue_size", "must be a number greater than 0, got %q", value("reviews.queue_size"))
+	}
+
+	c.ReviewDelays.Comment, err = time.ParseDuration(value("reviews.comment_delay"))
+	if err != nil || c.ReviewDelays.Comment < 0 {
+		problem("reviews.comment_delay", "must be a duration like 5s, or 0 to post without pausing, got %q", value("reviews.comment_delay"))
+	}
+	c.ReviewDelays.Page, err = time.ParseDuration(value("reviews.page_delay"))
+	if err != nil || c.ReviewDelays.Page < 0 {
+		problem("reviews.page_delay", "must be a duration like 15s, or 0 to review without pausing, got %q", value("reviews.page_delay"))
+	}
+
+	c.WelcomeNewRepositories, err = strconv.ParseBool(value("installations.welcome"))
+	if err != nil {
+		problem("installations.welcome", "must be true or false, got %q", value("installations.welcome"))
+	}
+
+	c.TracesExporter = value("tracing.exporter")
+	if !slices.Contains([]string{OTLP_EXPORTER, STDOUT_EXPORTER, NONE_EXPORTER}, c.TracesExporter) {
+		problem("tracing.exporter", "must be %s, %s or %s, got %q", OTLP_EXPORTER, STDOUT_EXPORTER, NONE_EXPORTER, c.TracesExporter)
+	}
+
+	return problems
+}
+
+// Print writes every setting with its effective value and where it came from, secrets are redacted
+func (c *Config) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := c.values[s.key]
+		source := value.source
+		if value.name != "" {
+			source += " " + value.name
+		}
+		display := displayValue(s, value.value)
+		if display == "" {
+			display = `""`
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.key, display, source)
+	}
+	return tw.Flush()
+}
+
+// LogValue logs the effective settings with secrets redacted, so the config can be logged as is
+func (c *Config) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.key, displayValue(s, c.values[s.key].value)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func displayValue(s setting, value string) string {
+	// References only say where the secret is stored, they can be shown
+	if s.secret && value != "" && !isSecretReference(value) {
+		return REDACTED
+	}
+	return value
+}
+
+func isSecretReference(value string) bool {
+	for _, prefix := range []string{secrets.ENV_PREFIX, secrets.BASE64_ENV_PREFIX, secrets.FILE_PREFIX, secrets.ENCRYPTED_PREFIX} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGeminiModel reports whether the model is served by gemini, empty uses the default model
+func isGeminiModel(llmModel string) bool {
+	llmModel = strings.TrimPrefix(llmModel, "models/")
+	return llmModel == "" || strings.HasPrefix(llmModel, "gemini") || strings.HasPrefix(llmModel, "gemma")
+}
+
+func validatePrivateKey(value []byte) error {
+	_, err := jwt.ParseRSAPrivateKeyFromPEM(value)
+	return err
+}
+
+// readConfigFile reads a YAML config file into setting keys, nested maps are joined with dots
+// and lists are joined with commas
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	flattenConfig("", document, values)
+	return values, nil
+}
+
+func flattenConfig(prefix string, document map[string]any, values map[string]string) {
+	for key, value := range document {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			flattenConfig(key, value, values)
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func settingEnv(key string) string {
+	for _, s := range settings {
+		if s.key == key {
+			return s.env
+		}
+	}
+	return ""
+}
+
+func settingIndex(key string) int {
+	for i, s := range settings {
+		if s.key == key {
+			return i
+		}
+	}
+	return len(settings)
+}
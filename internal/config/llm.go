@@ -0,0 +1,46 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+)
+
+const GEMINI_PROVIDER = "gemini"
+const OLLAMA_PROVIDER = "ollama"
+
+// FAKE_PROVIDER reviews with regular expressions instead of a model, for evaluations and tests
+const FAKE_PROVIDER = "fake"
+
+type LLMConfig struct {
+	// Provider is gemini, ollama or fake
+	Provider string
+	Model    string
+	// FallbackModel is used once a budget is exceeded, see usecase.BudgetUsecase
+	FallbackModel string
+	GeminiApiKey  string
+	OllamaURL     string
+	// ContextCacheTTL is how long review contexts are cached on providers which support it, 0 disables caching
+	ContextCacheTTL time.Duration
+}
+
+// NewLLMRepository creates the LLM provider selected in the config
+func NewLLMRepository(ctx context.Context, llmConfig LLMConfig) (usecase.LLMRepository, error) {
+	switch llmConfig.Provider {
+	case GEMINI_PROVIDER, "":
+		client, err := NewGeminiClient(ctx, llmConfig.GeminiApiKey)
+		if err != nil {
+			return nil, err
+		}
+		return repository.NewGeminiRepository(client, llmConfig.Model, llmConfig.ContextCacheTTL, ctx), nil
+	case OLLAMA_PROVIDER:
+		return repository.NewOllamaRepository(llmConfig.OllamaURL, llmConfig.Model, llmConfig.ContextCacheTTL), nil
+	case FAKE_PROVIDER:
+		return repository.NewFakeLLMRepository(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q, expected %s, %s or %s", llmConfig.Provider, GEMINI_PROVIDER, OLLAMA_PROVIDER, FAKE_PROVIDER)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	_ "modernc.org/sqlite"
+)
+
+const DEFAULT_SQLITE_DSN = "reviews.db"
+
+// NewReviewRepository opens the review history database, driver is sqlite (default) or postgres
+func NewReviewRepository(ctx context.Context, driver string, dsn string) (*repository.SQLReviewRepository, error) {
+	switch driver {
+	case repository.SQLITE_DIALECT, "":
+		if dsn == "" {
+			dsn = DEFAULT_SQLITE_DSN
+		}
+		// Wait on locks instead of failing, the webhook handler and background jobs write concurrently
+		if !strings.Contains(dsn, "_pragma") {
+			separator := "?"
+			if strings.Contains(dsn, "?") {
+				separator = "&"
+			}
+			dsn += separator + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+		}
+
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+		}
+		// SQLite only supports a single writer
+		db.SetMaxOpenConns(1)
+
+		return repository.NewSQLReviewRepository(ctx, db, repository.SQLITE_DIALECT)
+	case repository.POSTGRES_DIALECT:
+		// The postgres driver isn't bundled, register one (e.g. github.com/jackc/pgx/v5/stdlib) to use it
+		driverName := ""
+		for _, name := range []string{"pgx", "postgres"} {
+			if slices.Contains(sql.Drivers(), name) {
+				driverName = name
+				break
+			}
+		}
+		if driverName == "" {
+			return nil, fmt.Errorf("no postgres database/sql driver is registered in this build")
+		}
+
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open postgres database: %w", err)
+		}
+
+		return repository.NewSQLReviewRepository(ctx, db, repository.POSTGRES_DIALECT)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, expected %s or %s", driver, repository.SQLITE_DIALECT, repository.POSTGRES_DIALECT)
+	}
+}
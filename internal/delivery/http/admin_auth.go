@@ -0,0 +1,36 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+)
+
+// AdminAuth only lets through requests with a client certificate verified against the admin CAs (mTLS),
+// or with the admin token as a bearer token. token is nil when only mTLS is configured.
+func AdminAuth(token *secrets.Secret) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The TLS config only verifies client certificates when admin CAs are configured
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && token != nil && bearer != "" {
+				for _, value := range token.Values() {
+					if subtle.ConstantTimeCompare([]byte(bearer), value) == 1 {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			json.WriteUnauthorizedJSON(w, "unauthorized")
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminController inspects and controls the service at runtime, its routes are behind AdminAuth
+type AdminController struct {
+	history *usecase.ReviewHistoryUsecase
+	admin   *usecase.AdminUsecase
+}
+
+func NewAdminController(history *usecase.ReviewHistoryUsecase, admin *usecase.AdminUsecase) *AdminController {
+	return &AdminController{
+		history: history,
+		admin:   admin,
+	}
+}
+
+// ListReviews lists reviews newest first, filtered by the repo (owner/repo), dry_run and limit query parameters
+func (c *AdminController) ListReviews(w http.ResponseWriter, r *http.Request) {
+	var dryRun *bool
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			json.WriteBadRequestJSON(w, "invalid dry_run, expected true or false")
+			return
+		}
+		dryRun = &parsed
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	runs, err := c.history.ListRuns(r.Context(), r.URL.Query().Get("repo"), dryRun, limit)
+	if err != nil {
+		slog.Error("error listing reviews", "error", err)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", runs)
+}
+
+// GetReview returns a review with the comments it generated
+func (c *AdminController) GetReview(w http.ResponseWriter, r *http.Request) {
+	run, ok := c.getRun(w, r)
+	if !ok {
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", run)
+}
+
+// ListComments returns only the comments a review generated
+func (c *AdminController) ListComments(w http.ResponseWriter, r *http.Request) {
+	run, ok := c.getRun(w, r)
+	if !ok {
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", run.Comments)
+}
+
+// Rerun queues a new review of a pull request at its current head, a sha in the body must be the head
+func (c *AdminController) Rerun(w http.ResponseWriter, r *http.Request) {
+	var request model.RerunRequest
+	if err := json.ReadJSON(r, &request); err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	job, err := c.admin.Rerun(r.Context(), request)
+	if errors.Is(err, usecase.ErrInvalidRequest) {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrQueueFull) {
+		json.WriteErrorJSON(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("error queueing review", "error", err, "repo", request.Repo, "pullNumber", request.PullNumber)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusAccepted, "review queued", job)
+}
+
+// ListJobs lists the queued, running and recently finished jobs
+func (c *AdminController) ListJobs(w http.ResponseWriter, r *http.Request) {
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", c.admin.Jobs())
+}
+
+// CancelJob stops a queued or running job
+func (c *AdminController) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		json.WriteBadRequestJSON(w, "invalid job id")
+		return
+	}
+
+	job, err := c.admin.CancelJob(id)
+	if errors.Is(err, usecase.ErrJobNotFound) {
+		json.WriteNotFoundJSON(w, err.Error())
+		return
+	}
+	if errors.Is(err, usecase.ErrJobFinished) {
+		json.WriteErrorJSON(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "job cancelled", job)
+}
+
+// ListInstallations lists the installations of the app with their dry-run settings
+func (c *AdminController) ListInstallations(w http.ResponseWriter, r *http.Request) {
+	installations, err := c.admin.Installations(r.Context())
+	if err != nil {
+		slog.Error("error listing installations", "error", err)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", installations)
+}
+
+// ListUsage sums LLM tokens and cost per repository for the month query parameter (YYYY-MM), the current month by default
+func (c *AdminController) ListUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := c.admin.Usage(r.Context(), r.URL.Query().Get("month"))
+	if errors.Is(err, usecase.ErrInvalidRequest) {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("error listing llm usage", "error", err)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", usage)
+}
+
+// GetDryRun returns what dry-run mode is enabled for
+func (c *AdminController) GetDryRun(w http.ResponseWriter, r *http.Request) {
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", c.admin.DryRunSettings())
+}
+
+// SetDryRun turns dry-run mode on or off for an installation, a repository, or every review
+func (c *AdminController) SetDryRun(w http.ResponseWriter, r *http.Request) {
+	var update model.DryRunUpdate
+	if err := json.ReadJSON(r, &update); err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	settings, err := c.admin.SetDryRun(update)
+	if err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	slog.Info("dry run settings changed", "installationID", update.InstallationID, "repo", update.Repo, "enabled", update.Enabled)
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", settings)
+}
+
+func (c *AdminController) getRun(w http.ResponseWriter, r *http.Request) (*model.ReviewRunDetail, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		json.WriteBadRequestJSON(w, "invalid review id")
+		return nil, false
+	}
+
+	run, err := c.history.GetRun(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		json.WriteNotFoundJSON(w, "review not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("error fetching review", "error", err, "id", id)
+		json.WriteJSONError(w, err)
+		return nil, false
+	}
+
+	return run, true
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+)
+
+// FeedbackController exposes how useful developers found the bot's comments
+type FeedbackController struct {
+	usecase *usecase.ReviewHistoryUsecase
+}
+
+func NewFeedbackController(usecase *usecase.ReviewHistoryUsecase) *FeedbackController {
+	return &FeedbackController{
+		usecase: usecase,
+	}
+}
+
+// Stats returns per repository and per category precision, the repo query parameter (owner/repo) filters them
+func (c *FeedbackController) Stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := c.usecase.FeedbackStats(r.Context(), r.URL.Query().Get("repo"))
+	if err != nil {
+		slog.Error("error computing feedback stats", "error", err)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", stats)
+}
@@ -1,31 +1,43 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
-	"time"
 
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+	"github.com/go-chi/chi/middleware"
 	"github.com/google/go-github/v74/github"
 	"google.golang.org/genai"
 )
 
 type GithubController struct {
-	usecase          *usecase.GithubUsecase
-	webhookSecretKey string
-	client           *genai.Client
+	usecase       *usecase.GithubUsecase
+	installations *usecase.InstallationUsecase
+	queue         *usecase.ReviewQueue
+	webhookSecret *secrets.Secret
+	client        *genai.Client
 }
 
-func NewGithubController(usecase *usecase.GithubUsecase, webhookSecretKey string) *GithubController {
+func NewGithubController(usecase *usecase.GithubUsecase, installations *usecase.InstallationUsecase, queue *usecase.ReviewQueue, webhookSecret *secrets.Secret) *GithubController {
 	return &GithubController{
-		usecase:          usecase,
-		webhookSecretKey: webhookSecretKey,
+		usecase:       usecase,
+		installations: installations,
+		queue:         queue,
+		webhookSecret: webhookSecret,
 	}
 }
 
 func (c *GithubController) MainReciever(w http.ResponseWriter, r *http.Request) {
-	payload, err := github.ValidatePayload(r, []byte(c.webhookSecretKey))
+	payload, err := c.validatePayload(r)
 	if err != nil {
 		slog.Error("error validating github webhook request payload", "error", err)
 		return
@@ -38,28 +50,114 @@ func (c *GithubController) MainReciever(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	action := ""
+	if actionEvent, ok := event.(interface{ GetAction() string }); ok {
+		action = actionEvent.GetAction()
+	}
+	metrics.WebhooksReceived.WithLabelValues(github.WebHookType(r), action).Inc()
+
+	// Everything done for this delivery, including the background review, is part of one trace
+	ctx, span := tracing.Start(r.Context(), "GithubController.MainReciever",
+		tracing.EventKey.String(github.WebHookType(r)),
+		tracing.ActionKey.String(action),
+		tracing.DeliveryIDKey.String(github.DeliveryID(r)),
+		tracing.RequestIDKey.String(middleware.GetReqID(r.Context())),
+	)
+	defer span.End()
+
 	switch event := event.(type) {
 	case *github.PullRequestEvent:
 		pullRequest := event
 		slog.Info("pull request event received")
 
-		// Return 200 immediately to GitHub to prevent timeout
+		// Queue the review and return immediately to GitHub to prevent timeout, the job is detached from
+		// the request but stays in the same trace
+		_, err := c.queue.Submit(tracing.Detach(ctx), usecase.PullRequestJobName(pullRequest), func(ctx context.Context) error {
+			return c.usecase.PullRequestReviewer(ctx, pullRequest)
+		})
+		if err != nil {
+			// GitHub shows the failed delivery so it can be redelivered once the queue has drained
+			slog.Error("error queueing pull request review", "error", err)
+			json.WriteErrorJSON(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Webhook received, processing in background"))
 
-		// Process asynchronously with a longer timeout context
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-			defer cancel()
+	case *github.PullRequestReviewCommentEvent:
+		// Replies to the bot's comments are feedback, they are quick to process so no need for a goroutine
+		err := c.usecase.ReviewCommentFeedback(ctx, event)
+		if err != nil {
+			slog.Error("error recording review comment feedback", "error", err)
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case *github.InstallationEvent:
+		welcome, err := c.installations.InstallationEvent(ctx, event)
+		if err != nil {
+			slog.Error("error recording installation event", "error", err)
+			json.WriteJSONError(w, err)
+			return
+		}
+		c.queueWelcome(ctx, event.GetInstallation().GetID(), welcome)
+		w.WriteHeader(http.StatusOK)
 
-			err := c.usecase.PullRequestReviewer(ctx, pullRequest)
-			if err != nil {
-				slog.Error("error reviewing pull request", "error", err)
-				return
-			}
-		}()
+	case *github.InstallationRepositoriesEvent:
+		welcome, err := c.installations.InstallationRepositoriesEvent(ctx, event)
+		if err != nil {
+			slog.Error("error recording installation repositories event", "error", err)
+			json.WriteJSONError(w, err)
+			return
+		}
+		c.queueWelcome(ctx, event.GetInstallation().GetID(), welcome)
+		w.WriteHeader(http.StatusOK)
 
 	default:
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// queueWelcome welcomes new repositories in the background, a full queue only costs the welcome
+func (c *GithubController) queueWelcome(ctx context.Context, installationID int64, fullNames []string) {
+	if len(fullNames) == 0 {
+		return
+	}
+
+	_, err := c.queue.Submit(tracing.Detach(ctx), "welcome new repositories", func(ctx context.Context) error {
+		return c.installations.Welcome(ctx, installationID, fullNames)
+	})
+	if err != nil {
+		slog.Warn("error queueing welcome of new repositories", "error", err, "installationID", installationID)
+	}
+}
+
+// validatePayload checks the signature of the webhook against the current secret, and against the previous one
+// while a rotation is rolled out to github
+func (c *GithubController) validatePayload(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := r.Header.Get(github.SHA256SignatureHeader)
+	if signature == "" {
+		signature = r.Header.Get(github.SHA1SignatureHeader)
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	// github signs the raw body, whatever the content type
+	err = errors.New("webhook secret is not set")
+	for _, secret := range c.webhookSecret.Values() {
+		err = github.ValidateSignature(signature, body, secret)
+		if err == nil {
+			return github.ValidatePayloadFromBody(contentType, bytes.NewReader(body), signature, secret)
+		}
+	}
+
+	return nil, err
+}
@@ -3,15 +3,36 @@ package http
 import (
 	"net/http"
 
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
 )
 
-type HealthController struct{}
+type HealthController struct {
+	readiness *usecase.ReadinessUsecase
+}
 
-func NewHealthController() *HealthController {
-	return &HealthController{}
+func NewHealthController(readiness *usecase.ReadinessUsecase) *HealthController {
+	return &HealthController{
+		readiness: readiness,
+	}
 }
 
+// Health is the liveness check, it only says the process is up
 func (h *HealthController) Health(w http.ResponseWriter, r *http.Request) {
 	json.WriteSuccessJSON(w, http.StatusOK, "OK", nil)
 }
+
+// Ready checks every dependency a review needs and returns 503 with the failing checks if one is down
+func (h *HealthController) Ready(w http.ResponseWriter, r *http.Request) {
+	readiness := h.readiness.Ready(r.Context())
+	if !readiness.Ready {
+		json.WriteJSON(w, http.StatusServiceUnavailable, json.Envelope{
+			Error:   true,
+			Message: "not ready",
+			Data:    readiness,
+		})
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", readiness)
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/report"
+)
+
+type ReportController struct{}
+
+func NewReportController() *ReportController {
+	return &ReportController{}
+}
+
+// Request body for rendering review comments into a report
+type RenderReportRequest struct {
+	report.Metadata
+	Comments []model.ReviewCommentRequest `json:"comments"`
+}
+
+// Render converts review comments into the format given in the format query parameter (text, json, sarif or markdown)
+func (c *ReportController) Render(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = report.FORMAT_JSON
+	}
+
+	var request RenderReportRequest
+	if err := json.ReadJSON(r, &request); err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	// Render into a buffer first so an unsupported format can still be reported as a JSON error
+	var buffer bytes.Buffer
+	if err := report.Render(&buffer, format, request.Comments, request.Metadata); err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
+}
@@ -1,25 +1,83 @@
 package route
 
 import (
+	nethttp "net/http"
+
 	"github.com/RakibulBh/AI-pr-reviewer/internal/delivery/http"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type RouteConfig struct {
-	R                *chi.Mux
-	GithubController *http.GithubController
-	HealthController *http.HealthController
+	R                  *chi.Mux
+	GithubController   *http.GithubController
+	HealthController   *http.HealthController
+	ReportController   *http.ReportController
+	ShadowController   *http.ShadowController
+	FeedbackController *http.FeedbackController
+
+	// The admin API is only served when AdminAuth is set
+	AdminController *http.AdminController
+	AdminAuth       func(nethttp.Handler) nethttp.Handler
 }
 
 func (c *RouteConfig) Setup() {
 	c.SetupWebhookRoute()
 	c.SetupMetricRoutes()
+	c.SetupReportRoutes()
+	c.SetupAdminRoutes()
 }
 
 func (c *RouteConfig) SetupMetricRoutes() {
 	c.R.Get("/health", c.HealthController.Health)
+	c.R.Get("/ready", c.HealthController.Ready)
+	c.R.Handle("/metrics", promhttp.Handler())
 }
 
 func (c *RouteConfig) SetupWebhookRoute() {
 	c.R.Post("/webhook", c.GithubController.MainReciever)
 }
+
+func (c *RouteConfig) SetupReportRoutes() {
+	c.R.Post("/reports", c.ReportController.Render)
+}
+
+// SetupShadowRoutes serves dry-run reviews on r, they hold comments and code of private repositories so r
+// is the admin router
+func (c *RouteConfig) SetupShadowRoutes(r chi.Router) {
+	r.Get("/shadow/reviews", c.ShadowController.ListReviews)
+	r.Get("/shadow/reviews/{id}", c.ShadowController.GetReview)
+}
+
+// SetupFeedbackRoutes serves feedback stats on r, they name private repositories so r is the admin router
+func (c *RouteConfig) SetupFeedbackRoutes(r chi.Router) {
+	r.Get("/feedback/stats", c.FeedbackController.Stats)
+}
+
+func (c *RouteConfig) SetupAdminRoutes() {
+	if c.AdminAuth == nil {
+		return
+	}
+
+	c.R.Route("/admin", func(r chi.Router) {
+		r.Use(c.AdminAuth)
+
+		r.Get("/reviews", c.AdminController.ListReviews)
+		r.Post("/reviews", c.AdminController.Rerun)
+		r.Get("/reviews/{id}", c.AdminController.GetReview)
+		r.Get("/reviews/{id}/comments", c.AdminController.ListComments)
+
+		r.Get("/jobs", c.AdminController.ListJobs)
+		r.Post("/jobs/{id}/cancel", c.AdminController.CancelJob)
+
+		r.Get("/installations", c.AdminController.ListInstallations)
+
+		r.Get("/usage", c.AdminController.ListUsage)
+
+		r.Get("/dry-run", c.AdminController.GetDryRun)
+		r.Put("/dry-run", c.AdminController.SetDryRun)
+
+		c.SetupShadowRoutes(r)
+		c.SetupFeedbackRoutes(r)
+	})
+}
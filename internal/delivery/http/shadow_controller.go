@@ -0,0 +1,99 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/usecase"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/json"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils/report"
+	"github.com/go-chi/chi/v5"
+)
+
+// ShadowController exposes the results of reviews which ran in dry-run mode
+type ShadowController struct {
+	usecase *usecase.ReviewHistoryUsecase
+}
+
+func NewShadowController(usecase *usecase.ReviewHistoryUsecase) *ShadowController {
+	return &ShadowController{
+		usecase: usecase,
+	}
+}
+
+// ListReviews lists dry-run reviews newest first, the repo query parameter (owner/repo) filters them
+func (c *ShadowController) ListReviews(w http.ResponseWriter, r *http.Request) {
+	dryRun := true
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	runs, err := c.usecase.ListRuns(r.Context(), r.URL.Query().Get("repo"), &dryRun, limit)
+	if err != nil {
+		slog.Error("error listing dry run reviews", "error", err)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	json.WriteSuccessJSON(w, http.StatusOK, "OK", runs)
+}
+
+// GetReview returns a single dry-run review with its would-be comments, or renders it when the format query parameter is set
+func (c *ShadowController) GetReview(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		json.WriteBadRequestJSON(w, "invalid review id")
+		return
+	}
+
+	run, err := c.usecase.GetRun(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !run.DryRun) {
+		json.WriteNotFoundJSON(w, "review not found")
+		return
+	}
+	if err != nil {
+		slog.Error("error fetching dry run review", "error", err, "id", id)
+		json.WriteJSONError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		json.WriteSuccessJSON(w, http.StatusOK, "OK", run)
+		return
+	}
+
+	writeReport(w, format, run)
+}
+
+// writeReport renders the comments of a review run in the requested format
+func writeReport(w http.ResponseWriter, format string, run *model.ReviewRunDetail) {
+	comments := make([]model.ReviewCommentRequest, 0, len(run.Comments))
+	for _, comment := range run.Comments {
+		comments = append(comments, model.ReviewCommentRequest{
+			Body:     comment.Body,
+			CommitID: run.HeadSHA,
+			Path:     comment.Path,
+			Line:     comment.Line,
+		})
+	}
+
+	metadata := report.Metadata{
+		Repository: run.Owner + "/" + run.Repo,
+		PullNumber: run.PullNumber,
+		CommitID:   run.HeadSHA,
+	}
+
+	var buffer bytes.Buffer
+	if err := report.Render(&buffer, format, comments, metadata); err != nil {
+		json.WriteBadRequestJSON(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
+}
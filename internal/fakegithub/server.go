@@ -0,0 +1,676 @@
+// Package fakegithub serves the parts of the github API the reviewer uses from memory, so the whole app can be
+// driven by webhooks in tests without network access
+package fakegithub
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v74/github"
+)
+
+// Slug of the fake app, the bot posts as APP_SLUG[bot]
+const APP_SLUG = "ai-reviewer"
+
+// github's page size when per_page isn't set, and the largest it accepts
+const (
+	DEFAULT_PER_PAGE = 30
+	MAX_PER_PAGE     = 100
+)
+
+type pullRequestKey struct {
+	fullName string
+	number   int
+}
+
+// Request is a request the fake received, kept so tests can check what was called
+type Request struct {
+	Method string
+	Path   string
+}
+
+// Server is a fake github API. App endpoints require a JWT signed with the app's private key, everything else
+// an installation token created through the fake.
+type Server struct {
+	*httptest.Server
+
+	appID     int64
+	publicKey *rsa.PublicKey
+
+	mu            sync.Mutex
+	nextID        int64
+	tokens        map[string]int64
+	installations []*github.Installation
+	repositories  map[int64][]*github.Repository
+	pullRequests  map[pullRequestKey]*github.PullRequest
+	files         map[pullRequestKey][]*github.CommitFile
+	comments      map[pullRequestKey][]*github.PullRequestComment
+	reviews       map[pullRequestKey][]*github.PullRequestReviewRequest
+	issueComments map[pullRequestKey][]*github.IssueComment
+	issues        map[string][]*github.Issue
+	checkRuns     map[string][]*github.CreateCheckRunOptions
+	contents      map[string]string
+	comparisons   map[string][]*github.CommitFile
+	requests      []Request
+	unhandled     []Request
+}
+
+// NewServer starts a fake github for the app, JWTs are checked against its public key
+func NewServer(appID int64, publicKey *rsa.PublicKey) *Server {
+	s := &Server{
+		appID:         appID,
+		publicKey:     publicKey,
+		nextID:        1000,
+		tokens:        map[string]int64{},
+		repositories:  map[int64][]*github.Repository{},
+		pullRequests:  map[pullRequestKey]*github.PullRequest{},
+		files:         map[pullRequestKey][]*github.CommitFile{},
+		comments:      map[pullRequestKey][]*github.PullRequestComment{},
+		reviews:       map[pullRequestKey][]*github.PullRequestReviewRequest{},
+		issueComments: map[pullRequestKey][]*github.IssueComment{},
+		issues:        map[string][]*github.Issue{},
+		checkRuns:     map[string][]*github.CreateCheckRunOptions{},
+		contents:      map[string]string{},
+		comparisons:   map[string][]*github.CommitFile{},
+	}
+
+	mux := http.NewServeMux()
+	app := func(handler http.HandlerFunc) http.HandlerFunc { return s.requireJWT(handler) }
+	installation := func(handler http.HandlerFunc) http.HandlerFunc { return s.requireInstallationToken(handler) }
+
+	mux.HandleFunc("GET /app", app(s.getApp))
+	mux.HandleFunc("GET /app/installations", app(s.listInstallations))
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", app(s.createInstallationToken))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/installation", app(s.findRepositoryInstallation))
+
+	mux.HandleFunc("GET /installation/repositories", installation(s.listInstallationRepositories))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}", installation(s.getPullRequest))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/files", installation(s.listFiles))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/comments", installation(s.listComments))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls/{number}/comments", installation(s.createComment))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls/{number}/reviews", installation(s.createReview))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/contents/{path...}", installation(s.getContents))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/compare/{basehead...}", installation(s.compareCommits))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/check-runs", installation(s.createCheckRun))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues", installation(s.createIssue))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/comments", installation(s.createIssueComment))
+	mux.HandleFunc("POST /graphql", installation(s.graphQL))
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path})
+		s.mu.Unlock()
+
+		if _, pattern := mux.Handler(r); pattern == "" {
+			s.mu.Lock()
+			s.unhandled = append(s.unhandled, Request{Method: r.Method, Path: r.URL.Path})
+			s.mu.Unlock()
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// AddInstallation installs the app on the account's repositories, given as owner/repo
+func (s *Server) AddInstallation(id int64, account string, fullNames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.installations = append(s.installations, &github.Installation{
+		ID:      github.Ptr(id),
+		Account: &github.User{Login: github.Ptr(account)},
+		AppID:   github.Ptr(s.appID),
+		AppSlug: github.Ptr(APP_SLUG),
+	})
+	for _, fullName := range fullNames {
+		owner, name, _ := strings.Cut(fullName, "/")
+		s.repositories[id] = append(s.repositories[id], &github.Repository{
+			ID:       github.Ptr(s.newID()),
+			Name:     github.Ptr(name),
+			FullName: github.Ptr(fullName),
+			Owner:    &github.User{Login: github.Ptr(owner)},
+		})
+	}
+}
+
+// SuspendInstallation suspends the installation, tokens for it are refused like github does
+func (s *Server) SuspendInstallation(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if installation := s.installation(id); installation != nil {
+		installation.SuspendedAt = &github.Timestamp{Time: time.Now()}
+	}
+}
+
+// AddPullRequest opens a pull request changing the files, owner/repo must belong to an installation
+func (s *Server) AddPullRequest(fullName string, pullRequest *github.PullRequest, files []*github.CommitFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := pullRequestKey{fullName: fullName, number: pullRequest.GetNumber()}
+	s.pullRequests[key] = pullRequest
+	s.files[key] = files
+}
+
+// AddReviewComment adds a comment to the pull request as if someone had already posted it
+func (s *Server) AddReviewComment(fullName string, number int, comment *github.PullRequestComment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment.ID = github.Ptr(s.newID())
+	key := pullRequestKey{fullName: fullName, number: number}
+	s.comments[key] = append(s.comments[key], comment)
+}
+
+// SetFileContent stores the content of the file at ref
+func (s *Server) SetFileContent(fullName string, path string, ref string, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents[fullName+"@"+ref+":"+path] = content
+}
+
+// SetComparison sets the files changed between the base and head commits
+func (s *Server) SetComparison(fullName string, base string, head string, files []*github.CommitFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.comparisons[fullName+":"+base+"..."+head] = files
+}
+
+// ReviewComments returns every review comment on the pull request, including the ones added by the test
+func (s *Server) ReviewComments(fullName string, number int) []*github.PullRequestComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*github.PullRequestComment(nil), s.comments[pullRequestKey{fullName: fullName, number: number}]...)
+}
+
+// PostedReviewComments returns the review comments the app posted on the pull request, in order
+func (s *Server) PostedReviewComments(fullName string, number int) []*github.PullRequestComment {
+	var posted []*github.PullRequestComment
+	for _, comment := range s.ReviewComments(fullName, number) {
+		if comment.GetUser().GetLogin() == APP_SLUG+"[bot]" {
+			posted = append(posted, comment)
+		}
+	}
+	return posted
+}
+
+// Reviews returns the reviews submitted on the pull request
+func (s *Server) Reviews(fullName string, number int) []*github.PullRequestReviewRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*github.PullRequestReviewRequest(nil), s.reviews[pullRequestKey{fullName: fullName, number: number}]...)
+}
+
+// IssueComments returns the comments posted on the conversation of the pull request or issue
+func (s *Server) IssueComments(fullName string, number int) []*github.IssueComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*github.IssueComment(nil), s.issueComments[pullRequestKey{fullName: fullName, number: number}]...)
+}
+
+// Issues returns the issues opened on the repository
+func (s *Server) Issues(fullName string) []*github.Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*github.Issue(nil), s.issues[fullName]...)
+}
+
+// CheckRuns returns the check runs created on the repository
+func (s *Server) CheckRuns(fullName string) []*github.CreateCheckRunOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*github.CreateCheckRunOptions(nil), s.checkRuns[fullName]...)
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Unhandled returns the requests to endpoints the fake doesn't implement
+func (s *Server) Unhandled() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.unhandled...)
+}
+
+// newID returns a new unique ID, s.mu must be held
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// Authentication
+
+func (s *Server) requireJWT(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := jwt.Parse(bearerToken(r), func(token *jwt.Token) (any, error) {
+			return s.publicKey, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(strconv.FormatInt(s.appID, 10)))
+		if err != nil || !token.Valid {
+			writeError(w, http.StatusUnauthorized, fmt.Sprintf("A JSON web token could not be decoded: %v", err))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) requireInstallationToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		_, ok := s.tokens[bearerToken(r)]
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found {
+		return ""
+	}
+	return token
+}
+
+// App endpoints
+
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &github.App{ID: github.Ptr(s.appID), Slug: github.Ptr(APP_SLUG)})
+}
+
+func (s *Server) listInstallations(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	installations := append([]*github.Installation(nil), s.installations...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(installations))
+	writeJSON(w, http.StatusOK, installations[start:end])
+}
+
+func (s *Server) createInstallationToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	installation := s.installation(id)
+	if installation == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if installation.SuspendedAt != nil {
+		writeError(w, http.StatusForbidden, "This installation has been suspended")
+		return
+	}
+	token := fmt.Sprintf("ghs_fake_%d_%d", id, s.newID())
+	s.tokens[token] = id
+
+	writeJSON(w, http.StatusCreated, &github.InstallationToken{
+		Token:     github.Ptr(token),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	})
+}
+
+func (s *Server) findRepositoryInstallation(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, repositories := range s.repositories {
+		for _, repository := range repositories {
+			if strings.EqualFold(repository.GetFullName(), fullName) {
+				writeJSON(w, http.StatusOK, s.installation(id))
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// installation returns the installation with the ID, s.mu must be held
+func (s *Server) installation(id int64) *github.Installation {
+	for _, installation := range s.installations {
+		if installation.GetID() == id {
+			return installation
+		}
+	}
+	return nil
+}
+
+// Installation endpoints
+
+func (s *Server) listInstallationRepositories(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	repositories := append([]*github.Repository(nil), s.repositories[s.tokens[bearerToken(r)]]...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(repositories))
+	writeJSON(w, http.StatusOK, &github.ListRepositories{
+		TotalCount:   github.Ptr(len(repositories)),
+		Repositories: repositories[start:end],
+	})
+}
+
+func (s *Server) getPullRequest(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	pullRequest, found := s.pullRequests[key]
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, pullRequest)
+}
+
+func (s *Server) listFiles(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	files := append([]*github.CommitFile(nil), s.files[key]...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(files))
+	writeJSON(w, http.StatusOK, files[start:end])
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	comments := append([]*github.PullRequestComment(nil), s.comments[key]...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(comments))
+	writeJSON(w, http.StatusOK, comments[start:end])
+}
+
+// createComment adds a review comment, like github it rejects comments on files the pull request doesn't change
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var comment github.PullRequestComment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment.InReplyTo == nil {
+		changed := false
+		for _, file := range s.files[key] {
+			changed = changed || file.GetFilename() == comment.GetPath()
+		}
+		if !changed || comment.GetLine() <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: pull_request_review_thread.path and line must be part of the diff")
+			return
+		}
+	}
+
+	comment.ID = github.Ptr(s.newID())
+	comment.User = &github.User{Login: github.Ptr(APP_SLUG + "[bot]"), Type: github.Ptr("Bot")}
+	s.comments[key] = append(s.comments[key], &comment)
+
+	writeJSON(w, http.StatusCreated, &comment)
+}
+
+func (s *Server) createReview(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var review github.PullRequestReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reviews[key] = append(s.reviews[key], &review)
+	writeJSON(w, http.StatusOK, &github.PullRequestReview{
+		ID:       github.Ptr(s.newID()),
+		Body:     review.Body,
+		CommitID: review.CommitID,
+		State:    review.Event,
+	})
+}
+
+func (s *Server) getContents(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+	path := r.PathValue("path")
+
+	s.mu.Lock()
+	content, found := s.contents[fullName+"@"+r.URL.Query().Get("ref")+":"+path]
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.RepositoryContent{
+		Type:     github.Ptr("file"),
+		Encoding: github.Ptr("base64"),
+		Size:     github.Ptr(len(content)),
+		Path:     github.Ptr(path),
+		Content:  github.Ptr(base64.StdEncoding.EncodeToString([]byte(content))),
+	})
+}
+
+func (s *Server) compareCommits(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+
+	s.mu.Lock()
+	files, found := s.comparisons[fullName+":"+r.PathValue("basehead")]
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.CommitsComparison{Files: files})
+}
+
+func (s *Server) createCheckRun(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+
+	var checkRun github.CreateCheckRunOptions
+	if err := json.NewDecoder(r.Body).Decode(&checkRun); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if checkRun.Name == "" || checkRun.HeadSHA == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name and head_sha are required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkRuns[fullName] = append(s.checkRuns[fullName], &checkRun)
+	writeJSON(w, http.StatusCreated, &github.CheckRun{
+		ID:         github.Ptr(s.newID()),
+		Name:       github.Ptr(checkRun.Name),
+		HeadSHA:    github.Ptr(checkRun.HeadSHA),
+		Status:     checkRun.Status,
+		Conclusion: checkRun.Conclusion,
+	})
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("owner") + "/" + r.PathValue("repo")
+
+	var request github.IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issue := &github.Issue{
+		ID:     github.Ptr(s.newID()),
+		Number: github.Ptr(len(s.issues[fullName]) + 1),
+		Title:  request.Title,
+		Body:   request.Body,
+	}
+	s.issues[fullName] = append(s.issues[fullName], issue)
+	writeJSON(w, http.StatusCreated, issue)
+}
+
+func (s *Server) createIssueComment(w http.ResponseWriter, r *http.Request) {
+	key, ok := pullRequestKeyOf(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var comment github.IssueComment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment.ID = github.Ptr(s.newID())
+	comment.User = &github.User{Login: github.Ptr(APP_SLUG + "[bot]"), Type: github.Ptr("Bot")}
+	s.issueComments[key] = append(s.issueComments[key], &comment)
+	writeJSON(w, http.StatusCreated, &comment)
+}
+
+// graphQL answers review thread queries with no threads and accepts every mutation
+func (s *Server) graphQL(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(request.Query), "mutation") {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{}})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": map[string]any{
+			"repository": map[string]any{
+				"pullRequest": map[string]any{
+					"reviewThreads": map[string]any{
+						"pageInfo": map[string]any{"hasNextPage": false, "endCursor": ""},
+						"nodes":    []any{},
+					},
+				},
+			},
+		},
+	})
+}
+
+// Helpers
+
+func pullRequestKeyOf(r *http.Request) (pullRequestKey, bool) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		return pullRequestKey{}, false
+	}
+	return pullRequestKey{fullName: r.PathValue("owner") + "/" + r.PathValue("repo"), number: number}, true
+}
+
+// paginate returns the bounds of the requested page of total items and sets the Link header like github does
+func paginate(w http.ResponseWriter, r *http.Request, total int) (int, int) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = DEFAULT_PER_PAGE
+	}
+	perPage = min(perPage, MAX_PER_PAGE)
+
+	lastPage := max((total+perPage-1)/perPage, 1)
+	link := func(page int, rel string) string {
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(perPage))
+		pageURL := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, pageURL.String(), rel)
+	}
+	if page < lastPage {
+		w.Header().Set("Link", link(page+1, "next")+", "+link(lastPage, "last"))
+	}
+
+	start := min((page-1)*perPage, total)
+	end := min(start+perPage, total)
+	return start, end
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
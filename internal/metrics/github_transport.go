@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// GithubTransport counts github API requests and records the rate limit github reports on each response
+type GithubTransport struct {
+	Base http.RoundTripper
+}
+
+func NewGithubTransport(base http.RoundTripper) *GithubTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &GithubTransport{
+		Base: base,
+	}
+}
+
+func (t *GithubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		GithubRequests.WithLabelValues(req.Method, "error").Inc()
+		return nil, err
+	}
+
+	GithubRequests.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		resource := resp.Header.Get("X-RateLimit-Resource")
+		if resource == "" {
+			resource = "core"
+		}
+		GithubRateLimitRemaining.WithLabelValues(resource).Set(float64(remaining))
+	}
+
+	return resp, nil
+}
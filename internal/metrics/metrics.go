@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "pr_reviewer"
+
+// Buckets in seconds for whole reviews, which include the sleeps between comments and pages
+var reviewDurationBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 900}
+
+// Buckets in seconds for single LLM calls
+var llmDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80, 160, 300}
+
+var (
+	WebhooksReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhooks_received_total",
+		Help:      "Webhooks received from github by event and action.",
+	}, []string{"event", "action"})
+
+	ReviewsStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_started_total",
+		Help:      "Pull request reviews started.",
+	})
+
+	ReviewsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_finished_total",
+		Help:      "Pull request reviews finished by status (succeeded or failed).",
+	}, []string{"status"})
+
+	ReviewDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "review_duration_seconds",
+		Help:      "Time taken to review a pull request by status.",
+		Buckets:   reviewDurationBuckets,
+	}, []string{"status"})
+
+	LLMRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_requests_total",
+		Help:      "LLM calls by model, operation and status (success or error).",
+	}, []string{"model", "operation", "status"})
+
+	LLMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM calls by model and operation.",
+		Buckets:   llmDurationBuckets,
+	}, []string{"model", "operation"})
+
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by LLM calls by model and type (prompt, completion, or cached_prompt for the prompt tokens read from a context cache).",
+	}, []string{"model", "type"})
+
+	LLMCost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_cost_usd_total",
+		Help:      "Cost of LLM calls in USD by model, from the configured price table.",
+	}, []string{"model"})
+
+	BudgetExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "budget_exceeded_total",
+		Help:      "Reviews degraded because a monthly budget was exceeded, by action (smaller_model, summary_only or skip).",
+	}, []string{"action"})
+
+	SecretsDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "secrets_detected_total",
+		Help:      "Secrets found on lines added by pull requests by rule, they are masked before reaching the LLM.",
+	}, []string{"rule"})
+
+	ReviewComments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "review_comments_total",
+		Help:      "Review comments generated by the LLM by outcome (posted, dry_run, duplicate, rejected or failed).",
+	}, []string{"outcome"})
+
+	GithubRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_api_requests_total",
+		Help:      "Requests made to the github API by method and status code.",
+	}, []string{"method", "code"})
+
+	GithubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_rate_limit_remaining",
+		Help:      "Remaining github API rate limit reported by the last response, by rate limit resource.",
+	}, []string{"resource"})
+
+	ReviewCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "review_cache_lookups_total",
+		Help:      "Lookups of chunks of diffs in the review cache by result (hit, miss or expired).",
+	}, []string{"result"})
+
+	ReviewQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "review_queue_depth",
+		Help:      "Reviews accepted from webhooks which haven't finished yet.",
+	})
+)
+
+// Outcomes of generated review comments
+const (
+	COMMENT_POSTED    = "posted"
+	COMMENT_DRY_RUN   = "dry_run"
+	COMMENT_DUPLICATE = "duplicate"
+	COMMENT_REJECTED  = "rejected"
+	COMMENT_FAILED    = "failed"
+)
+
+// Results of review cache lookups
+const (
+	CACHE_HIT     = "hit"
+	CACHE_MISS    = "miss"
+	CACHE_EXPIRED = "expired"
+)
@@ -0,0 +1,23 @@
+package model
+
+// What dry-run mode is enabled for
+type DryRunSettings struct {
+	Global        bool     `json:"global"`
+	Installations []int64  `json:"installations"`
+	Repos         []string `json:"repos"`
+}
+
+// Request to turn dry-run mode on or off, for every review when neither an installation nor a repo is given
+type DryRunUpdate struct {
+	InstallationID int64  `json:"installation_id,omitempty"`
+	Repo           string `json:"repo,omitempty"`
+	Enabled        bool   `json:"enabled"`
+}
+
+// Request to review a pull request again at its head commit. SHA is optional, the request is refused when it
+// isn't the head, so a pull request which moved on isn't reviewed at a commit the caller didn't expect.
+type RerunRequest struct {
+	Repo       string `json:"repo"`
+	PullNumber int    `json:"pull_number"`
+	SHA        string `json:"sha,omitempty"`
+}
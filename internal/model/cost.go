@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// Operations LLM calls are made for
+const (
+	LLM_OPERATION_CODE_REVIEW      = "code_review"
+	LLM_OPERATION_RESOLUTION_CHECK = "resolution_check"
+	LLM_OPERATION_SUMMARY          = "summary"
+)
+
+// What happens to reviews once the budget of their installation or repository is exceeded
+const (
+	// Review with the fallback model
+	BUDGET_ACTION_SMALLER_MODEL = "smaller_model"
+	// Post a single summary of the pull request instead of inline comments
+	BUDGET_ACTION_SUMMARY_ONLY = "summary_only"
+	// Don't review, post a notice on the pull request
+	BUDGET_ACTION_SKIP = "skip"
+)
+
+// Price of a model in USD per million tokens
+type ModelPrice struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+	// Prompt tokens read from a context cache on the provider are billed at this price instead
+	CachedPromptPerMillion float64 `json:"cached_prompt_per_million"`
+}
+
+// A single LLM call and what it cost, RunID is 0 for calls made outside of a review run
+type LLMCall struct {
+	ID                 int64     `json:"id"`
+	RunID              int64     `json:"run_id,omitempty"`
+	InstallationID     int64     `json:"installation_id"`
+	Owner              string    `json:"owner"`
+	Repo               string    `json:"repo"`
+	Model              string    `json:"model"`
+	Operation          string    `json:"operation"`
+	PromptTokens       int       `json:"prompt_tokens"`
+	CompletionTokens   int       `json:"completion_tokens"`
+	CachedPromptTokens int       `json:"cached_prompt_tokens,omitempty"`
+	CostUSD            float64   `json:"cost_usd"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// LLM usage of a repository over a month
+type UsageSummary struct {
+	InstallationID   int64   `json:"installation_id"`
+	Owner            string  `json:"owner"`
+	Repo             string  `json:"repo"`
+	Month            string  `json:"month"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Spend of the current month against the budgets of an installation and one of its repositories,
+// a limit of 0 is unlimited
+type BudgetStatus struct {
+	InstallationSpendUSD float64 `json:"installation_spend_usd"`
+	InstallationLimitUSD float64 `json:"installation_limit_usd"`
+	RepoSpendUSD         float64 `json:"repo_spend_usd"`
+	RepoLimitUSD         float64 `json:"repo_limit_usd"`
+	Exceeded             bool    `json:"exceeded"`
+	// Action taken while the budget is exceeded
+	Action string `json:"action,omitempty"`
+}
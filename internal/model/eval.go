@@ -0,0 +1,100 @@
+package model
+
+import "time"
+
+// A recorded pull request and the findings a good review of it contains
+type EvalFixture struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Diff        string            `json:"-"`
+	Expected    []ExpectedFinding `json:"findings"`
+}
+
+// A finding a review is expected to raise, Line is the line in the new version of the file
+type ExpectedFinding struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	// Severity and Note only document the finding, matching is by location
+	Severity string `json:"severity,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// What the review pipeline was run with, so runs can be told apart when compared
+type EvalConfig struct {
+	Label         string `json:"label,omitempty"`
+	Provider      string `json:"provider"`
+	Model         string `json:"model"`
+	PromptVersion string `json:"prompt_version"`
+	// How many lines a comment may be from an expected finding and still match it
+	LineTolerance int `json:"line_tolerance"`
+}
+
+// The result of running the review pipeline over every fixture
+type EvalRun struct {
+	Config    EvalConfig          `json:"config"`
+	StartedAt time.Time           `json:"started_at"`
+	Duration  string              `json:"duration"`
+	Score     EvalScore           `json:"score"`
+	Fixtures  []EvalFixtureResult `json:"fixtures"`
+}
+
+// Scores of a single fixture, or summed over every fixture of a run
+type EvalScore struct {
+	Fixtures int `json:"fixtures"`
+	// Fixtures the pipeline failed on, they count as reviews without comments
+	Failed         int `json:"failed"`
+	Expected       int `json:"expected"`
+	Comments       int `json:"comments"`
+	TruePositives  int `json:"true_positives"`
+	FalsePositives int `json:"false_positives"`
+	FalseNegatives int `json:"false_negatives"`
+	// Matched comments on exactly the expected line
+	ExactLines int `json:"exact_lines"`
+
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	// Share of matched comments on exactly the expected line
+	LineAccuracy float64 `json:"line_accuracy"`
+	// Average distance in lines between matched comments and their findings
+	MeanLineDistance   float64 `json:"mean_line_distance"`
+	CommentsPerFixture float64 `json:"comments_per_fixture"`
+	// Comments by severity, those without one are counted as UNSPECIFIED
+	CommentsBySeverity map[string]int `json:"comments_by_severity"`
+}
+
+type EvalFixtureResult struct {
+	Name  string    `json:"name"`
+	Error string    `json:"error,omitempty"`
+	Score EvalScore `json:"score"`
+	// Expected findings no comment matched
+	Missed []ExpectedFinding `json:"missed,omitempty"`
+	// Comments matching no expected finding
+	Unexpected []ReviewCommentRequest `json:"unexpected,omitempty"`
+}
+
+// How a candidate run compares with a base run, deltas are candidate minus base
+type EvalComparison struct {
+	Base      EvalConfig         `json:"base"`
+	Candidate EvalConfig         `json:"candidate"`
+	Metrics   []EvalMetricDelta  `json:"metrics"`
+	Fixtures  []EvalFixtureDelta `json:"fixtures"`
+}
+
+type EvalMetricDelta struct {
+	Name      string  `json:"name"`
+	Base      float64 `json:"base"`
+	Candidate float64 `json:"candidate"`
+	Delta     float64 `json:"delta"`
+	// Whether a higher value is better, so reports can tell improvements from regressions
+	HigherIsBetter bool `json:"higher_is_better"`
+}
+
+// A fixture scored differently by the two runs, only fixtures in both runs are compared
+type EvalFixtureDelta struct {
+	Name                    string `json:"name"`
+	BaseTruePositives       int    `json:"base_true_positives"`
+	CandidateTruePositives  int    `json:"candidate_true_positives"`
+	BaseFalsePositives      int    `json:"base_false_positives"`
+	CandidateFalsePositives int    `json:"candidate_false_positives"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+const (
+	FEEDBACK_HELPFUL        = "helpful"
+	FEEDBACK_FALSE_POSITIVE = "false_positive"
+)
+
+// Developer reply to a bot comment which says whether the comment was useful
+type CommentFeedback struct {
+	ID            int64     `json:"id"`
+	CommentID     int64     `json:"comment_id"`
+	GithubReplyID int64     `json:"github_reply_id"`
+	Author        string    `json:"author"`
+	Kind          string    `json:"kind"`
+	Body          string    `json:"body"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// A stored comment which was posted to github, with where it was posted
+type PostedComment struct {
+	StoredComment
+	InstallationID int64  `json:"installation_id"`
+	Owner          string `json:"owner"`
+	Repo           string `json:"repo"`
+	PullNumber     int    `json:"pull_number"`
+}
+
+// Every feedback signal received for a posted comment
+type CommentFeedbackSummary struct {
+	PostedComment
+	ThumbsUp         int `json:"thumbs_up"`
+	ThumbsDown       int `json:"thumbs_down"`
+	HelpfulReplies   int `json:"helpful_replies"`
+	RejectingReplies int `json:"rejecting_replies"`
+}
+
+// IsHelpful reports whether developers agreed with the comment more than they rejected it
+func (s CommentFeedbackSummary) IsHelpful() bool {
+	return s.ThumbsUp+s.HelpfulReplies > s.ThumbsDown+s.RejectingReplies
+}
+
+// IsRejected reports whether developers rejected the comment more than they agreed with it
+func (s CommentFeedbackSummary) IsRejected() bool {
+	return s.ThumbsDown+s.RejectingReplies > s.ThumbsUp+s.HelpfulReplies
+}
+
+// Precision of the bot's comments for a repository and category (severity)
+type FeedbackStats struct {
+	Owner    string `json:"owner"`
+	Repo     string `json:"repo"`
+	Category string `json:"category"`
+	// Posted comments, including those nobody reacted to
+	Comments int `json:"comments"`
+	Helpful  int `json:"helpful"`
+	Rejected int `json:"rejected"`
+	// Helpful / (Helpful + Rejected), nil until a comment received feedback
+	Precision *float64 `json:"precision"`
+}
@@ -18,3 +18,43 @@ type PRFile struct {
 	Changes   int    `json:"changes"`
 	Patch     string `json:"patch"`
 }
+
+// Review thread on a pull request, as returned by the GraphQL API
+// Sides of a diff review threads are left on, github sends LEFT for removed lines of the base and RIGHT otherwise
+const (
+	DIFF_SIDE_LEFT  = "LEFT"
+	DIFF_SIDE_RIGHT = "RIGHT"
+)
+
+type ReviewThread struct {
+	ID                string `json:"id"`
+	IsResolved        bool   `json:"is_resolved"`
+	IsOutdated        bool   `json:"is_outdated"`
+	Path              string `json:"path"`
+	Line              int    `json:"line"`
+	StartLine         int    `json:"start_line"`
+	OriginalLine      int    `json:"original_line"`
+	OriginalStartLine int    `json:"original_start_line"`
+	// Side of the diff the thread is on, DIFF_SIDE_RIGHT for lines of the head commit
+	Side string `json:"side"`
+
+	// First comment of the thread, which is the one raising the issue
+	CommentID   int64  `json:"comment_id"`
+	Body        string `json:"body"`
+	DiffHunk    string `json:"diff_hunk"`
+	AuthorLogin string `json:"author_login"`
+}
+
+// LLM verdict on whether a previously raised issue has been addressed
+type IssueResolution struct {
+	Resolved bool   `json:"resolved"`
+	Reason   string `json:"reason"`
+}
+
+// Secret found on a line added by a pull request, the secret itself is never kept
+type SecretFinding struct {
+	Path        string `json:"path"`
+	Line        int    `json:"line"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+}
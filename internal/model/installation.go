@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	INSTALLATION_STATUS_ACTIVE    = "active"
+	INSTALLATION_STATUS_SUSPENDED = "suspended"
+	INSTALLATION_STATUS_DELETED   = "deleted"
+)
+
+// An installation of the github app on an account, and the bot's settings for it
+type Installation struct {
+	ID                  int64      `json:"id"`
+	Account             string     `json:"account"`
+	AccountType         string     `json:"account_type"`
+	RepositorySelection string     `json:"repository_selection"`
+	Status              string     `json:"status"`
+	Repositories        []string   `json:"repositories"`
+	InstalledAt         time.Time  `json:"installed_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	SuspendedAt         *time.Time `json:"suspended_at,omitempty"`
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`
+	DryRun              bool       `json:"dry_run"`
+	DryRunRepos         []string   `json:"dry_run_repos,omitempty"`
+}
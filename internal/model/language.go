@@ -0,0 +1,13 @@
+package model
+
+// LanguageProfile adds the checks specific to a language to the review of the files written in it
+type LanguageProfile struct {
+	Name string `yaml:"name" json:"name"`
+	// How files are recognised: extensions with the dot, exact file names and the interpreters of shebang lines
+	Extensions   []string `yaml:"extensions" json:"extensions,omitempty"`
+	Filenames    []string `yaml:"filenames" json:"filenames,omitempty"`
+	Interpreters []string `yaml:"interpreters" json:"interpreters,omitempty"`
+	// Points the review of the language should check, and rules added to the repository rules
+	Checklist []string `yaml:"checklist" json:"checklist,omitempty"`
+	Rules     string   `yaml:"rules" json:"rules,omitempty"`
+}
@@ -0,0 +1,37 @@
+package model
+
+// Additional context for the review prompt
+type PromptContext struct {
+	// Version of the embedded prompt templates, the default version when empty
+	PromptVersion string
+	// Templates and variables the repository replaces the defaults with
+	PromptOverrides PromptOverrides
+
+	// owner/repo and the description of the repository on github
+	Repository            string
+	RepositoryDescription string
+	// Written by the author of the pull request, so sent to the LLM as untrusted content
+	PullRequestTitle string
+	PullRequestBody  string
+	// Language of the code under review and what its profile adds to the review, empty when it is unknown
+	Language          string
+	LanguageChecklist []string
+	LanguageRules     string
+	// Repository rules which apply to the files under review, rendered as text
+	Rules string
+
+	// Comments developers rejected on this repository before, which the LLM should not repeat
+	RejectedPatterns []string
+}
+
+// PromptOverrides are read from the repository, prompt templates by name (review, summary or resolution)
+// and the variables of config.yml templates can read as .Config
+type PromptOverrides struct {
+	Templates map[string]string
+	Config    map[string]string
+}
+
+// Empty reports whether the repository overrides nothing
+func (p PromptOverrides) Empty() bool {
+	return len(p.Templates) == 0 && len(p.Config) == 0
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	READINESS_CHECK_LLM     = "llm"
+	READINESS_CHECK_GITHUB  = "github"
+	READINESS_CHECK_STORAGE = "storage"
+	READINESS_CHECK_QUEUE   = "queue"
+)
+
+// The outcome of checking a single dependency
+type ReadinessCheck struct {
+	Name       string `json:"name"`
+	Ready      bool   `json:"ready"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// Checks of remote dependencies are reused for a while, so probes don't call them every time
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Whether the service can take reviews, ready only when every check passes
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
@@ -0,0 +1,79 @@
+package model
+
+import "time"
+
+const (
+	REVIEW_STATUS_RUNNING   = "running"
+	REVIEW_STATUS_SUCCEEDED = "succeeded"
+	REVIEW_STATUS_FAILED    = "failed"
+)
+
+const (
+	COMMENT_STATUS_POSTED  = "posted"
+	COMMENT_STATUS_DRY_RUN = "dry_run"
+	COMMENT_STATUS_FAILED  = "failed"
+)
+
+// Tokens used by LLM calls
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// Prompt tokens read from a context cache on the provider, they are part of PromptTokens
+	CachedPromptTokens int `json:"cached_prompt_tokens,omitempty"`
+}
+
+func (t *TokenUsage) Add(usage TokenUsage) {
+	t.PromptTokens += usage.PromptTokens
+	t.CompletionTokens += usage.CompletionTokens
+	t.TotalTokens += usage.TotalTokens
+	t.CachedPromptTokens += usage.CachedPromptTokens
+}
+
+// A single review of a pull request at a given head commit
+type ReviewRun struct {
+	ID             int64      `json:"id"`
+	InstallationID int64      `json:"installation_id"`
+	Owner          string     `json:"owner"`
+	Repo           string     `json:"repo"`
+	PullNumber     int        `json:"pull_number"`
+	HeadSHA        string     `json:"head_sha"`
+	Trigger        string     `json:"trigger"`
+	Model          string     `json:"model"`
+	PromptVersion  string     `json:"prompt_version"`
+	DryRun         bool       `json:"dry_run"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	Usage          TokenUsage `json:"usage"`
+	DurationMs     int64      `json:"duration_ms"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// A comment generated during a review run
+type StoredComment struct {
+	ID              int64     `json:"id"`
+	RunID           int64     `json:"run_id"`
+	Path            string    `json:"path"`
+	Line            int       `json:"line"`
+	Body            string    `json:"body"`
+	Severity        string    `json:"severity,omitempty"`
+	Status          string    `json:"status"`
+	GithubCommentID int64     `json:"github_comment_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Filters for listing review runs, zero values are ignored
+type ReviewRunFilter struct {
+	Owner  string
+	Repo   string
+	DryRun *bool
+	Status string
+	Limit  int
+}
+
+// A review run together with the comments it generated
+type ReviewRunDetail struct {
+	ReviewRun
+	Comments []StoredComment `json:"comments"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// CachedReview is what the LLM said about a chunk of a diff, kept so an identical chunk isn't sent to the LLM again
+type CachedReview struct {
+	Model         string                `json:"model"`
+	PromptVersion string                `json:"prompt_version"`
+	Comments      []CachedReviewComment `json:"comments"`
+	CreatedAt     time.Time             `json:"created_at"`
+}
+
+// CachedReviewComment remembers the line a comment was made on by its place in the patch of the file and its
+// content, so the comment can be moved to where the line is in the current diff, or dropped when it isn't there
+type CachedReviewComment struct {
+	Path        string `json:"path"`
+	PatchIndex  int    `json:"patch_index"`
+	Content     string `json:"content"`
+	Body        string `json:"body"`
+	SubjectType string `json:"subject_type,omitempty"`
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// CostRepository records every LLM call with its cost, so spend can be checked against budgets.
+// SQLReviewRepository implements it next to the review history.
+type CostRepository interface {
+	RecordLLMCall(ctx context.Context, call *model.LLMCall) error
+	// SpentSince sums the cost of calls made for the installation, and for owner/repo, since the given time
+	SpentSince(ctx context.Context, installationID int64, owner, repo string, since time.Time) (installationSpend float64, repoSpend float64, err error)
+	// ListUsage sums calls made between since and until per installation and repository
+	ListUsage(ctx context.Context, since time.Time, until time.Time) ([]model.UsageSummary, error)
+}
+
+func (s *SQLReviewRepository) RecordLLMCall(ctx context.Context, call *model.LLMCall) error {
+	row := s.db.QueryRowContext(ctx, s.rebind(`INSERT INTO llm_calls
+		(run_id, installation_id, owner, repo, model, operation, prompt_tokens, completion_tokens, cost_usd, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		call.RunID, call.InstallationID, call.Owner, call.Repo, call.Model, call.Operation, call.PromptTokens,
+		call.CompletionTokens, call.CostUSD, call.CreatedAt.UTC(),
+	)
+
+	if err := row.Scan(&call.ID); err != nil {
+		return fmt.Errorf("failed to record llm call: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) SpentSince(ctx context.Context, installationID int64, owner, repo string, since time.Time) (float64, float64, error) {
+	var installationSpend, repoSpend float64
+
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT
+			COALESCE(SUM(cost_usd), 0),
+			COALESCE(SUM(CASE WHEN owner = ? AND repo = ? THEN cost_usd ELSE 0 END), 0)
+		FROM llm_calls WHERE installation_id = ? AND created_at >= ?`),
+		owner, repo, installationID, since.UTC(),
+	).Scan(&installationSpend, &repoSpend)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum llm costs: %w", err)
+	}
+
+	return installationSpend, repoSpend, nil
+}
+
+func (s *SQLReviewRepository) ListUsage(ctx context.Context, since time.Time, until time.Time) ([]model.UsageSummary, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT installation_id, owner, repo, COUNT(*),
+			COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM llm_calls WHERE created_at >= ? AND created_at < ?
+		GROUP BY installation_id, owner, repo
+		ORDER BY installation_id, owner, repo`),
+		since.UTC(), until.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list llm usage: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []model.UsageSummary{}
+	for rows.Next() {
+		var summary model.UsageSummary
+		err := rows.Scan(&summary.InstallationID, &summary.Owner, &summary.Repo, &summary.Calls,
+			&summary.PromptTokens, &summary.CompletionTokens, &summary.CostUSD)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan llm usage: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+const FAKE_LLM_MODEL = "fake"
+
+// fakeLLMRule flags added lines matching the pattern with the comment
+type fakeLLMRule struct {
+	pattern *regexp.Regexp
+	comment string
+}
+
+// Checks the fake model knows about, the first matching rule comments on a line
+var fakeLLMRules = []fakeLLMRule{
+	{
+		pattern: regexp.MustCompile(`(?i)["'` + "`" + `]\s*(?:SELECT|INSERT|UPDATE|DELETE)\b.*["'` + "`" + `]\s*\+|\b(?:SELECT|INSERT|UPDATE|DELETE)\b.*%[sv]`),
+		comment: "BLOCKING: The query is built by concatenating input, which allows SQL injection. Pass the values as query parameters instead.",
+	},
+	{
+		pattern: regexp.MustCompile(`exec\.Command\(\s*"(?:ba)?sh"\s*,\s*"-c"|shell\s*=\s*True|os\.system\(|child_process\.exec\(`),
+		comment: "BLOCKING: Running input through a shell allows command injection. Pass the arguments to the program directly.",
+	},
+	{
+		pattern: regexp.MustCompile(`\beval\(`),
+		comment: "BLOCKING: eval runs whatever it is given, never pass it data which could come from a user.",
+	},
+	{
+		pattern: regexp.MustCompile(`InsecureSkipVerify:\s*true|verify\s*=\s*False|rejectUnauthorized:\s*false`),
+		comment: "BLOCKING: Disabling certificate verification allows anyone on the network to intercept the connection.",
+	},
+	{
+		pattern: regexp.MustCompile(`^\s*_\s*(?:,\s*_\s*)?=\s*[\w.]+\(`),
+		comment: "IMPORTANT: The error is discarded, so failures go unnoticed. Handle it or return it to the caller.",
+	},
+	{
+		pattern: regexp.MustCompile(`\bpanic\(`),
+		comment: "IMPORTANT: Panicking takes the whole process down, return an error instead.",
+	},
+	{
+		pattern: regexp.MustCompile(`\bfmt\.Print(?:ln|f)?\(|\bconsole\.log\(`),
+		comment: "NIT: This looks like leftover debug output, use the logger or remove it.",
+	},
+	{
+		pattern: regexp.MustCompile(`\b(?:TODO|FIXME)\b`),
+		comment: "QUESTION: Is there an issue tracking this TODO, or should it be done before merging?",
+	},
+}
+
+var fakeDiffLinePattern = regexp.MustCompile(`^\[` + utils.LINE_MARKER_PREFIX + `(\d+)\] ([+ ])(.*)$`)
+
+// FakeLLMRepository reviews diffs with a handful of regular expressions instead of a model. Its output only
+// depends on its input, so the review pipeline can be evaluated and tested without network access.
+type FakeLLMRepository struct{}
+
+func NewFakeLLMRepository() *FakeLLMRepository {
+	return &FakeLLMRepository{}
+}
+
+func (f *FakeLLMRepository) Model() string {
+	return FAKE_LLM_MODEL
+}
+
+func (f *FakeLLMRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// GetCodeReviews comments on the added lines of the formatted diff matching a rule
+func (f *FakeLLMRepository) GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) ([]model.ReviewCommentRequest, model.TokenUsage, error) {
+	var reviewComments []model.ReviewCommentRequest
+
+	// Comments go on the line number of the marker, like a model following the prompt
+	path := ""
+	for _, line := range strings.Split(code, "\n") {
+		if trimmed := strings.TrimLeft(line, "\t "); strings.HasPrefix(trimmed, "FILE: ") {
+			path = strings.TrimPrefix(trimmed, "FILE: ")
+			continue
+		}
+
+		matches := fakeDiffLinePattern.FindStringSubmatch(line)
+		if matches == nil || matches[2] != "+" {
+			continue
+		}
+		if comment := fakeComment(matches[3]); comment != "" {
+			lineNumber, _ := strconv.Atoi(matches[1])
+			reviewComments = append(reviewComments, model.ReviewCommentRequest{Body: comment, Path: path, Line: lineNumber})
+		}
+	}
+
+	return reviewComments, fakeUsage(code, len(reviewComments)), nil
+}
+
+// CheckIssueResolved treats the issue as resolved once no rule matches the updated code
+func (f *FakeLLMRepository) CheckIssueResolved(ctx context.Context, comment string, originalHunk string, updatedCode string) (*model.IssueResolution, model.TokenUsage, error) {
+	usage := fakeUsage(comment+originalHunk+updatedCode, 1)
+	for _, line := range strings.Split(updatedCode, "\n") {
+		if fakeComment(line) != "" {
+			return &model.IssueResolution{Resolved: false, Reason: "The updated code still contains the issue."}, usage, nil
+		}
+	}
+
+	return &model.IssueResolution{Resolved: true, Reason: "The updated code no longer contains the issue."}, usage, nil
+}
+
+// Summarize lists the files of the formatted diff
+func (f *FakeLLMRepository) Summarize(ctx context.Context, code string, promptCtx model.PromptContext) (string, model.TokenUsage, error) {
+	var files []string
+	for _, line := range strings.Split(code, "\n") {
+		if trimmed := strings.TrimLeft(line, "\t "); strings.HasPrefix(trimmed, "FILE: ") {
+			files = append(files, "- `"+strings.TrimPrefix(trimmed, "FILE: ")+"`")
+		}
+	}
+
+	summary := fmt.Sprintf("This pull request changes %d file(s).\n\n%s", len(files), strings.Join(files, "\n"))
+	return summary, fakeUsage(code, 1), nil
+}
+
+func fakeComment(line string) string {
+	for _, rule := range fakeLLMRules {
+		if rule.pattern.MatchString(line) {
+			return rule.comment
+		}
+	}
+	return ""
+}
+
+// fakeUsage estimates tokens as a quarter of the characters, so budgets and costs can be tested too
+func fakeUsage(prompt string, completions int) model.TokenUsage {
+	promptTokens, completionTokens := len(prompt)/4, completions*40
+	return model.TokenUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// FeedbackRepository stores developer feedback (reactions and replies) against posted comments.
+// SQLReviewRepository implements it next to the review history.
+type FeedbackRepository interface {
+	GetCommentByGithubID(ctx context.Context, githubCommentID int64) (*model.StoredComment, error)
+	AddReplyFeedback(ctx context.Context, feedback *model.CommentFeedback) error
+	UpdateReactions(ctx context.Context, commentID int64, thumbsUp int, thumbsDown int) error
+	ListPostedComments(ctx context.Context, since time.Time) ([]model.PostedComment, error)
+	ListFeedbackSummaries(ctx context.Context, owner string, repo string) ([]model.CommentFeedbackSummary, error)
+}
+
+func (s *SQLReviewRepository) GetCommentByGithubID(ctx context.Context, githubCommentID int64) (*model.StoredComment, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+storedCommentColumns+` FROM review_comments WHERE github_comment_id = ? ORDER BY id DESC LIMIT 1`), githubCommentID)
+
+	comment, err := scanStoredComment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// AddReplyFeedback stores a reply, replies which were already stored are ignored
+func (s *SQLReviewRepository) AddReplyFeedback(ctx context.Context, feedback *model.CommentFeedback) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO comment_feedback
+		(comment_id, github_reply_id, author, kind, body, created_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (github_reply_id) DO NOTHING`),
+		feedback.CommentID, feedback.GithubReplyID, feedback.Author, feedback.Kind, feedback.Body, feedback.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add comment feedback: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) UpdateReactions(ctx context.Context, commentID int64, thumbsUp int, thumbsDown int) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE review_comments SET thumbs_up = ?, thumbs_down = ? WHERE id = ?`),
+		thumbsUp, thumbsDown, commentID)
+	if err != nil {
+		return fmt.Errorf("failed to update comment reactions: %w", err)
+	}
+
+	return nil
+}
+
+const postedCommentColumns = `c.id, c.run_id, c.path, c.line, c.body, c.severity, c.status, c.github_comment_id, c.created_at,
+	r.installation_id, r.owner, r.repo, r.pull_number`
+
+// ListPostedComments returns the comments posted to github since the given time
+func (s *SQLReviewRepository) ListPostedComments(ctx context.Context, since time.Time) ([]model.PostedComment, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+postedCommentColumns+`
+		FROM review_comments c JOIN review_runs r ON r.id = c.run_id
+		WHERE c.status = ? AND c.github_comment_id != 0 AND c.created_at >= ?
+		ORDER BY c.id`), model.COMMENT_STATUS_POSTED, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posted comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []model.PostedComment{}
+	for rows.Next() {
+		var comment model.PostedComment
+		err := rows.Scan(&comment.ID, &comment.RunID, &comment.Path, &comment.Line, &comment.Body, &comment.Severity,
+			&comment.Status, &comment.GithubCommentID, &comment.CreatedAt,
+			&comment.InstallationID, &comment.Owner, &comment.Repo, &comment.PullNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan posted comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// ListFeedbackSummaries returns every posted comment with its feedback, owner and repo are optional filters
+func (s *SQLReviewRepository) ListFeedbackSummaries(ctx context.Context, owner string, repo string) ([]model.CommentFeedbackSummary, error) {
+	conditions := []string{"c.status = ?"}
+	args := []any{
+		model.FEEDBACK_HELPFUL,
+		model.FEEDBACK_FALSE_POSITIVE,
+		model.COMMENT_STATUS_POSTED,
+	}
+	if owner != "" {
+		conditions = append(conditions, "r.owner = ?")
+		args = append(args, owner)
+	}
+	if repo != "" {
+		conditions = append(conditions, "r.repo = ?")
+		args = append(args, repo)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+postedCommentColumns+`, c.thumbs_up, c.thumbs_down,
+		COALESCE(SUM(CASE WHEN f.kind = ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN f.kind = ? THEN 1 ELSE 0 END), 0)
+		FROM review_comments c
+		JOIN review_runs r ON r.id = c.run_id
+		LEFT JOIN comment_feedback f ON f.comment_id = c.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY `+postedCommentColumns+`, c.thumbs_up, c.thumbs_down
+		ORDER BY c.id`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comment feedback: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []model.CommentFeedbackSummary{}
+	for rows.Next() {
+		var summary model.CommentFeedbackSummary
+		err := rows.Scan(&summary.ID, &summary.RunID, &summary.Path, &summary.Line, &summary.Body, &summary.Severity,
+			&summary.Status, &summary.GithubCommentID, &summary.CreatedAt,
+			&summary.InstallationID, &summary.Owner, &summary.Repo, &summary.PullNumber,
+			&summary.ThumbsUp, &summary.ThumbsDown, &summary.HelpfulReplies, &summary.RejectingReplies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment feedback: %w", err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
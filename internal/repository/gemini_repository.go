@@ -3,47 +3,91 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
 	"time"
 
 	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
 	"google.golang.org/genai"
 )
 
+const DEFAULT_GEMINI_MODEL = "gemini-2.0-flash"
+
+// Cheaper model reviews fall back to once a budget is exceeded
+const DEFAULT_GEMINI_FALLBACK_MODEL = "gemini-2.0-flash-lite"
+
+// Name review contexts are cached under on gemini, so they can be told apart in the caches of the project
+const GEMINI_REVIEW_CONTEXT_DISPLAY_NAME = "ai-pr-reviewer review context"
+
+// Statuses gemini answers with when a cached context can't be used, expired or deleted before it did here
+var geminiCachedContextStatuses = []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}
+
 type GeminiRepository struct {
 	client *genai.Client
+	model  string
 	ctx    context.Context
+	// Review contexts cached on gemini, nil when context caching is disabled
+	contexts *reviewContexts
 }
 
-func NewGeminiRepository(client *genai.Client, ctx context.Context) *GeminiRepository {
+// NewGeminiRepository reviews with the model, caching review contexts on gemini for contextTTL, 0 disables caching
+func NewGeminiRepository(client *genai.Client, model string, contextTTL time.Duration, ctx context.Context) *GeminiRepository {
+	if model == "" {
+		model = DEFAULT_GEMINI_MODEL
+	}
+
 	return &GeminiRepository{
-		client: client,
-		ctx:    ctx,
+		client:   client,
+		model:    model,
+		ctx:      ctx,
+		contexts: newReviewContexts(contextTTL),
 	}
 }
 
-func (g *GeminiRepository) GetCodeReviews(code string) ([]model.ReviewCommentRequest, error) {
-	// Create a context with a longer timeout for LLM processing
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// Model returns the name of the gemini model used for reviews
+func (g *GeminiRepository) Model() string {
+	return g.model
+}
 
-	parts := []*genai.Part{
-		{Text: code},
+// Ping checks the API key is accepted and the review model exists
+func (g *GeminiRepository) Ping(ctx context.Context) error {
+	_, err := g.client.Models.Get(ctx, g.model, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get gemini model %s: %w", g.model, err)
 	}
 
-	content := []*genai.Content{
-		{Parts: parts},
-	}
+	return nil
+}
 
-	// Get technical requirements
-	fileContent, err := utils.ReadRepositoryRuleFile("main.md")
-	if err != nil {
-		return nil, err
-	}
+func (g *GeminiRepository) GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) (reviewComments []model.ReviewCommentRequest, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "GeminiRepository.GetCodeReviews", tracing.ModelKey.String(g.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	// Create a context with a longer timeout for LLM processing
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
 
-	// Generate system prompt
-	systemPrompt := utils.GenerateCodeReviewPrompt(fileContent)
+	// Under a cached review context only the diff and what was written about the pull request are sent,
+	// otherwise the whole prompt is. Untrusted content is wrapped in new tags it can't guess either way.
+	reviewCtx, cached := g.contexts.get(promptCtx)
+	cached = cached && reviewCtx.name != ""
+	var systemPrompt, userPrompt string
+	if cached {
+		userPrompt = utils.GenerateReviewRequest(promptCtx, code)
+	} else {
+		systemPrompt, userPrompt, err = geminiReviewPrompt(promptCtx, code)
+		if err != nil {
+			return nil, model.TokenUsage{}, err
+		}
+	}
 
 	// Setup response schema for structured output
 	responseSchema := &genai.Schema{
@@ -65,56 +109,148 @@ func (g *GeminiRepository) GetCodeReviews(code string) ([]model.ReviewCommentReq
 				},
 				"line": {
 					Type:        genai.TypeInteger,
-					Description: "The line of the blob in the pull request diff that the comment applies to. For a multi-line comment, the last line of the range that your comment applies to.",
+					Description: "The number N of the [LINE:N] marker of the line the comment applies to, its line number in the new version of the file. For a multi-line comment, the last line of the range that your comment applies to.",
 				},
 			},
 			Required: []string{"body", "commit_id", "path", "line"},
 		},
 	}
 
-	// Setup the configuration
+	// Setup the configuration, a cached context replaces the system instruction
 	cfg := &genai.GenerateContentConfig{
-		SystemInstruction: &genai.Content{
-			Role: "system",
-			Parts: []*genai.Part{
-				{
-					Text: systemPrompt,
-				},
-			},
-		},
 		ResponseMIMEType: "application/json",
 		ResponseSchema:   responseSchema,
 	}
+	if cached {
+		cfg.CachedContent = reviewCtx.name
+	} else {
+		cfg.SystemInstruction = geminiSystemInstruction(systemPrompt)
+	}
 
-	result, err := g.client.Models.GenerateContent(
-		ctx,
-		"gemini-2.0-flash",
-		content,
-		cfg,
-	)
+	result, err := g.client.Models.GenerateContent(ctx, g.model, geminiUserContent(userPrompt), cfg)
+	var apiErr genai.APIError
+	if err != nil && cached && errors.As(err, &apiErr) && slices.Contains(geminiCachedContextStatuses, apiErr.Code) {
+		slog.Warn("cached review context can't be used, sending it with the diff", "error", err, "model", g.model)
+		g.contexts.forget(promptCtx)
+
+		systemPrompt, userPrompt, err = geminiReviewPrompt(promptCtx, code)
+		if err != nil {
+			return nil, model.TokenUsage{}, err
+		}
+		cfg.CachedContent = ""
+		cfg.SystemInstruction = geminiSystemInstruction(systemPrompt)
+		result, err = g.client.Models.GenerateContent(ctx, g.model, geminiUserContent(userPrompt), cfg)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+		return nil, model.TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	usage = geminiUsage(result)
+
 	// Extract and validate the response
 	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response generated")
+		return nil, usage, fmt.Errorf("no response generated")
 	}
 
 	// Parse the JSON response
 	responseText := result.Candidates[0].Content.Parts[0].Text
-	var reviewComments []model.ReviewCommentRequest
 
 	if err := json.Unmarshal([]byte(responseText), &reviewComments); err != nil {
-		return nil, fmt.Errorf("failed to parse response JSON: %w", err)
+		return nil, usage, fmt.Errorf("failed to parse response JSON: %w", err)
 	}
 
 	// Validate the response
 	if err := validateReviewComments(reviewComments); err != nil {
-		return nil, fmt.Errorf("invalid response format: %w", err)
+		return nil, usage, fmt.Errorf("invalid response format: %w", err)
+	}
+
+	return reviewComments, usage, nil
+}
+
+// CacheReviewContext caches the review prompt shared by the pull requests of the repository on gemini, so chunks
+// only send their diff and what was written about their pull request. Contexts replaced in a full scope are deleted.
+// Gemini refuses to cache prompts below a minimum number of tokens, the prompt is then sent with every chunk, and
+// caching it isn't tried again until the context expires.
+func (g *GeminiRepository) CacheReviewContext(ctx context.Context, promptCtx model.PromptContext) (err error) {
+	if g.contexts == nil {
+		return nil
+	}
+
+	ctx, span := tracing.Start(ctx, "GeminiRepository.CacheReviewContext", tracing.ModelKey.String(g.model))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if reviewCtx, found := g.contexts.get(promptCtx); found {
+		// Contexts in use are extended once half their life is gone, so they don't expire between two chunks
+		if reviewCtx.name == "" || time.Until(reviewCtx.expiresAt) > g.contexts.ttl/2 {
+			return nil
+		}
+		if _, err := g.client.Caches.Update(ctx, reviewCtx.name, &genai.UpdateCachedContentConfig{TTL: g.contexts.ttl}); err != nil {
+			return fmt.Errorf("failed to extend cached review context: %w", err)
+		}
+		reviewCtx.expiresAt = time.Now().Add(g.contexts.ttl)
+		g.contexts.put(promptCtx, reviewCtx)
+		return nil
+	}
+
+	// Expires here before it does on gemini, as it is created after
+	reviewCtx, err := newReviewContext(promptCtx, g.contexts.ttl)
+	if err != nil {
+		return err
+	}
+
+	cachedContent, err := g.client.Caches.Create(ctx, g.model, &genai.CreateCachedContentConfig{
+		TTL:               g.contexts.ttl,
+		DisplayName:       GEMINI_REVIEW_CONTEXT_DISPLAY_NAME,
+		SystemInstruction: geminiSystemInstruction(reviewCtx.systemPrompt),
+	})
+	if err != nil {
+		g.deleteReviewContexts(ctx, g.contexts.put(promptCtx, reviewCtx))
+		return fmt.Errorf("failed to cache review context: %w", err)
+	}
+
+	reviewCtx.name = cachedContent.Name
+	g.deleteReviewContexts(ctx, g.contexts.put(promptCtx, reviewCtx))
+	return nil
+}
+
+// deleteReviewContexts deletes replaced contexts from gemini, instead of paying for them until they expire
+func (g *GeminiRepository) deleteReviewContexts(ctx context.Context, replaced []reviewContext) {
+	for _, reviewCtx := range replaced {
+		if reviewCtx.name == "" {
+			continue
+		}
+		if _, err := g.client.Caches.Delete(ctx, reviewCtx.name, nil); err != nil {
+			slog.Warn("failed to delete replaced review context", "error", err, "model", g.model, "name", reviewCtx.name)
+		}
 	}
+}
 
-	return reviewComments, nil
+// geminiReviewPrompt renders the whole review prompt of a chunk sent without a cached context,
+// the diff goes in the user content wrapped in a new untrusted tag
+func geminiReviewPrompt(promptCtx model.PromptContext, code string) (string, string, error) {
+	untrustedTag := utils.NewUntrustedTag("diff")
+	systemPrompt, err := utils.GenerateCodeReviewPrompt(promptCtx.Rules, promptCtx, untrustedTag)
+	if err != nil {
+		return "", "", err
+	}
+	return systemPrompt, utils.WrapUntrusted(untrustedTag, code), nil
+}
+
+// geminiUserContent sends the prompt as the content of a request
+func geminiUserContent(prompt string) []*genai.Content {
+	return []*genai.Content{
+		{Parts: []*genai.Part{{Text: prompt}}},
+	}
+}
+
+// geminiSystemInstruction sends the system prompt as the instructions of a request or cached context
+func geminiSystemInstruction(systemPrompt string) *genai.Content {
+	return &genai.Content{
+		Role:  "system",
+		Parts: []*genai.Part{{Text: systemPrompt}},
+	}
 }
 
 // validateReviewComments validates the structure and content of review comments
@@ -135,3 +271,128 @@ func validateReviewComments(comments []model.ReviewCommentRequest) error {
 	}
 	return nil
 }
+
+// CheckIssueResolved asks the LLM whether a previous review comment is addressed by the updated code
+func (g *GeminiRepository) CheckIssueResolved(ctx context.Context, comment string, originalHunk string, updatedCode string) (resolution *model.IssueResolution, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "GeminiRepository.CheckIssueResolved", tracing.ModelKey.String(g.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	prompt, err := utils.GenerateResolutionCheckPrompt(comment, originalHunk, updatedCode)
+	if err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+
+	content := []*genai.Content{
+		{Parts: []*genai.Part{{Text: prompt}}},
+	}
+
+	responseSchema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"resolved": {
+				Type:        genai.TypeBoolean,
+				Description: "Whether the issue raised in the review comment has been addressed",
+			},
+			"reason": {
+				Type:        genai.TypeString,
+				Description: "One sentence explaining the decision",
+			},
+		},
+		Required: []string{"resolved", "reason"},
+	}
+
+	cfg := &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   responseSchema,
+	}
+
+	result, err := g.client.Models.GenerateContent(
+		ctx,
+		g.model,
+		content,
+		cfg,
+	)
+	if err != nil {
+		return nil, model.TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	usage = geminiUsage(result)
+
+	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
+		return nil, usage, fmt.Errorf("no response generated")
+	}
+
+	resolution = &model.IssueResolution{}
+	if err := json.Unmarshal([]byte(result.Candidates[0].Content.Parts[0].Text), resolution); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse response JSON: %w", err)
+	}
+
+	return resolution, usage, nil
+}
+
+// Summarize writes a short summary of the formatted diff, used instead of a full review once a budget is exceeded
+func (g *GeminiRepository) Summarize(ctx context.Context, code string, promptCtx model.PromptContext) (summary string, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "GeminiRepository.Summarize", tracing.ModelKey.String(g.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	untrustedTag := utils.NewUntrustedTag("diff")
+	systemPrompt, err := utils.GenerateSummaryPrompt(promptCtx, untrustedTag)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	content := []*genai.Content{
+		{Parts: []*genai.Part{{Text: utils.WrapUntrusted(untrustedTag, code)}}},
+	}
+
+	cfg := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{
+			Role:  "system",
+			Parts: []*genai.Part{{Text: systemPrompt}},
+		},
+	}
+
+	result, err := g.client.Models.GenerateContent(
+		ctx,
+		g.model,
+		content,
+		cfg,
+	)
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	usage = geminiUsage(result)
+
+	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", usage, fmt.Errorf("no response generated")
+	}
+
+	return result.Candidates[0].Content.Parts[0].Text, usage, nil
+}
+
+// geminiUsage extracts the token counts of a response, the usage metadata is missing on some errors
+func geminiUsage(result *genai.GenerateContentResponse) model.TokenUsage {
+	if result == nil || result.UsageMetadata == nil {
+		return model.TokenUsage{}
+	}
+
+	return model.TokenUsage{
+		PromptTokens:     int(result.UsageMetadata.PromptTokenCount),
+		CompletionTokens: int(result.UsageMetadata.CandidatesTokenCount),
+		TotalTokens:      int(result.UsageMetadata.TotalTokenCount),
+
+		CachedPromptTokens: int(result.UsageMetadata.CachedContentTokenCount),
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GitRepository reads diffs from a local git checkout
+type GitRepository struct {
+	Dir string
+}
+
+func NewGitRepository(dir string) *GitRepository {
+	return &GitRepository{
+		Dir: dir,
+	}
+}
+
+// Diff returns the output of git diff for the given arguments, such as a range like main...HEAD or --staged
+func (r *GitRepository) Diff(ctx context.Context, args ...string) (string, error) {
+	gitArgs := append([]string{"-C", r.Dir, "diff", "--no-color", "--no-ext-diff"}, args...)
+
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git diff failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -2,45 +2,531 @@ package repository
 
 import (
 	"context"
-	"crypto/rsa"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
 	"github.com/google/go-github/v74/github"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/oauth2"
 )
 
-type GithubRepository struct {
-	WebhookSecret string
-	PrivateKey    *rsa.PrivateKey
+// Where the github REST API is served, github enterprise servers and tests point it elsewhere
+const DEFAULT_GITHUB_API_URL = "https://api.github.com/"
+
+// GithubRepository is everything the reviewer does on github. Clients are created with NewClient, authenticated
+// either as the app (a JWT) or as one of its installations.
+type GithubRepository interface {
+	// NewClient creates a client authenticated with the token
+	NewClient(token string) *github.Client
+	CreateInstallationToken(ctx context.Context, jwtClient *github.Client, installationID int64) (string, error)
+
+	CreateReviewComments(ctx context.Context, client *github.Client, owner string, repo string, pullNumber int, comment *github.PullRequestComment) (*github.PullRequestComment, error)
+	ListPullRequestFiles(ctx context.Context, client *github.Client, owner, repo string, pullNumber int, pageNumber int) ([]*github.CommitFile, error)
+	ListReviewComments(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) ([]*github.PullRequestComment, error)
+	ReplyToReviewComment(ctx context.Context, client *github.Client, owner, repo string, pullNumber int, commentID int64, body string) error
+	CompareCommits(ctx context.Context, client *github.Client, owner, repo, base, head string) ([]*github.CommitFile, error)
+	GetFileContent(ctx context.Context, client *github.Client, owner, repo, path, ref string) (string, bool, error)
+	GetPullRequest(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) (*github.PullRequest, error)
+	ListReviewThreads(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) ([]model.ReviewThread, error)
+	ResolveReviewThread(ctx context.Context, client *github.Client, threadID string) error
+
+	CreateIssue(ctx context.Context, client *github.Client, owner, repo string, title string, body string) (*github.Issue, error)
+	CreateIssueComment(ctx context.Context, client *github.Client, owner, repo string, number int, body string) (*github.IssueComment, error)
+
+	GetAppSlug(ctx context.Context, jwtClient *github.Client) (string, error)
+	FindRepositoryInstallation(ctx context.Context, jwtClient *github.Client, owner, repo string) (*github.Installation, error)
+	ListInstallations(ctx context.Context, jwtClient *github.Client) ([]*github.Installation, error)
+	ListInstallationRepositories(ctx context.Context, client *github.Client) ([]*github.Repository, error)
 }
 
-func NewGithubRepository(webhookSecret string, privateKey *rsa.PrivateKey) *GithubRepository {
-	return &GithubRepository{
+// GithubAPIRepository talks to the github REST and GraphQL APIs
+type GithubAPIRepository struct {
+	WebhookSecret *secrets.Secret
+	PrivateKey    *secrets.Secret
+	baseURL       *url.URL
+}
+
+// NewGithubAPIRepository sends requests to apiURL, DEFAULT_GITHUB_API_URL when it is empty
+func NewGithubAPIRepository(webhookSecret *secrets.Secret, privateKey *secrets.Secret, apiURL string) (*GithubAPIRepository, error) {
+	if apiURL == "" {
+		apiURL = DEFAULT_GITHUB_API_URL
+	}
+	// The client resolves paths against the base URL, which only works with a trailing slash
+	baseURL, err := url.Parse(strings.TrimRight(apiURL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid github API URL: %w", err)
+	}
+
+	return &GithubAPIRepository{
 		WebhookSecret: webhookSecret,
 		PrivateKey:    privateKey,
+		baseURL:       baseURL,
+	}, nil
+}
+
+// NewClient creates a github client authenticated with the token, requests are counted in the metrics
+func (u *GithubAPIRepository) NewClient(token string) *github.Client {
+	client := github.NewClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+			Base:   metrics.NewGithubTransport(http.DefaultTransport),
+		},
+	})
+	client.BaseURL = u.baseURL
+
+	return client
+}
+
+// CreateInstallationToken returns a token acting as the installation, the client must be authenticated as the app
+func (u *GithubAPIRepository) CreateInstallationToken(ctx context.Context, jwtClient *github.Client, installationID int64) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.CreateInstallationToken", attribute.Int64("github.installation_id", installationID))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	installationToken, _, err := jwtClient.Apps.CreateInstallationToken(ctx, installationID, &github.InstallationTokenOptions{})
+	if err != nil {
+		return "", err
 	}
+
+	return installationToken.GetToken(), nil
 }
 
-func (u *GithubRepository) CreateReviewComments(ctx context.Context, client *github.Client, owner string, repo string, pullNumber int, comment *github.PullRequestComment) error {
+func (u *GithubAPIRepository) CreateReviewComments(ctx context.Context, client *github.Client, owner string, repo string, pullNumber int, comment *github.PullRequestComment) (created *github.PullRequestComment, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.CreateReviewComments", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
 
-	_, _, err := client.PullRequests.CreateComment(ctx, owner, repo, pullNumber, comment)
+	created, _, err = client.PullRequests.CreateComment(ctx, owner, repo, pullNumber, comment)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return created, nil
 }
 
-func (u *GithubRepository) ListPullRequestFiles(ctx context.Context, client *github.Client, owner, repo string, pullNumber int, pageNumber int) ([]*github.CommitFile, error) {
+func (u *GithubAPIRepository) ListPullRequestFiles(ctx context.Context, client *github.Client, owner, repo string, pullNumber int, pageNumber int) (files []*github.CommitFile, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ListPullRequestFiles", append(tracing.PullRequestAttributes(owner, repo, pullNumber), attribute.Int("github.page", pageNumber))...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	slog.Debug("trying to fetch diffs", "owner", owner, "repo", repo, "pullNumber", pullNumber)
 
 	opts := &github.ListOptions{
 		Page: int(pageNumber),
 	}
 
-	files, _, err := client.PullRequests.ListFiles(ctx, owner, repo, pullNumber, opts)
+	files, _, err = client.PullRequests.ListFiles(ctx, owner, repo, pullNumber, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	return files, nil
 }
+
+func (u *GithubAPIRepository) ListReviewComments(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) (allComments []*github.PullRequestComment, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ListReviewComments", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	slog.Debug("trying to fetch existing review comments", "owner", owner, "repo", repo, "pullNumber", pullNumber)
+
+	opts := &github.PullRequestListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		comments, resp, err := client.PullRequests.ListComments(ctx, owner, repo, pullNumber, opts)
+		if err != nil {
+			return nil, err
+		}
+		allComments = append(allComments, comments...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allComments, nil
+}
+
+func (u *GithubAPIRepository) ReplyToReviewComment(ctx context.Context, client *github.Client, owner, repo string, pullNumber int, commentID int64, body string) (err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ReplyToReviewComment", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	_, _, err = client.PullRequests.CreateCommentInReplyTo(ctx, owner, repo, pullNumber, body, commentID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Github lists at most this many files in a comparison, however many pages of commits are read
+const COMPARE_COMMITS_MAX_FILES = 300
+
+// CompareCommits returns the files changed between base and head, reading every page of the comparison.
+// Comparisons changing more than COMPARE_COMMITS_MAX_FILES files are truncated by github.
+func (u *GithubAPIRepository) CompareCommits(ctx context.Context, client *github.Client, owner, repo, base, head string) (files []*github.CommitFile, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.CompareCommits", tracing.OwnerKey.String(owner), tracing.RepoKey.String(repo))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	opts := &github.ListOptions{PerPage: 100}
+	seen := map[string]bool{}
+	for {
+		comparison, resp, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Pages list the commits, files may be repeated on each of them
+		for _, file := range comparison.Files {
+			if seen[file.GetFilename()] {
+				continue
+			}
+			seen[file.GetFilename()] = true
+			files = append(files, file)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return files, nil
+}
+
+// GetFileContent returns the decoded content of a file at the given ref, found is false when the file doesn't exist
+func (u *GithubAPIRepository) GetFileContent(ctx context.Context, client *github.Client, owner, repo, path, ref string) (content string, found bool, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.GetFileContent", tracing.OwnerKey.String(owner), tracing.RepoKey.String(repo), attribute.String("github.path", path))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if file == nil {
+		return "", false, fmt.Errorf("%s is a directory, not a file", path)
+	}
+
+	content, err = file.GetContent()
+	if err != nil {
+		return "", false, err
+	}
+
+	return content, true, nil
+}
+
+// GetAppSlug returns the slug of the github app the JWT client is authenticated as
+func (u *GithubAPIRepository) GetAppSlug(ctx context.Context, jwtClient *github.Client) (slug string, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.GetAppSlug")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	app, _, err := jwtClient.Apps.Get(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	return app.GetSlug(), nil
+}
+
+// FindRepositoryInstallation returns the installation of the app on the repository
+func (u *GithubAPIRepository) FindRepositoryInstallation(ctx context.Context, jwtClient *github.Client, owner, repo string) (installation *github.Installation, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.FindRepositoryInstallation", tracing.OwnerKey.String(owner), tracing.RepoKey.String(repo))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	installation, _, err = jwtClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return installation, nil
+}
+
+// ListInstallations lists every installation of the app
+func (u *GithubAPIRepository) ListInstallations(ctx context.Context, jwtClient *github.Client) (allInstallations []*github.Installation, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ListInstallations")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		installations, resp, err := jwtClient.Apps.ListInstallations(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		allInstallations = append(allInstallations, installations...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allInstallations, nil
+}
+
+// ListInstallationRepositories lists the repositories the installation client has access to
+func (u *GithubAPIRepository) ListInstallationRepositories(ctx context.Context, client *github.Client) (allRepositories []*github.Repository, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ListInstallationRepositories")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		repositories, resp, err := client.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		allRepositories = append(allRepositories, repositories.Repositories...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allRepositories, nil
+}
+
+func (u *GithubAPIRepository) CreateIssue(ctx context.Context, client *github.Client, owner, repo string, title string, body string) (issue *github.Issue, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.CreateIssue", tracing.OwnerKey.String(owner), tracing.RepoKey.String(repo))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	issue, _, err = client.Issues.Create(ctx, owner, repo, &github.IssueRequest{
+		Title: &title,
+		Body:  &body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
+
+// CreateIssueComment comments on the conversation of an issue or pull request
+func (u *GithubAPIRepository) CreateIssueComment(ctx context.Context, client *github.Client, owner, repo string, number int, body string) (comment *github.IssueComment, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.CreateIssueComment", tracing.PullRequestAttributes(owner, repo, number)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	comment, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
+		Body: &body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+func (u *GithubAPIRepository) GetPullRequest(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) (pullRequest *github.PullRequest, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.GetPullRequest", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	pullRequest, _, err = client.PullRequests.Get(ctx, owner, repo, pullNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return pullRequest, nil
+}
+
+const listReviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id
+          isResolved
+          isOutdated
+          path
+          line
+          startLine
+          originalLine
+          originalStartLine
+          diffSide
+          comments(first: 1) {
+            nodes { databaseId body diffHunk author { login } }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const resolveReviewThreadMutation = `mutation($threadId: ID!) {
+  resolveReviewThread(input: {threadId: $threadId}) {
+    thread { id isResolved }
+  }
+}`
+
+// ListReviewThreads returns every review thread on the pull request, review threads are only available through GraphQL
+func (u *GithubAPIRepository) ListReviewThreads(ctx context.Context, client *github.Client, owner, repo string, pullNumber int) (threads []model.ReviewThread, err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ListReviewThreads", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	type threadsResponse struct {
+		Repository struct {
+			PullRequest struct {
+				ReviewThreads struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []struct {
+						ID                string `json:"id"`
+						IsResolved        bool   `json:"isResolved"`
+						IsOutdated        bool   `json:"isOutdated"`
+						Path              string `json:"path"`
+						Line              int    `json:"line"`
+						StartLine         int    `json:"startLine"`
+						OriginalLine      int    `json:"originalLine"`
+						OriginalStartLine int    `json:"originalStartLine"`
+						DiffSide          string `json:"diffSide"`
+						Comments          struct {
+							Nodes []struct {
+								DatabaseID int64  `json:"databaseId"`
+								Body       string `json:"body"`
+								DiffHunk   string `json:"diffHunk"`
+								Author     struct {
+									Login string `json:"login"`
+								} `json:"author"`
+							} `json:"nodes"`
+						} `json:"comments"`
+					} `json:"nodes"`
+				} `json:"reviewThreads"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	}
+
+	var cursor *string
+	for {
+		var response threadsResponse
+		variables := map[string]any{
+			"owner":  owner,
+			"repo":   repo,
+			"number": pullNumber,
+			"cursor": cursor,
+		}
+		if err := u.graphQL(ctx, client, listReviewThreadsQuery, variables, &response); err != nil {
+			return nil, err
+		}
+
+		reviewThreads := response.Repository.PullRequest.ReviewThreads
+		for _, node := range reviewThreads.Nodes {
+			thread := model.ReviewThread{
+				ID:                node.ID,
+				IsResolved:        node.IsResolved,
+				IsOutdated:        node.IsOutdated,
+				Path:              node.Path,
+				Line:              node.Line,
+				StartLine:         node.StartLine,
+				OriginalLine:      node.OriginalLine,
+				OriginalStartLine: node.OriginalStartLine,
+				Side:              node.DiffSide,
+			}
+			if len(node.Comments.Nodes) > 0 {
+				first := node.Comments.Nodes[0]
+				thread.CommentID = first.DatabaseID
+				thread.Body = first.Body
+				thread.DiffHunk = first.DiffHunk
+				thread.AuthorLogin = first.Author.Login
+			}
+			threads = append(threads, thread)
+		}
+
+		if !reviewThreads.PageInfo.HasNextPage {
+			break
+		}
+		endCursor := reviewThreads.PageInfo.EndCursor
+		cursor = &endCursor
+	}
+
+	return threads, nil
+}
+
+func (u *GithubAPIRepository) ResolveReviewThread(ctx context.Context, client *github.Client, threadID string) (err error) {
+	ctx, span := tracing.Start(ctx, "GithubRepository.ResolveReviewThread", attribute.String("github.thread_id", threadID))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	variables := map[string]any{
+		"threadId": threadID,
+	}
+
+	return u.graphQL(ctx, client, resolveReviewThreadMutation, variables, nil)
+}
+
+// graphQL sends a query to the github GraphQL API using the REST client's authentication
+func (u *GithubAPIRepository) graphQL(ctx context.Context, client *github.Client, query string, variables map[string]any, out any) error {
+	body := map[string]any{
+		"query":     query,
+		"variables": variables,
+	}
+
+	req, err := client.NewRequest(http.MethodPost, "graphql", body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := client.Do(ctx, req, &response); err != nil {
+		return err
+	}
+
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("graphql error: %s", response.Errors[0].Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return fmt.Errorf("failed to parse graphql response: %w", err)
+	}
+
+	return nil
+}
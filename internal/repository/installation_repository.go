@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// InstallationRepository keeps track of the accounts and repositories the github app is installed on.
+// SQLReviewRepository implements it next to the review history.
+type InstallationRepository interface {
+	// SaveInstallation creates or updates the installation, the time it was first installed is kept
+	SaveInstallation(ctx context.Context, installation *model.Installation) error
+	GetInstallation(ctx context.Context, id int64) (*model.Installation, error)
+	ListInstallations(ctx context.Context) ([]model.Installation, error)
+
+	// AddInstallationRepositories records repositories (owner/repo) the app was given access to.
+	// welcomed marks them as not needing a welcome, for repositories which predate the registry.
+	AddInstallationRepositories(ctx context.Context, installationID int64, fullNames []string, welcomed bool) error
+	RemoveInstallationRepositories(ctx context.Context, installationID int64, fullNames []string) error
+	// ClaimRepositoryWelcome marks the repository as welcomed, it returns false when it already was
+	ClaimRepositoryWelcome(ctx context.Context, installationID int64, fullName string) (bool, error)
+}
+
+const installationColumns = `id, account, account_type, repository_selection, status, installed_at, updated_at, suspended_at, deleted_at`
+
+func (s *SQLReviewRepository) SaveInstallation(ctx context.Context, installation *model.Installation) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO installations (`+installationColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET account = excluded.account, account_type = excluded.account_type,
+			repository_selection = excluded.repository_selection, status = excluded.status,
+			updated_at = excluded.updated_at, suspended_at = excluded.suspended_at, deleted_at = excluded.deleted_at`),
+		installation.ID, installation.Account, installation.AccountType, installation.RepositorySelection,
+		installation.Status, installation.InstalledAt.UTC(), installation.UpdatedAt.UTC(),
+		nullableTime(installation.SuspendedAt), nullableTime(installation.DeletedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save installation: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) GetInstallation(ctx context.Context, id int64) (*model.Installation, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+installationColumns+` FROM installations WHERE id = ?`), id)
+
+	installation, err := scanInstallation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation: %w", err)
+	}
+
+	installation.Repositories, err = s.listInstallationRepositories(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return installation, nil
+}
+
+func (s *SQLReviewRepository) ListInstallations(ctx context.Context) ([]model.Installation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+installationColumns+` FROM installations ORDER BY account, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installations: %w", err)
+	}
+	defer rows.Close()
+
+	installations := []model.Installation{}
+	for rows.Next() {
+		installation, err := scanInstallation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan installation: %w", err)
+		}
+		installations = append(installations, *installation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list installations: %w", err)
+	}
+	rows.Close()
+
+	for i := range installations {
+		installations[i].Repositories, err = s.listInstallationRepositories(ctx, installations[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return installations, nil
+}
+
+func (s *SQLReviewRepository) AddInstallationRepositories(ctx context.Context, installationID int64, fullNames []string, welcomed bool) error {
+	now := time.Now().UTC()
+	var welcomedAt any
+	if welcomed {
+		welcomedAt = now
+	}
+
+	for _, fullName := range fullNames {
+		// Re-added repositories keep their welcome, they have already seen it
+		_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO installation_repositories
+			(installation_id, full_name, added_at, welcomed_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (installation_id, full_name) DO UPDATE SET removed_at = NULL`),
+			installationID, fullName, now, welcomedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add installation repository: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) RemoveInstallationRepositories(ctx context.Context, installationID int64, fullNames []string) error {
+	now := time.Now().UTC()
+	for _, fullName := range fullNames {
+		_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE installation_repositories SET removed_at = ?
+			WHERE installation_id = ? AND full_name = ? AND removed_at IS NULL`),
+			now, installationID, fullName,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove installation repository: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) ClaimRepositoryWelcome(ctx context.Context, installationID int64, fullName string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, s.rebind(`UPDATE installation_repositories SET welcomed_at = ?
+		WHERE installation_id = ? AND full_name = ? AND welcomed_at IS NULL`),
+		time.Now().UTC(), installationID, fullName,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim repository welcome: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim repository welcome: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// listInstallationRepositories returns the repositories the installation currently has access to
+func (s *SQLReviewRepository) listInstallationRepositories(ctx context.Context, installationID int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT full_name FROM installation_repositories
+		WHERE installation_id = ? AND removed_at IS NULL ORDER BY full_name`), installationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installation repositories: %w", err)
+	}
+	defer rows.Close()
+
+	repositories := []string{}
+	for rows.Next() {
+		var fullName string
+		if err := rows.Scan(&fullName); err != nil {
+			return nil, fmt.Errorf("failed to scan installation repository: %w", err)
+		}
+		repositories = append(repositories, fullName)
+	}
+
+	return repositories, rows.Err()
+}
+
+func scanInstallation(row scanner) (*model.Installation, error) {
+	var installation model.Installation
+	var suspendedAt, deletedAt sql.NullTime
+
+	err := row.Scan(&installation.ID, &installation.Account, &installation.AccountType, &installation.RepositorySelection,
+		&installation.Status, &installation.InstalledAt, &installation.UpdatedAt, &suspendedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if suspendedAt.Valid {
+		installation.SuspendedAt = &suspendedAt.Time
+	}
+	if deletedAt.Valid {
+		installation.DeletedAt = &deletedAt.Time
+	}
+
+	return &installation, nil
+}
+
+func nullableTime(value *time.Time) any {
+	if value == nil {
+		return nil
+	}
+	return value.UTC()
+}
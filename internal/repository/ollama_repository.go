@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+const DEFAULT_OLLAMA_URL = "http://localhost:11434"
+const DEFAULT_OLLAMA_MODEL = "qwen2.5-coder:7b"
+
+// OllamaRepository talks to a local Ollama server, so reviews can run without any network access
+type OllamaRepository struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+	// Review contexts rendered once, nil when context caching is disabled
+	contexts *reviewContexts
+}
+
+// NewOllamaRepository reviews with the model on the server at baseURL, keeping review contexts for contextTTL,
+// 0 renders the prompt again for every chunk
+func NewOllamaRepository(baseURL string, model string, contextTTL time.Duration) *OllamaRepository {
+	if baseURL == "" {
+		baseURL = DEFAULT_OLLAMA_URL
+	}
+	if model == "" {
+		model = DEFAULT_OLLAMA_MODEL
+	}
+
+	return &OllamaRepository{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		// Local models can be slow on laptops, give them the same budget as the hosted ones
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		contexts:   newReviewContexts(contextTTL),
+	}
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Format   any             `json:"format,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Error           string        `json:"error"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// Model returns the name of the local model used for reviews
+func (o *OllamaRepository) Model() string {
+	return o.model
+}
+
+func (o *OllamaRepository) GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) (reviewComments []model.ReviewCommentRequest, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "OllamaRepository.GetCodeReviews", tracing.ModelKey.String(o.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	// The shared review prompt is reused when it was rendered, with the diff and what was written about the pull
+	// request in the message. Otherwise the whole prompt is rendered. Untrusted content is wrapped in new tags it
+	// can't guess either way, so it can't pass itself off as instructions.
+	var systemPrompt, userPrompt string
+	if reviewCtx, cached := o.contexts.get(promptCtx); cached {
+		systemPrompt, userPrompt = reviewCtx.systemPrompt, utils.GenerateReviewRequest(promptCtx, code)
+	} else {
+		untrustedTag := utils.NewUntrustedTag("diff")
+		systemPrompt, err = utils.GenerateCodeReviewPrompt(promptCtx.Rules, promptCtx, untrustedTag)
+		if err != nil {
+			return nil, model.TokenUsage{}, err
+		}
+		userPrompt = utils.WrapUntrusted(untrustedTag, code)
+	}
+
+	// Same structure as the gemini response schema, expressed as JSON schema
+	responseSchema := map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"body":      map[string]any{"type": "string"},
+				"commit_id": map[string]any{"type": "string"},
+				"path":      map[string]any{"type": "string"},
+				"line":      map[string]any{"type": "integer"},
+			},
+			"required": []string{"body", "commit_id", "path", "line"},
+		},
+	}
+
+	responseText, usage, err := o.chat(ctx, systemPrompt, userPrompt, responseSchema)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	if err := json.Unmarshal([]byte(responseText), &reviewComments); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse response JSON: %w", err)
+	}
+
+	// Validate the response
+	if err := validateReviewComments(reviewComments); err != nil {
+		return nil, usage, fmt.Errorf("invalid response format: %w", err)
+	}
+
+	return reviewComments, usage, nil
+}
+
+// CacheReviewContext renders the review prompt shared by the pull requests of the repository once, ollama reuses
+// what it computed for the start of a prompt when the next one starts the same way, so chunks only have their
+// own message evaluated
+func (o *OllamaRepository) CacheReviewContext(ctx context.Context, promptCtx model.PromptContext) error {
+	if o.contexts == nil {
+		return nil
+	}
+	if _, found := o.contexts.get(promptCtx); found {
+		return nil
+	}
+
+	reviewCtx, err := newReviewContext(promptCtx, o.contexts.ttl)
+	if err != nil {
+		return err
+	}
+	o.contexts.put(promptCtx, reviewCtx)
+	return nil
+}
+
+func (o *OllamaRepository) CheckIssueResolved(ctx context.Context, comment string, originalHunk string, updatedCode string) (resolution *model.IssueResolution, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "OllamaRepository.CheckIssueResolved", tracing.ModelKey.String(o.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	prompt, err := utils.GenerateResolutionCheckPrompt(comment, originalHunk, updatedCode)
+	if err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+
+	responseSchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"resolved": map[string]any{"type": "boolean"},
+			"reason":   map[string]any{"type": "string"},
+		},
+		"required": []string{"resolved", "reason"},
+	}
+
+	responseText, usage, err := o.chat(ctx, "", prompt, responseSchema)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	resolution = &model.IssueResolution{}
+	if err := json.Unmarshal([]byte(responseText), resolution); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse response JSON: %w", err)
+	}
+
+	return resolution, usage, nil
+}
+
+// Summarize writes a short summary of the formatted diff, used instead of a full review once a budget is exceeded
+func (o *OllamaRepository) Summarize(ctx context.Context, code string, promptCtx model.PromptContext) (summary string, usage model.TokenUsage, err error) {
+	ctx, span := tracing.Start(ctx, "OllamaRepository.Summarize", tracing.ModelKey.String(o.model))
+	defer func() {
+		span.SetAttributes(tracing.TokenAttributes(usage.PromptTokens, usage.CompletionTokens)...)
+		tracing.End(span, err)
+	}()
+
+	untrustedTag := utils.NewUntrustedTag("diff")
+	systemPrompt, err := utils.GenerateSummaryPrompt(promptCtx, untrustedTag)
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	return o.chat(ctx, systemPrompt, utils.WrapUntrusted(untrustedTag, code), nil)
+}
+
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// Ping checks the ollama server is up and the review model has been pulled
+func (o *OllamaRepository) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to parse ollama models: %w", err)
+	}
+
+	for _, pulled := range tags.Models {
+		if pulled.Name == o.model || pulled.Name == o.model+":latest" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("model %q has not been pulled, run ollama pull %s", o.model, o.model)
+}
+
+// chat sends a single non streaming chat request constrained to the response schema and returns the reply
+func (o *OllamaRepository) chat(ctx context.Context, systemPrompt string, userPrompt string, responseSchema any) (string, model.TokenUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	var messages []ollamaMessage
+	if systemPrompt != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: systemPrompt})
+	}
+	messages = append(messages, ollamaMessage{Role: "user", Content: userPrompt})
+
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:    o.model,
+		Messages: messages,
+		Format:   responseSchema,
+		Stream:   false,
+		// Reviews should be reproducible between runs
+		Options: map[string]any{"temperature": 0},
+	})
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("failed to marshal ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", model.TokenUsage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("failed to read ollama response: %w", err)
+	}
+
+	var chatResponse ollamaChatResponse
+	if err := json.Unmarshal(respBody, &chatResponse); err != nil {
+		return "", model.TokenUsage{}, fmt.Errorf("failed to parse ollama response: %w", err)
+	}
+
+	usage := model.TokenUsage{
+		PromptTokens:     chatResponse.PromptEvalCount,
+		CompletionTokens: chatResponse.EvalCount,
+		TotalTokens:      chatResponse.PromptEvalCount + chatResponse.EvalCount,
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", usage, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, chatResponse.Error)
+	}
+
+	if chatResponse.Message.Content == "" {
+		return "", usage, fmt.Errorf("no response generated")
+	}
+
+	return chatResponse.Message.Content, usage, nil
+}
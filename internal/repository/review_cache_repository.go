@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// Entries kept by the in-memory review cache before the oldest are evicted
+const DEFAULT_REVIEW_CACHE_ENTRIES = 1000
+
+// Cache keys are hex encoded hashes, so they are safe to use as file names
+var reviewCacheKeyPattern = regexp.MustCompile(`^[0-9a-f]{16,128}$`)
+
+// ReviewCacheRepository stores the comments the LLM generated for chunks of diffs by a content hash.
+// MemoryReviewCacheRepository and DiskReviewCacheRepository implement it.
+type ReviewCacheRepository interface {
+	Get(ctx context.Context, key string) (review *model.CachedReview, found bool, err error)
+	Put(ctx context.Context, key string, review *model.CachedReview) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryReviewCacheRepository keeps cached reviews in memory, evicting the oldest beyond maxEntries
+type MemoryReviewCacheRepository struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*model.CachedReview
+	// Keys in the order they were added, for eviction
+	order []string
+}
+
+func NewMemoryReviewCacheRepository(maxEntries int) *MemoryReviewCacheRepository {
+	if maxEntries <= 0 {
+		maxEntries = DEFAULT_REVIEW_CACHE_ENTRIES
+	}
+
+	return &MemoryReviewCacheRepository{
+		maxEntries: maxEntries,
+		entries:    map[string]*model.CachedReview{},
+	}
+}
+
+func (m *MemoryReviewCacheRepository) Get(ctx context.Context, key string) (*model.CachedReview, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	review, found := m.entries[key]
+	return review, found, nil
+}
+
+func (m *MemoryReviewCacheRepository) Put(ctx context.Context, key string, review *model.CachedReview) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.entries[key]; !found {
+		m.order = append(m.order, key)
+	}
+	m.entries[key] = review
+
+	for len(m.entries) > m.maxEntries && len(m.order) > 0 {
+		delete(m.entries, m.order[0])
+		m.order = m.order[1:]
+	}
+	return nil
+}
+
+func (m *MemoryReviewCacheRepository) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, found := m.entries[key]; !found {
+		return nil
+	}
+	delete(m.entries, key)
+	for i, existing := range m.order {
+		if existing == key {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// DiskReviewCacheRepository keeps cached reviews as JSON files in a directory, so they survive restarts
+// and can be shared by the CLI runs of a CI job
+type DiskReviewCacheRepository struct {
+	dir string
+}
+
+// NewDiskReviewCacheRepository creates dir when it doesn't exist
+func NewDiskReviewCacheRepository(dir string) (*DiskReviewCacheRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create review cache directory: %w", err)
+	}
+
+	return &DiskReviewCacheRepository{dir: dir}, nil
+}
+
+func (d *DiskReviewCacheRepository) Get(ctx context.Context, key string) (*model.CachedReview, bool, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cached review: %w", err)
+	}
+
+	var review model.CachedReview
+	if err := json.Unmarshal(content, &review); err != nil {
+		return nil, false, fmt.Errorf("failed to parse cached review %s: %w", path, err)
+	}
+	return &review, true, nil
+}
+
+// Put writes to a temporary file first, so readers never see a partly written review
+func (d *DiskReviewCacheRepository) Put(ctx context.Context, key string, review *model.CachedReview) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached review: %w", err)
+	}
+
+	file, err := os.CreateTemp(d.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cached review: %w", err)
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write cached review: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskReviewCacheRepository) Delete(ctx context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete cached review: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskReviewCacheRepository) path(key string) (string, error) {
+	if !reviewCacheKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid review cache key %q", key)
+	}
+	return filepath.Join(d.dir, key+".json"), nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// How long providers keep the review context of a repository cached, it is extended while reviews use it
+const DEFAULT_REVIEW_CONTEXT_TTL = time.Hour
+
+// How many review contexts are kept for a repository and language. Rule files are scoped by path, so pull requests
+// changing different parts of a repository are reviewed with different rules, and each set of rules has a context.
+const REVIEW_CONTEXTS_PER_SCOPE = 4
+
+// reviewContext is the review prompt every pull request of a repository shares for one language, rendered once
+// so every chunk sends the same prompt. It holds no untrusted tag, each chunk wraps its content in new ones.
+type reviewContext struct {
+	systemPrompt string
+	// Repository and language the context is for, each of them keeps at most REVIEW_CONTEXTS_PER_SCOPE contexts
+	scope string
+	// What the provider cached the system prompt as, empty when it is sent with every chunk
+	name      string
+	expiresAt time.Time
+	// When a review last used the context, the least recently used one of a full scope is replaced
+	lastUsed time.Time
+}
+
+// reviewContexts are the review contexts a provider cached, by the hash of their shared prompt context.
+// Providers without context caching have a nil reviewContexts, which caches nothing.
+type reviewContexts struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	contexts map[string]reviewContext
+}
+
+// newReviewContexts keeps review contexts for ttl after they were cached, nil when ttl is 0
+func newReviewContexts(ttl time.Duration) *reviewContexts {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &reviewContexts{
+		ttl:      ttl,
+		contexts: map[string]reviewContext{},
+	}
+}
+
+// get returns the review context cached for the prompt context, when it hasn't expired
+func (r *reviewContexts) get(promptCtx model.PromptContext) (reviewContext, bool) {
+	if r == nil {
+		return reviewContext{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reviewContextKey(promptCtx)
+	reviewCtx, found := r.contexts[key]
+	if !found || time.Now().After(reviewCtx.expiresAt) {
+		return reviewContext{}, false
+	}
+	reviewCtx.lastUsed = time.Now()
+	r.contexts[key] = reviewCtx
+	return reviewCtx, true
+}
+
+// put keeps the review context of the prompt context until it expires, and forgets the expired ones. When its
+// repository and language already have REVIEW_CONTEXTS_PER_SCOPE contexts, it replaces the least recently used
+// one and returns it, so the provider deletes it.
+func (r *reviewContexts) put(promptCtx model.PromptContext, reviewCtx reviewContext) []reviewContext {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reviewContextKey(promptCtx)
+	now := time.Now()
+	if reviewCtx.lastUsed.IsZero() {
+		reviewCtx.lastUsed = now
+	}
+
+	var leastRecentKey string
+	inScope := 0
+	for cachedKey, cached := range r.contexts {
+		switch {
+		case now.After(cached.expiresAt):
+			delete(r.contexts, cachedKey)
+		case cachedKey != key && cached.scope == reviewCtx.scope:
+			inScope++
+			if leastRecentKey == "" || cached.lastUsed.Before(r.contexts[leastRecentKey].lastUsed) {
+				leastRecentKey = cachedKey
+			}
+		}
+	}
+	r.contexts[key] = reviewCtx
+
+	if inScope < REVIEW_CONTEXTS_PER_SCOPE {
+		return nil
+	}
+	replaced := r.contexts[leastRecentKey]
+	delete(r.contexts, leastRecentKey)
+	return []reviewContext{replaced}
+}
+
+// forget drops the review context of the prompt context, so the next reviews send the whole prompt again
+func (r *reviewContexts) forget(promptCtx model.PromptContext) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.contexts, reviewContextKey(promptCtx))
+}
+
+// newReviewContext renders the shared review prompt of the prompt context
+func newReviewContext(promptCtx model.PromptContext, ttl time.Duration) (reviewContext, error) {
+	systemPrompt, err := utils.GenerateSharedReviewPrompt(promptCtx)
+	if err != nil {
+		return reviewContext{}, err
+	}
+
+	return reviewContext{
+		systemPrompt: systemPrompt,
+		scope:        promptCtx.Repository + "\x00" + promptCtx.Language,
+		expiresAt:    time.Now().Add(ttl),
+	}, nil
+}
+
+// reviewContextKey hashes what the shared review prompt is rendered from, maps are encoded with sorted keys
+func reviewContextKey(promptCtx model.PromptContext) string {
+	encoded, _ := json.Marshal(utils.SharedPromptContext(promptCtx))
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+func rulesContext(repository string, rules string) model.PromptContext {
+	return model.PromptContext{Repository: repository, Language: "Go", Rules: rules}
+}
+
+func newTestReviewContext(t *testing.T, promptCtx model.PromptContext, name string) reviewContext {
+	t.Helper()
+
+	reviewCtx, err := newReviewContext(promptCtx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewCtx.name = name
+	return reviewCtx
+}
+
+func TestReviewContextsSharedAcrossPullRequests(t *testing.T) {
+	contexts := newReviewContexts(time.Hour)
+	promptCtx := rulesContext("octo/app", "Wrap errors")
+	contexts.put(promptCtx, newTestReviewContext(t, promptCtx, "cachedContents/1"))
+
+	// Another pull request of the repository, reviewed with the same rules
+	other := promptCtx
+	other.PullRequestTitle, other.PullRequestBody, other.RejectedPatterns = "Fix rounding", "Rounds half up", []string{"Rename this"}
+	if reviewCtx, found := contexts.get(other); !found || reviewCtx.name != "cachedContents/1" {
+		t.Errorf("expected the context to be shared, got %+v", reviewCtx)
+	}
+
+	for name, promptCtx := range map[string]model.PromptContext{
+		"other rules":      rulesContext("octo/app", "Log with slog"),
+		"other repository": rulesContext("octo/web", "Wrap errors"),
+	} {
+		if _, found := contexts.get(promptCtx); found {
+			t.Errorf("expected no context for %s", name)
+		}
+	}
+}
+
+func TestReviewContextsExpireAndForget(t *testing.T) {
+	contexts := newReviewContexts(time.Hour)
+	expired, kept := rulesContext("octo/app", "Wrap errors"), rulesContext("octo/web", "Wrap errors")
+
+	reviewCtx := newTestReviewContext(t, expired, "cachedContents/1")
+	reviewCtx.expiresAt = time.Now().Add(-time.Minute)
+	contexts.put(expired, reviewCtx)
+	if _, found := contexts.get(expired); found {
+		t.Error("expected an expired context to be left out")
+	}
+
+	// Expired contexts are dropped when another is kept
+	contexts.put(kept, newTestReviewContext(t, kept, "cachedContents/2"))
+	if len(contexts.contexts) != 1 {
+		t.Errorf("expected the expired context to be dropped, got %d contexts", len(contexts.contexts))
+	}
+
+	contexts.forget(kept)
+	if _, found := contexts.get(kept); found {
+		t.Error("expected a forgotten context to be left out")
+	}
+}
+
+func TestReviewContextsReplaceLeastRecentlyUsedOfAFullScope(t *testing.T) {
+	contexts := newReviewContexts(time.Hour)
+
+	var scoped []model.PromptContext
+	for i := range REVIEW_CONTEXTS_PER_SCOPE {
+		promptCtx := rulesContext("octo/app", fmt.Sprintf("rules of part %d", i))
+		scoped = append(scoped, promptCtx)
+		if replaced := contexts.put(promptCtx, newTestReviewContext(t, promptCtx, fmt.Sprintf("cachedContents/%d", i))); len(replaced) != 0 {
+			t.Fatalf("expected nothing replaced until the scope is full, got %+v", replaced)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Other repositories don't count towards the scope
+	web := rulesContext("octo/web", "rules of part 0")
+	if replaced := contexts.put(web, newTestReviewContext(t, web, "cachedContents/web")); len(replaced) != 0 {
+		t.Fatalf("expected nothing replaced in another scope, got %+v", replaced)
+	}
+
+	// The first context is used by a review, the second one is now the least recently used
+	contexts.get(scoped[0])
+	extended := newTestReviewContext(t, scoped[0], "cachedContents/0")
+	if replaced := contexts.put(scoped[0], extended); len(replaced) != 0 {
+		t.Fatalf("expected extending a context to replace nothing, got %+v", replaced)
+	}
+
+	another := rulesContext("octo/app", "rules of another part")
+	replaced := contexts.put(another, newTestReviewContext(t, another, "cachedContents/new"))
+	if len(replaced) != 1 || replaced[0].name != "cachedContents/1" {
+		t.Fatalf("expected the least recently used context to be replaced, got %+v", replaced)
+	}
+	if _, found := contexts.get(scoped[1]); found {
+		t.Error("expected the replaced context to be left out")
+	}
+	for _, promptCtx := range []model.PromptContext{scoped[0], scoped[2], scoped[3], another, web} {
+		if _, found := contexts.get(promptCtx); !found {
+			t.Errorf("expected the context of %q in %s to be kept", promptCtx.Rules, promptCtx.Repository)
+		}
+	}
+}
+
+func TestNilReviewContextsCacheNothing(t *testing.T) {
+	contexts := newReviewContexts(0)
+	promptCtx := rulesContext("octo/app", "Wrap errors")
+
+	if replaced := contexts.put(promptCtx, newTestReviewContext(t, promptCtx, "cachedContents/1")); replaced != nil {
+		t.Errorf("expected nothing replaced, got %+v", replaced)
+	}
+	if _, found := contexts.get(promptCtx); found {
+		t.Error("expected nothing cached")
+	}
+	contexts.forget(promptCtx)
+}
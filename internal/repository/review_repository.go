@@ -0,0 +1,403 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+const SQLITE_DIALECT = "sqlite"
+const POSTGRES_DIALECT = "postgres"
+
+// ErrNotFound is returned when a stored record doesn't exist
+var ErrNotFound = errors.New("record not found")
+
+// ReviewRepository records every review run and the comments it generated.
+// SQLReviewRepository implements it for SQLite and Postgres.
+type ReviewRepository interface {
+	CreateRun(ctx context.Context, run *model.ReviewRun) error
+	FinishRun(ctx context.Context, run *model.ReviewRun) error
+	GetRun(ctx context.Context, id int64) (*model.ReviewRun, error)
+	ListRuns(ctx context.Context, filter model.ReviewRunFilter) ([]model.ReviewRun, error)
+
+	AddComment(ctx context.Context, comment *model.StoredComment) error
+	ListComments(ctx context.Context, runID int64) ([]model.StoredComment, error)
+
+	// CheckWritable fails when the database can't be written to, for the readiness probe
+	CheckWritable(ctx context.Context) error
+	Close() error
+}
+
+// SQLReviewRepository stores reviews in any database/sql database, queries are written with ? placeholders
+// and rewritten for the dialect
+type SQLReviewRepository struct {
+	db      *sql.DB
+	dialect string
+}
+
+// migrations are applied in order and recorded in schema_migrations, never edit an existing migration.
+// {{ID}} is replaced with the auto increment primary key type of the dialect.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS review_runs (
+		id {{ID}},
+		installation_id BIGINT NOT NULL,
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		pull_number INTEGER NOT NULL,
+		head_sha TEXT NOT NULL,
+		trigger TEXT NOT NULL,
+		model TEXT NOT NULL,
+		prompt_version TEXT NOT NULL,
+		dry_run BOOLEAN NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		total_tokens INTEGER NOT NULL DEFAULT 0,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS review_runs_repo_idx ON review_runs (owner, repo, pull_number);
+	CREATE TABLE IF NOT EXISTS review_comments (
+		id {{ID}},
+		run_id BIGINT NOT NULL REFERENCES review_runs (id),
+		path TEXT NOT NULL,
+		line INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		severity TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		github_comment_id BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS review_comments_run_idx ON review_comments (run_id);`,
+	`ALTER TABLE review_comments ADD COLUMN thumbs_up INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE review_comments ADD COLUMN thumbs_down INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS review_comments_github_idx ON review_comments (github_comment_id);
+	CREATE TABLE IF NOT EXISTS comment_feedback (
+		id {{ID}},
+		comment_id BIGINT NOT NULL REFERENCES review_comments (id),
+		github_reply_id BIGINT NOT NULL UNIQUE,
+		author TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS readiness_checks (
+		id INTEGER PRIMARY KEY,
+		checked_at TIMESTAMP NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS installations (
+		id BIGINT PRIMARY KEY,
+		account TEXT NOT NULL,
+		account_type TEXT NOT NULL,
+		repository_selection TEXT NOT NULL,
+		status TEXT NOT NULL,
+		installed_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		suspended_at TIMESTAMP,
+		deleted_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS installation_repositories (
+		installation_id BIGINT NOT NULL REFERENCES installations (id),
+		full_name TEXT NOT NULL,
+		added_at TIMESTAMP NOT NULL,
+		removed_at TIMESTAMP,
+		welcomed_at TIMESTAMP,
+		PRIMARY KEY (installation_id, full_name)
+	);`,
+	`CREATE TABLE IF NOT EXISTS llm_calls (
+		id {{ID}},
+		run_id BIGINT NOT NULL DEFAULT 0,
+		installation_id BIGINT NOT NULL,
+		owner TEXT NOT NULL,
+		repo TEXT NOT NULL,
+		model TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS llm_calls_installation_idx ON llm_calls (installation_id, created_at);
+	CREATE INDEX IF NOT EXISTS llm_calls_created_idx ON llm_calls (created_at);`,
+}
+
+func NewSQLReviewRepository(ctx context.Context, db *sql.DB, dialect string) (*SQLReviewRepository, error) {
+	if dialect != SQLITE_DIALECT && dialect != POSTGRES_DIALECT {
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+
+	repository := &SQLReviewRepository{
+		db:      db,
+		dialect: dialect,
+	}
+
+	if err := repository.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return repository, nil
+}
+
+func (s *SQLReviewRepository) CreateRun(ctx context.Context, run *model.ReviewRun) error {
+	row := s.db.QueryRowContext(ctx, s.rebind(`INSERT INTO review_runs
+		(installation_id, owner, repo, pull_number, head_sha, trigger, model, prompt_version, dry_run, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		run.InstallationID, run.Owner, run.Repo, run.PullNumber, run.HeadSHA, run.Trigger, run.Model,
+		run.PromptVersion, run.DryRun, run.Status, run.StartedAt.UTC(),
+	)
+
+	if err := row.Scan(&run.ID); err != nil {
+		return fmt.Errorf("failed to create review run: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) FinishRun(ctx context.Context, run *model.ReviewRun) error {
+	var finishedAt any
+	if run.FinishedAt != nil {
+		finishedAt = run.FinishedAt.UTC()
+	}
+
+	_, err := s.db.ExecContext(ctx, s.rebind(`UPDATE review_runs SET
+		model = ?, prompt_version = ?, status = ?, error = ?, prompt_tokens = ?, completion_tokens = ?,
+		total_tokens = ?, duration_ms = ?, finished_at = ?
+		WHERE id = ?`),
+		run.Model, run.PromptVersion, run.Status, run.Error, run.Usage.PromptTokens, run.Usage.CompletionTokens,
+		run.Usage.TotalTokens, run.DurationMs, finishedAt, run.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish review run: %w", err)
+	}
+
+	return nil
+}
+
+const reviewRunColumns = `id, installation_id, owner, repo, pull_number, head_sha, trigger, model, prompt_version, dry_run,
+	status, error, prompt_tokens, completion_tokens, total_tokens, duration_ms, started_at, finished_at`
+
+func (s *SQLReviewRepository) GetRun(ctx context.Context, id int64) (*model.ReviewRun, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`SELECT `+reviewRunColumns+` FROM review_runs WHERE id = ?`), id)
+
+	run, err := scanReviewRun(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review run: %w", err)
+	}
+
+	return run, nil
+}
+
+func (s *SQLReviewRepository) ListRuns(ctx context.Context, filter model.ReviewRunFilter) ([]model.ReviewRun, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, filter.Owner)
+	}
+	if filter.Repo != "" {
+		conditions = append(conditions, "repo = ?")
+		args = append(args, filter.Repo)
+	}
+	if filter.DryRun != nil {
+		conditions = append(conditions, "dry_run = ?")
+		args = append(args, *filter.DryRun)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := `SELECT ` + reviewRunColumns + ` FROM review_runs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += ` ORDER BY id DESC LIMIT ` + strconv.Itoa(limit)
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []model.ReviewRun{}
+	for rows.Next() {
+		run, err := scanReviewRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+
+	return runs, rows.Err()
+}
+
+func (s *SQLReviewRepository) AddComment(ctx context.Context, comment *model.StoredComment) error {
+	row := s.db.QueryRowContext(ctx, s.rebind(`INSERT INTO review_comments
+		(run_id, path, line, body, severity, status, github_comment_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		comment.RunID, comment.Path, comment.Line, comment.Body, comment.Severity, comment.Status,
+		comment.GithubCommentID, comment.CreatedAt.UTC(),
+	)
+
+	if err := row.Scan(&comment.ID); err != nil {
+		return fmt.Errorf("failed to add review comment: %w", err)
+	}
+
+	return nil
+}
+
+const storedCommentColumns = `id, run_id, path, line, body, severity, status, github_comment_id, created_at`
+
+func (s *SQLReviewRepository) ListComments(ctx context.Context, runID int64) ([]model.StoredComment, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+storedCommentColumns+` FROM review_comments WHERE run_id = ? ORDER BY id`), runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []model.StoredComment{}
+	for rows.Next() {
+		comment, err := scanStoredComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review comment: %w", err)
+		}
+		comments = append(comments, *comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// CheckWritable writes the time of the check to a single row table, a read only or locked database fails it
+func (s *SQLReviewRepository) CheckWritable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO readiness_checks (id, checked_at) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET checked_at = excluded.checked_at`),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write to the database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLReviewRepository) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies every migration which hasn't been recorded in schema_migrations yet
+func (s *SQLReviewRepository) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	idType := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if s.dialect == POSTGRES_DIALECT {
+		idType = "BIGSERIAL PRIMARY KEY"
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range strings.Split(strings.ReplaceAll(migrations[i], "{{ID}}", idType), ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebind rewrites ? placeholders into $1, $2... for postgres
+func (s *SQLReviewRepository) rebind(query string) string {
+	if s.dialect != POSTGRES_DIALECT {
+		return query
+	}
+
+	var builder strings.Builder
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			builder.WriteString("$" + strconv.Itoa(position))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReviewRun(row scanner) (*model.ReviewRun, error) {
+	var run model.ReviewRun
+	var finishedAt sql.NullTime
+
+	err := row.Scan(&run.ID, &run.InstallationID, &run.Owner, &run.Repo, &run.PullNumber, &run.HeadSHA, &run.Trigger,
+		&run.Model, &run.PromptVersion, &run.DryRun, &run.Status, &run.Error, &run.Usage.PromptTokens,
+		&run.Usage.CompletionTokens, &run.Usage.TotalTokens, &run.DurationMs, &run.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if finishedAt.Valid {
+		finished := finishedAt.Time
+		run.FinishedAt = &finished
+	}
+
+	return &run, nil
+}
+
+func scanStoredComment(row scanner) (*model.StoredComment, error) {
+	var comment model.StoredComment
+
+	err := row.Scan(&comment.ID, &comment.RunID, &comment.Path, &comment.Line, &comment.Body, &comment.Severity,
+		&comment.Status, &comment.GithubCommentID, &comment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
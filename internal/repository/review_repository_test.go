@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T, path string) *SQLReviewRepository {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository, err := NewSQLReviewRepository(context.Background(), db, SQLITE_DIALECT)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repository.Close() })
+	return repository
+}
+
+func TestMigrationsRunTwice(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "reviews.db")
+
+	first := openSQLite(t, path)
+	run := &model.ReviewRun{InstallationID: 1, Owner: "octo", Repo: "api", PullNumber: 7, HeadSHA: "abc123", Status: model.REVIEW_STATUS_RUNNING, StartedAt: time.Now()}
+	if err := first.CreateRun(ctx, run); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart migrates the same database again, nothing is applied twice and nothing is lost
+	second := openSQLite(t, path)
+	var applied, latest int
+	if err := second.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&applied, &latest); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) || latest != len(migrations) {
+		t.Errorf("expected %d migrations applied once each, got %d up to version %d", len(migrations), applied, latest)
+	}
+	if err := second.migrate(ctx); err != nil {
+		t.Errorf("expected migrating an up to date database to do nothing, got %v", err)
+	}
+
+	stored, err := second.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.HeadSHA != "abc123" {
+		t.Errorf("expected the run to survive migrating again, got %+v", stored)
+	}
+	if err := second.CheckWritable(ctx); err != nil {
+		t.Errorf("expected the migrated database to be writable, got %v", err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM review_runs WHERE owner = ? AND repo = ? LIMIT ?`
+
+	tests := []struct {
+		name     string
+		dialect  string
+		query    string
+		expected string
+	}{
+		{name: "sqlite keeps placeholders", dialect: SQLITE_DIALECT, query: query, expected: query},
+		{name: "postgres numbers placeholders", dialect: POSTGRES_DIALECT, query: query, expected: `SELECT id FROM review_runs WHERE owner = $1 AND repo = $2 LIMIT $3`},
+		{
+			name:     "postgres numbers placeholders across lines",
+			dialect:  POSTGRES_DIALECT,
+			query:    "INSERT INTO readiness_checks (id, checked_at) VALUES (1, ?)\n\t\tON CONFLICT (id) DO UPDATE SET checked_at = ?",
+			expected: "INSERT INTO readiness_checks (id, checked_at) VALUES (1, $1)\n\t\tON CONFLICT (id) DO UPDATE SET checked_at = $2",
+		},
+		{name: "postgres without placeholders", dialect: POSTGRES_DIALECT, query: `SELECT COUNT(*) FROM review_runs`, expected: `SELECT COUNT(*) FROM review_runs`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := &SQLReviewRepository{dialect: test.dialect}
+			if got := repository.rebind(test.query); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
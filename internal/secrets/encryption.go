@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Size in bytes of the keys secrets are encrypted with
+const KEY_SIZE = 32
+
+const nonceSize = 24
+
+// GenerateKey returns a random encryption key
+func GenerateKey() (*[KEY_SIZE]byte, error) {
+	key := new([KEY_SIZE]byte)
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeKey encodes the key as base64, the format LoadKeyFile reads
+func EncodeKey(key *[KEY_SIZE]byte) string {
+	return base64.StdEncoding.EncodeToString(key[:])
+}
+
+// LoadKeyFile reads a base64 encoded encryption key from a file
+func LoadKeyFile(path string) (*[KEY_SIZE]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key file as base64: %w", err)
+	}
+	if len(decoded) != KEY_SIZE {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KEY_SIZE, len(decoded))
+	}
+
+	key := new([KEY_SIZE]byte)
+	copy(key[:], decoded)
+	return key, nil
+}
+
+// Encrypt seals the secret with NaCl secretbox and returns the nonce and ciphertext encoded as base64
+func Encrypt(secret []byte, key *[KEY_SIZE]byte) ([]byte, error) {
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := secretbox.Seal(nonce[:], secret, &nonce, key)
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(encoded, sealed)
+	return append(encoded, '\n'), nil
+}
+
+// Decrypt opens a secret sealed by Encrypt
+func Decrypt(data []byte, key *[KEY_SIZE]byte) ([]byte, error) {
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(sealed, bytes.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode as base64: %w", err)
+	}
+	sealed = sealed[:n]
+
+	if len(sealed) < nonceSize+secretbox.Overhead {
+		return nil, errors.New("encrypted secret is too short")
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], sealed[:nonceSize])
+	secret, ok := secretbox.Open(nil, sealed[nonceSize:], &nonce, key)
+	if !ok {
+		return nil, errors.New("wrong key or corrupted secret")
+	}
+	return secret, nil
+}
@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const DEFAULT_RELOAD_INTERVAL = 30 * time.Second
+const DEFAULT_ROTATION_WINDOW = time.Hour
+
+// Secret holds the current value of a secret and reloads it from its source on change.
+// After a change the previous value stays available for the rotation window, so callers
+// verifying signatures can accept both while the new secret is rolled out.
+type Secret struct {
+	source         Source
+	validate       func([]byte) error
+	rotationWindow time.Duration
+
+	mu        sync.RWMutex
+	current   []byte
+	previous  []byte
+	rotatedAt time.Time
+}
+
+// New loads the secret from its source, validate is optional and rejects values that can't be used,
+// like a private key which doesn't parse
+func New(source Source, validate func([]byte) error, rotationWindow time.Duration) (*Secret, error) {
+	secret := &Secret{
+		source:         source,
+		validate:       validate,
+		rotationWindow: rotationWindow,
+	}
+
+	value, err := secret.load()
+	if err != nil {
+		return nil, err
+	}
+	secret.current = value
+
+	return secret, nil
+}
+
+// Static wraps a value which never changes
+func Static(value []byte) *Secret {
+	return &Secret{
+		source:  &LiteralSource{value: value},
+		current: value,
+	}
+}
+
+// Value returns the current value of the secret
+func (s *Secret) Value() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.current
+}
+
+// Values returns the current value, followed by the previous one while still inside the rotation window
+func (s *Secret) Values() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := [][]byte{s.current}
+	if s.previous != nil && time.Since(s.rotatedAt) < s.rotationWindow {
+		values = append(values, s.previous)
+	}
+	return values
+}
+
+// Reload reads the source again and reports whether the value changed. An invalid new value is
+// rejected and the current one kept.
+func (s *Secret) Reload() (bool, error) {
+	value, err := s.load()
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bytes.Equal(value, s.current) {
+		return false, nil
+	}
+
+	s.previous = s.current
+	s.current = value
+	s.rotatedAt = time.Now()
+
+	return true, nil
+}
+
+// Watch reloads the secret every interval until ctx is done, name is only used in logs
+func (s *Secret) Watch(ctx context.Context, name string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Reload()
+			if err != nil {
+				slog.Error("error reloading secret, keeping the current value", "secret", name, "source", s.source.String(), "error", err)
+				continue
+			}
+			if changed {
+				slog.Info("secret rotated", "secret", name, "source", s.source.String(), "previous_accepted_for", s.rotationWindow.String())
+			}
+		}
+	}
+}
+
+// String describes where the secret comes from, never its value
+func (s *Secret) String() string {
+	return s.source.String()
+}
+
+// LogValue keeps the value out of the logs
+func (s *Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s *Secret) load() ([]byte, error) {
+	value, err := s.source.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, fmt.Errorf("secret from %s is empty", s.source.String())
+	}
+
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return nil, fmt.Errorf("invalid secret from %s: %w", s.source.String(), err)
+		}
+	}
+
+	return value, nil
+}
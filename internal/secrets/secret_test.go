@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeSecret(t *testing.T, path string, value string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func values(secret *Secret) []string {
+	var values []string
+	for _, value := range secret.Values() {
+		values = append(values, string(value))
+	}
+	return values
+}
+
+func TestSecretKeepsPreviousValueDuringRotationWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook-secret")
+	writeSecret(t, path, "first")
+
+	secret, err := New(&FileSource{Path: path}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := secret.Reload(); err != nil || changed {
+		t.Fatalf("expected an unchanged secret, got changed %t and error %v", changed, err)
+	}
+	if got := values(secret); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Fatalf("expected only the current value before a rotation, got %v", got)
+	}
+
+	writeSecret(t, path, "second")
+	if changed, err := secret.Reload(); err != nil || !changed {
+		t.Fatalf("expected the secret to change, got changed %t and error %v", changed, err)
+	}
+	if string(secret.Value()) != "second" {
+		t.Errorf("expected the new value, got %q", secret.Value())
+	}
+	if got := values(secret); !reflect.DeepEqual(got, []string{"second", "first"}) {
+		t.Errorf("expected the previous value inside the rotation window, got %v", got)
+	}
+
+	// Once the window is over only the new value is accepted
+	secret.rotatedAt = time.Now().Add(-time.Hour)
+	if got := values(secret); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("expected only the current value after the rotation window, got %v", got)
+	}
+}
+
+func TestSecretWithoutRotationWindowDropsPreviousValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook-secret")
+	writeSecret(t, path, "first")
+
+	secret, err := New(&FileSource{Path: path}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSecret(t, path, "second")
+	if _, err := secret.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := values(secret); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("expected only the current value, got %v", got)
+	}
+}
+
+func TestSecretReloadKeepsValueOnInvalidChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "private-key")
+	writeSecret(t, path, "valid key")
+	validate := func(value []byte) error {
+		if string(value) != "valid key" {
+			return errors.New("not a key")
+		}
+		return nil
+	}
+
+	secret, err := New(&FileSource{Path: path}, validate, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, value := range map[string]string{"invalid": "half written key", "empty": "\n"} {
+		writeSecret(t, path, value)
+		if changed, err := secret.Reload(); err == nil || changed {
+			t.Errorf("expected the %s value to be rejected, got changed %t", name, changed)
+		}
+		if got := values(secret); !reflect.DeepEqual(got, []string{"valid key"}) {
+			t.Errorf("expected the current value to be kept after the %s value, got %v", name, got)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secret.Reload(); err == nil {
+		t.Error("expected an error once the file is gone")
+	}
+	if string(secret.Value()) != "valid key" {
+		t.Errorf("expected the current value to be kept, got %q", secret.Value())
+	}
+}
+
+func TestNewRejectsInvalidSecret(t *testing.T) {
+	validate := func([]byte) error { return errors.New("not a key") }
+	if _, err := New(&LiteralSource{value: []byte("garbage")}, validate, time.Hour); err == nil {
+		t.Error("expected an invalid secret to be rejected")
+	}
+	if _, err := New(&LiteralSource{}, nil, time.Hour); err == nil {
+		t.Error("expected an empty secret to be rejected")
+	}
+}
+
+func TestSecretDoesNotLogItsValue(t *testing.T) {
+	t.Setenv("SECRETS_TEST_LOGGED", "hunter2")
+	secret, err := New(&EnvSource{Name: "SECRETS_TEST_LOGGED"}, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged := secret.LogValue().String(); logged != "env:SECRETS_TEST_LOGGED" {
+		t.Errorf("expected the source to be logged, got %q", logged)
+	}
+}
@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefixes of secret references, a value without one of them is the secret itself
+const (
+	ENV_PREFIX        = "env:"
+	BASE64_ENV_PREFIX = "base64env:"
+	FILE_PREFIX       = "file:"
+	ENCRYPTED_PREFIX  = "encrypted:"
+)
+
+// Source is somewhere a secret can be read from
+type Source interface {
+	// Load reads the current value of the secret
+	Load() ([]byte, error)
+	// String describes where the secret comes from without revealing it
+	String() string
+}
+
+// ParseSource turns a reference like file:/run/secrets/key into its source, anything without a known prefix
+// is a literal secret. encryptionKey is only needed for encrypted: references.
+func ParseSource(reference string, encryptionKey *[KEY_SIZE]byte) (Source, error) {
+	switch {
+	case strings.HasPrefix(reference, ENV_PREFIX):
+		return &EnvSource{Name: strings.TrimPrefix(reference, ENV_PREFIX)}, nil
+	case strings.HasPrefix(reference, BASE64_ENV_PREFIX):
+		return &Base64EnvSource{Name: strings.TrimPrefix(reference, BASE64_ENV_PREFIX)}, nil
+	case strings.HasPrefix(reference, FILE_PREFIX):
+		return &FileSource{Path: strings.TrimPrefix(reference, FILE_PREFIX)}, nil
+	case strings.HasPrefix(reference, ENCRYPTED_PREFIX):
+		if encryptionKey == nil {
+			return nil, fmt.Errorf("an encryption key is needed to read %s", reference)
+		}
+		return &EncryptedFileSource{Path: strings.TrimPrefix(reference, ENCRYPTED_PREFIX), Key: encryptionKey}, nil
+	default:
+		return &LiteralSource{value: []byte(reference)}, nil
+	}
+}
+
+// LiteralSource is a secret given directly in the config
+type LiteralSource struct {
+	value []byte
+}
+
+func (s *LiteralSource) Load() ([]byte, error) {
+	return s.value, nil
+}
+
+func (s *LiteralSource) String() string {
+	return "literal"
+}
+
+// EnvSource reads the secret from an environment variable
+type EnvSource struct {
+	Name string
+}
+
+func (s *EnvSource) Load() ([]byte, error) {
+	value, ok := os.LookupEnv(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", s.Name)
+	}
+	return []byte(value), nil
+}
+
+func (s *EnvSource) String() string {
+	return ENV_PREFIX + s.Name
+}
+
+// Base64EnvSource reads a base64 encoded secret from an environment variable, handy for multi line keys
+type Base64EnvSource struct {
+	Name string
+}
+
+func (s *Base64EnvSource) Load() ([]byte, error) {
+	value, ok := os.LookupEnv(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", s.Name)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s as base64: %w", s.Name, err)
+	}
+	return decoded, nil
+}
+
+func (s *Base64EnvSource) String() string {
+	return BASE64_ENV_PREFIX + s.Name
+}
+
+// FileSource reads the secret from a file, such as a mounted kubernetes or docker secret
+type FileSource struct {
+	Path string
+}
+
+func (s *FileSource) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+	// Editors add a trailing newline which isn't part of tokens
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+func (s *FileSource) String() string {
+	return FILE_PREFIX + s.Path
+}
+
+// EncryptedFileSource reads a secret encrypted with Encrypt from a file, so it can be committed or copied around
+type EncryptedFileSource struct {
+	Path string
+	Key  *[KEY_SIZE]byte
+}
+
+func (s *EncryptedFileSource) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted secret file: %w", err)
+	}
+
+	secret, err := Decrypt(data, s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", s.Path, err)
+	}
+	return secret, nil
+}
+
+func (s *EncryptedFileSource) String() string {
+	return ENCRYPTED_PREFIX + s.Path
+}
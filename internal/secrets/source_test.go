@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSource(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	plainFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(plainFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := Encrypt([]byte("encrypted-token"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedFile := filepath.Join(dir, "token.enc")
+	if err := os.WriteFile(encryptedFile, encrypted, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SECRETS_TEST_TOKEN", "env-token")
+	t.Setenv("SECRETS_TEST_BASE64_TOKEN", base64.StdEncoding.EncodeToString([]byte("line one\nline two")))
+	t.Setenv("SECRETS_TEST_NOT_BASE64", "not base64!")
+
+	tests := []struct {
+		name      string
+		reference string
+		key       *[KEY_SIZE]byte
+		// Where the source says it reads from, and what it reads, a load error is expected when value is empty
+		source string
+		value  string
+	}{
+		{name: "literal", reference: "literal-token", source: "literal", value: "literal-token"},
+		{name: "env", reference: "env:SECRETS_TEST_TOKEN", source: "env:SECRETS_TEST_TOKEN", value: "env-token"},
+		{name: "unset env", reference: "env:SECRETS_TEST_UNSET", source: "env:SECRETS_TEST_UNSET"},
+		{name: "base64 env", reference: "base64env:SECRETS_TEST_BASE64_TOKEN", source: "base64env:SECRETS_TEST_BASE64_TOKEN", value: "line one\nline two"},
+		{name: "base64 env not encoded", reference: "base64env:SECRETS_TEST_NOT_BASE64", source: "base64env:SECRETS_TEST_NOT_BASE64"},
+		{name: "file without trailing newline", reference: "file:" + plainFile, source: "file:" + plainFile, value: "file-token"},
+		{name: "missing file", reference: "file:" + filepath.Join(dir, "missing"), source: "file:" + filepath.Join(dir, "missing")},
+		{name: "encrypted file", reference: "encrypted:" + encryptedFile, key: key, source: "encrypted:" + encryptedFile, value: "encrypted-token"},
+		{name: "encrypted plain file", reference: "encrypted:" + plainFile, key: key, source: "encrypted:" + plainFile},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := ParseSource(test.reference, test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source.String() != test.source {
+				t.Errorf("expected the source to be %q, got %q", test.source, source.String())
+			}
+
+			value, err := source.Load()
+			if test.value == "" {
+				if err == nil {
+					t.Errorf("expected an error loading the secret, got %q", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != test.value {
+				t.Errorf("expected %q, got %q", test.value, value)
+			}
+		})
+	}
+}
+
+func TestParseSourceNeedsKeyForEncryptedSecrets(t *testing.T) {
+	if _, err := ParseSource("encrypted:/run/secrets/token.enc", nil); err == nil {
+		t.Error("expected an error without an encryption key")
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TRACER_NAME = "github.com/RakibulBh/AI-pr-reviewer"
+
+// Attribute keys shared by the spans of a review
+const (
+	OwnerKey      = attribute.Key("github.owner")
+	RepoKey       = attribute.Key("github.repo")
+	PullNumberKey = attribute.Key("github.pull_number")
+	EventKey      = attribute.Key("github.event")
+	ActionKey     = attribute.Key("github.action")
+	DeliveryIDKey = attribute.Key("github.delivery_id")
+	RequestIDKey  = attribute.Key("http.request_id")
+	ModelKey      = attribute.Key("llm.model")
+)
+
+// Start starts a span using the global tracer provider, which is a no-op until tracing is configured
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// PullRequestAttributes identifies the pull request a span belongs to
+func PullRequestAttributes(owner string, repo string, pullNumber int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		OwnerKey.String(owner),
+		RepoKey.String(repo),
+		PullNumberKey.Int(pullNumber),
+	}
+}
+
+// TokenAttributes records the tokens used by an LLM call
+func TokenAttributes(promptTokens int, completionTokens int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("llm.prompt_tokens", promptTokens),
+		attribute.Int("llm.completion_tokens", completionTokens),
+	}
+}
+
+// Sleep waits for the duration inside its own span, so waits show up in traces, and stops early if the context is done
+func Sleep(ctx context.Context, reason string, duration time.Duration) {
+	_, span := Start(ctx, "sleep", attribute.String("sleep.reason", reason), attribute.String("sleep.duration", duration.String()))
+	defer span.End()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// Detach returns a context without the parent's deadline or cancellation which keeps its span and values,
+// so work started by a request can continue in the background as part of the same trace
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
+	"github.com/google/go-github/v74/github"
+)
+
+// ErrInvalidRequest is returned for admin requests missing required fields
+var ErrInvalidRequest = errors.New("invalid request")
+
+// AdminUsecase controls the running service: jobs, installations, LLM usage and dry-run mode
+type AdminUsecase struct {
+	github        *GithubUsecase
+	installations *InstallationUsecase
+	budget        *BudgetUsecase
+	queue         *ReviewQueue
+	dryRun        *DryRunPolicy
+}
+
+func NewAdminUsecase(github *GithubUsecase, installations *InstallationUsecase, budget *BudgetUsecase, queue *ReviewQueue, dryRun *DryRunPolicy) *AdminUsecase {
+	return &AdminUsecase{
+		github:        github,
+		installations: installations,
+		budget:        budget,
+		queue:         queue,
+		dryRun:        dryRun,
+	}
+}
+
+// Rerun queues a new review of the pull request at the requested commit, it runs like an opened pull request
+// so comments already on the pull request are not repeated
+func (a *AdminUsecase) Rerun(ctx context.Context, request model.RerunRequest) (model.Job, error) {
+	owner, repo, ok := strings.Cut(request.Repo, "/")
+	if !ok || owner == "" || repo == "" || request.PullNumber <= 0 {
+		return model.Job{}, fmt.Errorf("%w: repo (owner/repo) and pull_number are required", ErrInvalidRequest)
+	}
+
+	event, err := a.github.rerunEvent(ctx, owner, repo, request.PullNumber, request.SHA)
+	if err != nil {
+		return model.Job{}, err
+	}
+
+	return a.queue.Submit(tracing.Detach(ctx), PullRequestJobName(event), func(ctx context.Context) error {
+		return a.github.PullRequestReviewer(ctx, event)
+	})
+}
+
+// Jobs lists the queued, running and recently finished jobs
+func (a *AdminUsecase) Jobs() []model.Job {
+	return a.queue.Jobs()
+}
+
+// CancelJob stops a queued or running job
+func (a *AdminUsecase) CancelJob(id int64) (model.Job, error) {
+	return a.queue.Cancel(id)
+}
+
+// Installations lists the registered installations with their dry-run settings
+func (a *AdminUsecase) Installations(ctx context.Context) ([]model.Installation, error) {
+	return a.installations.List(ctx)
+}
+
+// Usage returns the LLM usage and cost of every repository over the month (YYYY-MM), the current month when empty
+func (a *AdminUsecase) Usage(ctx context.Context, month string) ([]model.UsageSummary, error) {
+	if month == "" {
+		month = time.Now().UTC().Format(USAGE_MONTH_FORMAT)
+	}
+	return a.budget.Usage(ctx, month)
+}
+
+// DryRunSettings returns what dry-run mode is enabled for
+func (a *AdminUsecase) DryRunSettings() model.DryRunSettings {
+	return a.dryRun.Settings()
+}
+
+// SetDryRun turns dry-run mode on or off for an installation, a repository, or every review.
+// Changes last until the server restarts, the config is read again then.
+func (a *AdminUsecase) SetDryRun(update model.DryRunUpdate) (model.DryRunSettings, error) {
+	switch {
+	case update.InstallationID != 0 && update.Repo != "":
+		return model.DryRunSettings{}, fmt.Errorf("%w: set either installation_id or repo", ErrInvalidRequest)
+	case update.InstallationID != 0:
+		a.dryRun.SetInstallation(update.InstallationID, update.Enabled)
+	case update.Repo != "":
+		if owner, repo, ok := strings.Cut(update.Repo, "/"); !ok || owner == "" || repo == "" {
+			return model.DryRunSettings{}, fmt.Errorf("%w: repo must be owner/repo", ErrInvalidRequest)
+		}
+		a.dryRun.SetRepo(update.Repo, update.Enabled)
+	default:
+		a.dryRun.SetGlobal(update.Enabled)
+	}
+
+	return a.dryRun.Settings(), nil
+}
+
+// rerunEvent builds the pull request event a review is run from, at the current head. Files are only listed at
+// the head, so a sha other than the head is refused instead of posting comments on lines of another commit.
+func (g *GithubUsecase) rerunEvent(ctx context.Context, owner, repo string, pullNumber int, sha string) (*github.PullRequestEvent, error) {
+	appClient, err := g.newAppClient()
+	if err != nil {
+		return nil, err
+	}
+
+	installation, err := g.repository.FindRepositoryInstallation(ctx, appClient, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("error finding the installation of %s/%s: %w", owner, repo, err)
+	}
+
+	client, err := g.newInstallationClient(ctx, installation.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	pullRequest, err := g.repository.GetPullRequest(ctx, client, owner, repo, pullNumber)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pull request %s/%s#%d: %w", owner, repo, pullNumber, err)
+	}
+	if head := pullRequest.GetHead().GetSHA(); sha != "" && !strings.EqualFold(sha, head) {
+		return nil, fmt.Errorf("%w: %s is not the head of %s/%s#%d, only the head commit %s can be reviewed again", ErrInvalidRequest, sha, owner, repo, pullNumber, head)
+	}
+
+	return &github.PullRequestEvent{
+		Action:       github.Ptr(RERUN_ACTION),
+		Number:       github.Ptr(pullNumber),
+		PullRequest:  pullRequest,
+		Repo:         pullRequest.GetBase().GetRepo(),
+		Installation: installation,
+	}, nil
+}
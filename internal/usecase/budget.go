@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/google/go-github/v74/github"
+)
+
+// How much of the diff is sent to the LLM when summarising a pull request, the rest is cut off
+const SUMMARY_MAX_DIFF_CHARS = 60000
+
+// checkBudget returns the budget state of the repository, when it can't be checked reviews go ahead as usual
+func (g *GithubUsecase) checkBudget(ctx context.Context, installationID int64, owner, repo string) model.BudgetStatus {
+	status, err := g.budget.Check(ctx, installationID, owner, repo)
+	if err != nil {
+		slog.Warn("error checking budget, continuing without it", "error", err, "owner", owner, "repo", repo)
+		return model.BudgetStatus{}
+	}
+
+	if status.Exceeded {
+		slog.Info("monthly budget exceeded",
+			"owner", owner,
+			"repo", repo,
+			"installationID", installationID,
+			"installation_spend_usd", status.InstallationSpendUSD,
+			"installation_limit_usd", status.InstallationLimitUSD,
+			"repo_spend_usd", status.RepoSpendUSD,
+			"repo_limit_usd", status.RepoLimitUSD,
+			"action", status.Action)
+	}
+
+	return status
+}
+
+// recordLLMCall stores the tokens a call used, call holds who the call was made for
+func (g *GithubUsecase) recordLLMCall(ctx context.Context, call model.LLMCall, usage model.TokenUsage) {
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		return
+	}
+
+	call.PromptTokens = usage.PromptTokens
+	call.CompletionTokens = usage.CompletionTokens
+	call.CachedPromptTokens = usage.CachedPromptTokens
+	g.budget.RecordCall(ctx, &call)
+}
+
+// summarizePullRequest posts a single summary of the pull request instead of reviewing it
+func (g *GithubUsecase) summarizePullRequest(ctx context.Context, client *github.Client, run *model.ReviewRun, status model.BudgetStatus, promptCtx model.PromptContext) error {
+	metrics.BudgetExceeded.WithLabelValues(model.BUDGET_ACTION_SUMMARY_ONLY).Inc()
+
+	var diff strings.Builder
+	for pageCount := 1; diff.Len() < SUMMARY_MAX_DIFF_CHARS; pageCount++ {
+		files, err := g.repository.ListPullRequestFiles(ctx, client, run.Owner, run.Repo, run.PullNumber, pageCount)
+		if err != nil {
+			return err
+		}
+		if len(files) <= 0 {
+			break
+		}
+		prFiles, _ := g.redactor.RedactFiles(toPRFiles(files))
+		diff.WriteString(formatFilesForLLM(prFiles))
+	}
+
+	code := diff.String()
+	if len(code) > SUMMARY_MAX_DIFF_CHARS {
+		code = strings.ToValidUTF8(code[:SUMMARY_MAX_DIFF_CHARS], "") + "\n... the rest of the diff was cut off"
+	}
+
+	llm := g.budget.SummaryLLM(g.llm)
+	run.Model = llm.Model()
+	summary, usage, err := llm.Summarize(ctx, code, promptCtx)
+	run.Usage.Add(usage)
+	g.recordLLMCall(ctx, model.LLMCall{
+		RunID:          run.ID,
+		InstallationID: run.InstallationID,
+		Owner:          run.Owner,
+		Repo:           run.Repo,
+		Model:          llm.Model(),
+		Operation:      model.LLM_OPERATION_SUMMARY,
+	}, usage)
+	if err != nil {
+		return fmt.Errorf("error summarising pull request: %w", err)
+	}
+
+	// The summary is checked like review comments, only the notice is posted when it is rejected
+	if reason := g.outputPolicy.CheckBody(summary); reason != "" {
+		slog.Warn("rejecting summary generated by the LLM", "reason", reason, "owner", run.Owner, "repo", run.Repo, "pullNumber", run.PullNumber)
+		return g.postBudgetComment(ctx, client, run, budgetNotice(status, "so this pull request was not reviewed"))
+	}
+
+	body := budgetNotice(status, "so this pull request was summarised instead of reviewed") + "\n\n" + summary
+	return g.postBudgetComment(ctx, client, run, body)
+}
+
+// skipReview posts a notice explaining why the pull request wasn't reviewed
+func (g *GithubUsecase) skipReview(ctx context.Context, client *github.Client, run *model.ReviewRun, status model.BudgetStatus) error {
+	metrics.BudgetExceeded.WithLabelValues(model.BUDGET_ACTION_SKIP).Inc()
+
+	body := budgetNotice(status, "so this pull request was not reviewed") + " Reviews resume at the start of next month, or once the budget is raised."
+	return g.postBudgetComment(ctx, client, run, body)
+}
+
+func (g *GithubUsecase) postBudgetComment(ctx context.Context, client *github.Client, run *model.ReviewRun, body string) error {
+	if run.DryRun {
+		slog.Info("dry run, not posting budget notice", "owner", run.Owner, "repo", run.Repo, "pullNumber", run.PullNumber, "body", body)
+		return nil
+	}
+
+	_, err := g.repository.CreateIssueComment(ctx, client, run.Owner, run.Repo, run.PullNumber, body)
+	if err != nil {
+		return fmt.Errorf("error posting budget notice: %w", err)
+	}
+
+	return nil
+}
+
+// budgetNotice explains which budget ran out, consequence finishes the sentence
+func budgetNotice(status model.BudgetStatus, consequence string) string {
+	scope, spend, limit := "installation", status.InstallationSpendUSD, status.InstallationLimitUSD
+	if status.RepoLimitUSD > 0 && status.RepoSpendUSD >= status.RepoLimitUSD {
+		scope, spend, limit = "repository", status.RepoSpendUSD, status.RepoLimitUSD
+	}
+
+	return fmt.Sprintf("**AI review budget exceeded**: this %s has used $%.2f of its $%.2f monthly budget, %s.", scope, spend, limit, consequence)
+}
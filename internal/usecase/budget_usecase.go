@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+)
+
+// Format of the months usage is reported for
+const USAGE_MONTH_FORMAT = "2006-01"
+
+// DEFAULT_PRICES are the list prices of the gemini models in USD per million tokens, models which aren't listed
+// (like local ollama models) are free unless a price is configured. The hourly storage of cached contexts isn't
+// included.
+var DEFAULT_PRICES = PriceTable{
+	"gemini-2.5-pro":        {PromptPerMillion: 1.25, CompletionPerMillion: 10, CachedPromptPerMillion: 0.125},
+	"gemini-2.5-flash":      {PromptPerMillion: 0.30, CompletionPerMillion: 2.50, CachedPromptPerMillion: 0.03},
+	"gemini-2.5-flash-lite": {PromptPerMillion: 0.10, CompletionPerMillion: 0.40, CachedPromptPerMillion: 0.01},
+	"gemini-2.0-flash":      {PromptPerMillion: 0.10, CompletionPerMillion: 0.40, CachedPromptPerMillion: 0.025},
+	"gemini-2.0-flash-lite": {PromptPerMillion: 0.075, CompletionPerMillion: 0.30, CachedPromptPerMillion: 0.075},
+	"gemini-1.5-pro":        {PromptPerMillion: 1.25, CompletionPerMillion: 5, CachedPromptPerMillion: 0.3125},
+	"gemini-1.5-flash":      {PromptPerMillion: 0.075, CompletionPerMillion: 0.30, CachedPromptPerMillion: 0.01875},
+}
+
+// PriceTable maps model names to their price
+type PriceTable map[string]model.ModelPrice
+
+// ParsePriceTable adds prices from a comma separated list of model=prompt/completion[/cached], in USD per million
+// tokens, to the default prices. Cached prompt tokens cost the same as other prompt tokens when no cached price
+// is given.
+func ParsePriceTable(prices string) (PriceTable, error) {
+	table := PriceTable{}
+	for name, price := range DEFAULT_PRICES {
+		table[name] = price
+	}
+
+	for _, value := range splitList(prices) {
+		name, price, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid price %q, expected model=prompt/completion[/cached]", value)
+		}
+		parts := strings.Split(price, "/")
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, fmt.Errorf("invalid price %q, expected model=prompt/completion[/cached]", value)
+		}
+
+		prompt, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil || prompt < 0 {
+			return nil, fmt.Errorf("invalid prompt price in %q", value)
+		}
+		completion, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || completion < 0 {
+			return nil, fmt.Errorf("invalid completion price in %q", value)
+		}
+		cached := prompt
+		if len(parts) == 3 {
+			cached, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+			if err != nil || cached < 0 {
+				return nil, fmt.Errorf("invalid cached prompt price in %q", value)
+			}
+		}
+
+		table[normalizeModelName(name)] = model.ModelPrice{PromptPerMillion: prompt, CompletionPerMillion: completion, CachedPromptPerMillion: cached}
+	}
+
+	return table, nil
+}
+
+// Has reports whether the model has a price
+func (p PriceTable) Has(llmModel string) bool {
+	_, ok := p[normalizeModelName(llmModel)]
+	return ok
+}
+
+// Cost returns the price of the tokens in USD, 0 for models without a price. Cached prompt tokens are part of
+// the prompt tokens and billed at the cached price.
+func (p PriceTable) Cost(llmModel string, usage model.TokenUsage) float64 {
+	price := p[normalizeModelName(llmModel)]
+	cached := min(usage.CachedPromptTokens, usage.PromptTokens)
+	return (float64(usage.PromptTokens-cached)*price.PromptPerMillion + float64(cached)*price.CachedPromptPerMillion +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion) / 1e6
+}
+
+// normalizeModelName drops the models/ prefix gemini accepts
+func normalizeModelName(llmModel string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(llmModel), "models/"))
+}
+
+// BudgetPolicy holds the monthly budgets in USD, a budget of 0 is unlimited.
+// Overrides replace the default budget of a single installation or owner/repo.
+type BudgetPolicy struct {
+	installationLimit     float64
+	repoLimit             float64
+	installationOverrides map[int64]float64
+	repoOverrides         map[string]float64
+	action                string
+}
+
+// ParseBudgetPolicy builds the policy from config values, overrides is a comma separated list of
+// installationID=USD or owner/repo=USD
+func ParseBudgetPolicy(installationLimit string, repoLimit string, overrides string, action string) (*BudgetPolicy, error) {
+	policy := &BudgetPolicy{
+		installationOverrides: map[int64]float64{},
+		repoOverrides:         map[string]float64{},
+		action:                action,
+	}
+
+	var err error
+	policy.installationLimit, err = parseBudget(installationLimit)
+	if err != nil {
+		return nil, err
+	}
+	policy.repoLimit, err = parseBudget(repoLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range splitList(overrides) {
+		scope, limit, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid budget %q, expected installationID=USD or owner/repo=USD", value)
+		}
+		amount, err := parseBudget(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		scope = strings.TrimSpace(scope)
+		if strings.Contains(scope, "/") {
+			policy.repoOverrides[strings.ToLower(scope)] = amount
+			continue
+		}
+		installationID, err := strconv.ParseInt(scope, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget %q, expected installationID=USD or owner/repo=USD", value)
+		}
+		policy.installationOverrides[installationID] = amount
+	}
+
+	switch action {
+	case model.BUDGET_ACTION_SMALLER_MODEL, model.BUDGET_ACTION_SUMMARY_ONLY, model.BUDGET_ACTION_SKIP:
+	default:
+		return nil, fmt.Errorf("unknown action %q, expected %s, %s or %s", action, model.BUDGET_ACTION_SMALLER_MODEL, model.BUDGET_ACTION_SUMMARY_ONLY, model.BUDGET_ACTION_SKIP)
+	}
+
+	return policy, nil
+}
+
+func parseBudget(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	amount, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid budget %q, expected an amount in USD", value)
+	}
+	return amount, nil
+}
+
+// Enabled reports whether any budget is set
+func (b *BudgetPolicy) Enabled() bool {
+	return b.installationLimit > 0 || b.repoLimit > 0 || len(b.installationOverrides) > 0 || len(b.repoOverrides) > 0
+}
+
+// Action returns what happens to reviews while a budget is exceeded
+func (b *BudgetPolicy) Action() string {
+	return b.action
+}
+
+// Limits returns the monthly budgets of the installation and of owner/repo
+func (b *BudgetPolicy) Limits(installationID int64, fullName string) (installationLimit float64, repoLimit float64) {
+	installationLimit = b.installationLimit
+	if limit, ok := b.installationOverrides[installationID]; ok {
+		installationLimit = limit
+	}
+
+	repoLimit = b.repoLimit
+	if limit, ok := b.repoOverrides[strings.ToLower(fullName)]; ok {
+		repoLimit = limit
+	}
+
+	return installationLimit, repoLimit
+}
+
+// BudgetUsecase records what LLM calls cost and checks spend against the monthly budgets
+type BudgetUsecase struct {
+	costs  repository.CostRepository
+	prices PriceTable
+	policy *BudgetPolicy
+	// Reviews use this model once a budget is exceeded with the smaller_model action, it may be nil otherwise
+	fallback LLMRepository
+}
+
+func NewBudgetUsecase(costs repository.CostRepository, prices PriceTable, policy *BudgetPolicy, fallback LLMRepository) *BudgetUsecase {
+	return &BudgetUsecase{
+		costs:    costs,
+		prices:   prices,
+		policy:   policy,
+		fallback: fallback,
+	}
+}
+
+// RecordCall prices the call and stores it, failures are logged as the call has already been made
+func (b *BudgetUsecase) RecordCall(ctx context.Context, call *model.LLMCall) {
+	call.CostUSD = b.prices.Cost(call.Model, model.TokenUsage{
+		PromptTokens:       call.PromptTokens,
+		CompletionTokens:   call.CompletionTokens,
+		CachedPromptTokens: call.CachedPromptTokens,
+	})
+	call.CreatedAt = time.Now()
+
+	metrics.LLMCost.WithLabelValues(call.Model).Add(call.CostUSD)
+
+	if err := b.costs.RecordLLMCall(context.WithoutCancel(ctx), call); err != nil {
+		slog.Error("error recording llm call", "error", err, "model", call.Model, "operation", call.Operation, "cost_usd", call.CostUSD)
+	}
+}
+
+// Check compares the spend of the installation and owner/repo this month with their budgets
+func (b *BudgetUsecase) Check(ctx context.Context, installationID int64, owner, repo string) (model.BudgetStatus, error) {
+	installationLimit, repoLimit := b.policy.Limits(installationID, owner+"/"+repo)
+	status := model.BudgetStatus{
+		InstallationLimitUSD: installationLimit,
+		RepoLimitUSD:         repoLimit,
+	}
+	if installationLimit == 0 && repoLimit == 0 {
+		return status, nil
+	}
+
+	var err error
+	status.InstallationSpendUSD, status.RepoSpendUSD, err = b.costs.SpentSince(ctx, installationID, owner, repo, monthStart(time.Now()))
+	if err != nil {
+		return status, err
+	}
+
+	status.Exceeded = (installationLimit > 0 && status.InstallationSpendUSD >= installationLimit) ||
+		(repoLimit > 0 && status.RepoSpendUSD >= repoLimit)
+	if status.Exceeded {
+		status.Action = b.policy.Action()
+	}
+
+	return status, nil
+}
+
+// LLMFor returns the model to use while the budget is in the given state, nil when nothing should be generated
+func (b *BudgetUsecase) LLMFor(status model.BudgetStatus, llm LLMRepository) LLMRepository {
+	if !status.Exceeded {
+		return llm
+	}
+	if status.Action == model.BUDGET_ACTION_SMALLER_MODEL && b.fallback != nil {
+		return b.fallback
+	}
+	return nil
+}
+
+// SummaryLLM returns the model pull requests are summarised with, the fallback model when there is one
+func (b *BudgetUsecase) SummaryLLM(llm LLMRepository) LLMRepository {
+	if b.fallback != nil {
+		return b.fallback
+	}
+	return llm
+}
+
+// Usage sums the LLM usage of every repository over the month, given as YYYY-MM
+func (b *BudgetUsecase) Usage(ctx context.Context, month string) ([]model.UsageSummary, error) {
+	since, err := time.Parse(USAGE_MONTH_FORMAT, month)
+	if err != nil {
+		return nil, fmt.Errorf("%w: month must be YYYY-MM", ErrInvalidRequest)
+	}
+
+	summaries, err := b.costs.ListUsage(ctx, since, since.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		summaries[i].Month = month
+	}
+
+	return summaries, nil
+}
+
+// monthStart returns the start of the month in UTC, budgets reset then
+func monthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
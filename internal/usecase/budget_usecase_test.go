@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// fakeCosts returns fixed spends and keeps the calls recorded
+type fakeCosts struct {
+	installationSpend float64
+	repoSpend         float64
+	queried           bool
+	calls             []model.LLMCall
+}
+
+func (f *fakeCosts) RecordLLMCall(ctx context.Context, call *model.LLMCall) error {
+	f.calls = append(f.calls, *call)
+	return nil
+}
+
+func (f *fakeCosts) SpentSince(ctx context.Context, installationID int64, owner, repo string, since time.Time) (float64, float64, error) {
+	f.queried = true
+	return f.installationSpend, f.repoSpend, nil
+}
+
+func (f *fakeCosts) ListUsage(ctx context.Context, since time.Time, until time.Time) ([]model.UsageSummary, error) {
+	return nil, nil
+}
+
+func TestParsePriceTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		prices   string
+		model    string
+		expected model.ModelPrice
+		invalid  bool
+	}{
+		{name: "defaults", model: "gemini-2.5-flash", expected: DEFAULT_PRICES["gemini-2.5-flash"]},
+		{
+			name:     "cached prompt tokens cost the prompt price by default",
+			prices:   "llama3=0.2/0.6",
+			model:    "llama3",
+			expected: model.ModelPrice{PromptPerMillion: 0.2, CompletionPerMillion: 0.6, CachedPromptPerMillion: 0.2},
+		},
+		{
+			name:     "cached price",
+			prices:   " llama3 = 0.2 / 0.6 / 0.05 ",
+			model:    "llama3",
+			expected: model.ModelPrice{PromptPerMillion: 0.2, CompletionPerMillion: 0.6, CachedPromptPerMillion: 0.05},
+		},
+		{
+			name:     "overrides a default price",
+			prices:   "models/Gemini-2.5-Pro=2/12/0.5",
+			model:    "gemini-2.5-pro",
+			expected: model.ModelPrice{PromptPerMillion: 2, CompletionPerMillion: 12, CachedPromptPerMillion: 0.5},
+		},
+		{name: "missing model", prices: "=1/2", invalid: true},
+		{name: "missing completion price", prices: "llama3=1", invalid: true},
+		{name: "too many prices", prices: "llama3=1/2/3/4", invalid: true},
+		{name: "negative price", prices: "llama3=-1/2", invalid: true},
+		{name: "invalid cached price", prices: "llama3=1/2/free", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table, err := ParsePriceTable(test.prices)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected %q to be rejected", test.prices)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if price := table["gemini-2.0-flash"]; price != DEFAULT_PRICES["gemini-2.0-flash"] {
+				t.Errorf("expected the default prices to be kept, got %+v", price)
+			}
+			if !table.Has(test.model) {
+				t.Fatalf("expected a price for %s", test.model)
+			}
+			if price := table[test.model]; price != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, price)
+			}
+		})
+	}
+}
+
+func TestPriceTableCost(t *testing.T) {
+	prices := PriceTable{"gemini-2.5-pro": {PromptPerMillion: 1, CompletionPerMillion: 10, CachedPromptPerMillion: 0.25}}
+
+	tests := []struct {
+		name     string
+		model    string
+		usage    model.TokenUsage
+		expected float64
+	}{
+		{name: "prompt and completion", model: "gemini-2.5-pro", usage: model.TokenUsage{PromptTokens: 2_000_000, CompletionTokens: 100_000}, expected: 3},
+		{
+			name:     "cached prompt tokens at the cached price",
+			model:    "models/gemini-2.5-pro",
+			usage:    model.TokenUsage{PromptTokens: 2_000_000, CachedPromptTokens: 1_600_000, CompletionTokens: 100_000},
+			expected: 0.4 + 0.4 + 1,
+		},
+		{name: "model without a price", model: "llama3", usage: model.TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 1_000_000}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cost := prices.Cost(test.model, test.usage); math.Abs(cost-test.expected) > 1e-9 {
+				t.Errorf("expected $%f, got $%f", test.expected, cost)
+			}
+		})
+	}
+}
+
+func TestParseBudgetPolicy(t *testing.T) {
+	policy, err := ParseBudgetPolicy("100", "$20", "42=500, octo/API=5, 7=0", model.BUDGET_ACTION_SKIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.Enabled() || policy.Action() != model.BUDGET_ACTION_SKIP {
+		t.Errorf("expected an enabled policy skipping reviews, got %+v", policy)
+	}
+
+	tests := []struct {
+		name           string
+		installationID int64
+		fullName       string
+		installation   float64
+		repo           float64
+	}{
+		{name: "global budgets", installationID: 1, fullName: "octo/web", installation: 100, repo: 20},
+		{name: "installation override", installationID: 42, fullName: "octo/web", installation: 500, repo: 20},
+		{name: "repository override ignores case", installationID: 1, fullName: "Octo/Api", installation: 100, repo: 5},
+		{name: "unlimited override", installationID: 7, fullName: "octo/api", installation: 0, repo: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			installation, repo := policy.Limits(test.installationID, test.fullName)
+			if installation != test.installation || repo != test.repo {
+				t.Errorf("expected $%v and $%v, got $%v and $%v", test.installation, test.repo, installation, repo)
+			}
+		})
+	}
+
+	unlimited, err := ParseBudgetPolicy("0", "", "", model.BUDGET_ACTION_SMALLER_MODEL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unlimited.Enabled() {
+		t.Error("expected a policy without budgets to be disabled")
+	}
+
+	for _, invalid := range []struct{ installation, repo, overrides, action string }{
+		{installation: "lots", action: model.BUDGET_ACTION_SKIP},
+		{repo: "-5", action: model.BUDGET_ACTION_SKIP},
+		{overrides: "octo/api", action: model.BUDGET_ACTION_SKIP},
+		{overrides: "octo=5", action: model.BUDGET_ACTION_SKIP},
+		{action: "block"},
+	} {
+		if _, err := ParseBudgetPolicy(invalid.installation, invalid.repo, invalid.overrides, invalid.action); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestBudgetCheck(t *testing.T) {
+	tests := []struct {
+		name              string
+		installationLimit string
+		repoLimit         string
+		action            string
+		installationSpend float64
+		repoSpend         float64
+		exceeded          bool
+	}{
+		{name: "unlimited", action: model.BUDGET_ACTION_SKIP, installationSpend: 1000},
+		{name: "under budget", installationLimit: "100", repoLimit: "20", action: model.BUDGET_ACTION_SKIP, installationSpend: 50, repoSpend: 19.99},
+		{name: "installation over budget", installationLimit: "100", action: model.BUDGET_ACTION_SKIP, installationSpend: 100, exceeded: true},
+		{name: "repository over budget", installationLimit: "100", repoLimit: "20", action: model.BUDGET_ACTION_SMALLER_MODEL, installationSpend: 30, repoSpend: 25, exceeded: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := ParseBudgetPolicy(test.installationLimit, test.repoLimit, "", test.action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			costs := &fakeCosts{installationSpend: test.installationSpend, repoSpend: test.repoSpend}
+			budget := NewBudgetUsecase(costs, DEFAULT_PRICES, policy, nil)
+
+			status, err := budget.Check(context.Background(), 1, "octo", "api")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Exceeded != test.exceeded {
+				t.Errorf("expected exceeded to be %t, got %+v", test.exceeded, status)
+			}
+			if test.exceeded && status.Action != test.action {
+				t.Errorf("expected the %s action, got %q", test.action, status.Action)
+			}
+			if !test.exceeded && status.Action != "" {
+				t.Errorf("expected no action under budget, got %q", status.Action)
+			}
+			if costs.queried != policy.Enabled() {
+				t.Errorf("expected spend to be queried only with a budget, queried %t", costs.queried)
+			}
+		})
+	}
+}
+
+func TestBudgetLLMFor(t *testing.T) {
+	llm, fallback := &countingLLM{}, &countingLLM{}
+
+	tests := []struct {
+		name     string
+		status   model.BudgetStatus
+		fallback LLMRepository
+		expected LLMRepository
+	}{
+		{name: "under budget", status: model.BudgetStatus{}, fallback: fallback, expected: llm},
+		{name: "smaller model", status: model.BudgetStatus{Exceeded: true, Action: model.BUDGET_ACTION_SMALLER_MODEL}, fallback: fallback, expected: fallback},
+		{name: "smaller model without a fallback", status: model.BudgetStatus{Exceeded: true, Action: model.BUDGET_ACTION_SMALLER_MODEL}},
+		{name: "summary only", status: model.BudgetStatus{Exceeded: true, Action: model.BUDGET_ACTION_SUMMARY_ONLY}, fallback: fallback},
+		{name: "skip", status: model.BudgetStatus{Exceeded: true, Action: model.BUDGET_ACTION_SKIP}, fallback: fallback},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			budget := NewBudgetUsecase(&fakeCosts{}, DEFAULT_PRICES, &BudgetPolicy{}, test.fallback)
+			if got := budget.LLMFor(test.status, llm); got != test.expected {
+				t.Errorf("expected %p, got %p", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordCallPricesCachedPromptTokens(t *testing.T) {
+	costs := &fakeCosts{}
+	prices := PriceTable{"gemini-2.5-flash": {PromptPerMillion: 0.30, CompletionPerMillion: 2.50, CachedPromptPerMillion: 0.03}}
+	budget := NewBudgetUsecase(costs, prices, &BudgetPolicy{}, nil)
+
+	budget.RecordCall(context.Background(), &model.LLMCall{
+		Model:              "gemini-2.5-flash",
+		PromptTokens:       1_000_000,
+		CachedPromptTokens: 900_000,
+	})
+
+	if len(costs.calls) != 1 {
+		t.Fatalf("expected the call to be recorded, got %d calls", len(costs.calls))
+	}
+	if expected := 0.03 + 0.027; math.Abs(costs.calls[0].CostUSD-expected) > 1e-9 {
+		t.Errorf("expected $%f, got $%f", expected, costs.calls[0].CostUSD)
+	}
+}
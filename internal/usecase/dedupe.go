@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+)
+
+const (
+	// How many lines away from an existing comment a new comment is still considered the same spot
+	DUPLICATE_LINE_TOLERANCE = 3
+	// Minimum similarity for a new comment to be considered a repeat of a bot comment
+	BOT_DUPLICATE_THRESHOLD = 0.5
+	// Humans tend to be terse, so a lower similarity is enough to consider the issue raised
+	HUMAN_DUPLICATE_THRESHOLD = 0.35
+)
+
+// existingComment is the subset of a pull request review comment needed for deduplication
+type existingComment struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Body      string
+	IsBot     bool
+}
+
+// toExistingComments converts the comments on the pull request, skipping comments without a line
+func toExistingComments(comments []*github.PullRequestComment) []existingComment {
+	var existing []existingComment
+
+	for _, comment := range comments {
+		line := comment.GetLine()
+		startLine := comment.GetStartLine()
+		if line == 0 {
+			// Outdated comments no longer have a line, fall back to where they were made
+			line = comment.GetOriginalLine()
+			startLine = comment.GetOriginalStartLine()
+		}
+		if line == 0 {
+			continue
+		}
+		if startLine == 0 {
+			startLine = line
+		}
+
+		existing = append(existing, existingComment{
+			Path:      comment.GetPath(),
+			StartLine: startLine,
+			EndLine:   line,
+			Body:      comment.GetBody(),
+			IsBot:     comment.GetUser().GetType() == "Bot",
+		})
+	}
+
+	return existing
+}
+
+// findDuplicateComment returns the existing comment which already raises the same issue as the review, if any
+func findDuplicateComment(review model.ReviewCommentRequest, existing []existingComment) (existingComment, bool) {
+	for _, comment := range existing {
+		if comment.Path != review.Path {
+			continue
+		}
+		if review.Line < comment.StartLine-DUPLICATE_LINE_TOLERANCE || review.Line > comment.EndLine+DUPLICATE_LINE_TOLERANCE {
+			continue
+		}
+
+		threshold := HUMAN_DUPLICATE_THRESHOLD
+		if comment.IsBot {
+			threshold = BOT_DUPLICATE_THRESHOLD
+		}
+
+		if utils.TextSimilarity(review.Body, comment.Body) >= threshold {
+			return comment, true
+		}
+	}
+
+	return existingComment{}, false
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/google/go-github/v74/github"
+)
+
+func TestFindDuplicateComment(t *testing.T) {
+	const review = "IMPORTANT: The error returned by db.Close is silently ignored, which hides failures when flushing writes."
+
+	tests := []struct {
+		name     string
+		line     int
+		existing existingComment
+		expected bool
+	}{
+		{
+			name:     "near-duplicate by the bot",
+			line:     42,
+			existing: existingComment{Path: "store.go", StartLine: 42, EndLine: 42, Body: "IMPORTANT: error returned by db.Close is ignored, which hides failures when flushing writes.", IsBot: true},
+			expected: true,
+		},
+		{
+			name:     "terse human remark",
+			line:     42,
+			existing: existingComment{Path: "store.go", StartLine: 42, EndLine: 42, Body: "close error ignored?"},
+			expected: true,
+		},
+		{
+			name:     "terse remark by the bot isn't enough",
+			line:     42,
+			existing: existingComment{Path: "store.go", StartLine: 42, EndLine: 42, Body: "close error ignored?", IsBot: true},
+			expected: false,
+		},
+		{
+			name:     "different issue on the same line",
+			line:     42,
+			existing: existingComment{Path: "store.go", StartLine: 42, EndLine: 42, Body: "NIT: Consider renaming this variable to something clearer.", IsBot: true},
+			expected: false,
+		},
+		{
+			name:     "within the line tolerance",
+			line:     42 + DUPLICATE_LINE_TOLERANCE,
+			existing: existingComment{Path: "store.go", StartLine: 40, EndLine: 42, Body: review, IsBot: true},
+			expected: true,
+		},
+		{
+			name:     "before a multi-line comment, within the tolerance",
+			line:     40 - DUPLICATE_LINE_TOLERANCE,
+			existing: existingComment{Path: "store.go", StartLine: 40, EndLine: 42, Body: review, IsBot: true},
+			expected: true,
+		},
+		{
+			name:     "beyond the line tolerance",
+			line:     42 + DUPLICATE_LINE_TOLERANCE + 1,
+			existing: existingComment{Path: "store.go", StartLine: 40, EndLine: 42, Body: review, IsBot: true},
+			expected: false,
+		},
+		{
+			name:     "same comment on another file",
+			line:     42,
+			existing: existingComment{Path: "cache.go", StartLine: 42, EndLine: 42, Body: review, IsBot: true},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, found := findDuplicateComment(model.ReviewCommentRequest{Path: "store.go", Line: test.line, Body: review}, []existingComment{test.existing})
+			if found != test.expected {
+				t.Errorf("expected duplicate to be %v", test.expected)
+			}
+		})
+	}
+}
+
+func TestToExistingComments(t *testing.T) {
+	comments := []*github.PullRequestComment{
+		{Path: github.Ptr("a.go"), Line: github.Ptr(12), StartLine: github.Ptr(10), Body: github.Ptr("range"), User: &github.User{Type: github.Ptr("Bot")}},
+		{Path: github.Ptr("a.go"), Line: github.Ptr(5), Body: github.Ptr("single line"), User: &github.User{Type: github.Ptr("User")}},
+		// Outdated, where it was made is used instead
+		{Path: github.Ptr("b.go"), OriginalLine: github.Ptr(7), Body: github.Ptr("outdated")},
+		// On the whole file, not a line
+		{Path: github.Ptr("c.go"), Body: github.Ptr("file comment")},
+	}
+
+	got := toExistingComments(comments)
+	expected := []existingComment{
+		{Path: "a.go", StartLine: 10, EndLine: 12, Body: "range", IsBot: true},
+		{Path: "a.go", StartLine: 5, EndLine: 5, Body: "single line"},
+		{Path: "b.go", StartLine: 7, EndLine: 7, Body: "outdated"},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], got[i])
+		}
+	}
+}
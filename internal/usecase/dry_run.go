@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// DryRunPolicy decides whether a review runs in dry-run (shadow) mode, where nothing is posted to github.
+// Dry-run can be enabled globally, for an installation, or for a single owner/repo.
+type DryRunPolicy struct {
+	mu            sync.RWMutex
+	global        bool
+	installations map[int64]bool
+	repos         map[string]bool
+}
+
+func NewDryRunPolicy(global bool, installationIDs []int64, repos []string) *DryRunPolicy {
+	policy := &DryRunPolicy{
+		global:        global,
+		installations: map[int64]bool{},
+		repos:         map[string]bool{},
+	}
+
+	for _, installationID := range installationIDs {
+		policy.installations[installationID] = true
+	}
+	for _, repo := range repos {
+		policy.repos[strings.ToLower(repo)] = true
+	}
+
+	return policy
+}
+
+// ParseDryRunPolicy builds the policy from config values, installations and repos are comma separated lists
+func ParseDryRunPolicy(global string, installations string, repos string) (*DryRunPolicy, error) {
+	enabled := false
+	if global != "" {
+		var err error
+		enabled, err = strconv.ParseBool(global)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var installationIDs []int64
+	for _, value := range splitList(installations) {
+		installationID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		installationIDs = append(installationIDs, installationID)
+	}
+
+	return NewDryRunPolicy(enabled, installationIDs, splitList(repos)), nil
+}
+
+// Enabled reports whether reviews for the repository (owner/repo) should run in dry-run mode
+func (p *DryRunPolicy) Enabled(installationID int64, fullName string) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.global || p.installations[installationID] || p.repos[strings.ToLower(fullName)]
+}
+
+// SetGlobal turns dry-run mode on or off for every review
+func (p *DryRunPolicy) SetGlobal(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.global = enabled
+}
+
+// SetInstallation turns dry-run mode on or off for an installation
+func (p *DryRunPolicy) SetInstallation(installationID int64, enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if enabled {
+		p.installations[installationID] = true
+	} else {
+		delete(p.installations, installationID)
+	}
+}
+
+// SetRepo turns dry-run mode on or off for a repository (owner/repo)
+func (p *DryRunPolicy) SetRepo(fullName string, enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if enabled {
+		p.repos[strings.ToLower(fullName)] = true
+	} else {
+		delete(p.repos, strings.ToLower(fullName))
+	}
+}
+
+// Settings returns what dry-run mode is currently enabled for
+func (p *DryRunPolicy) Settings() model.DryRunSettings {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	settings := model.DryRunSettings{
+		Global:        p.global,
+		Installations: make([]int64, 0, len(p.installations)),
+		Repos:         make([]string, 0, len(p.repos)),
+	}
+	for installationID := range p.installations {
+		settings.Installations = append(settings.Installations, installationID)
+	}
+	for repo := range p.repos {
+		settings.Repos = append(settings.Repos, repo)
+	}
+	slices.Sort(settings.Installations)
+	slices.Sort(settings.Repos)
+
+	return settings
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,237 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// How many lines a comment may be from an expected finding and still match it, LLMs are often a line or two off
+const DEFAULT_EVAL_LINE_TOLERANCE = 3
+
+// EvalUsecase runs the local review pipeline over recorded pull requests and scores the comments
+// against the findings each one is expected to raise
+type EvalUsecase struct {
+	review *LocalReviewUsecase
+	config model.EvalConfig
+}
+
+func NewEvalUsecase(review *LocalReviewUsecase, config model.EvalConfig) *EvalUsecase {
+	if config.LineTolerance < 0 {
+		config.LineTolerance = DEFAULT_EVAL_LINE_TOLERANCE
+	}
+
+	return &EvalUsecase{
+		review: review,
+		config: config,
+	}
+}
+
+// Run reviews every fixture in turn. Fixtures the pipeline fails on are scored as reviews without comments,
+// only cancelling ctx stops the run early.
+func (e *EvalUsecase) Run(ctx context.Context, fixtures []model.EvalFixture) (model.EvalRun, error) {
+	run := model.EvalRun{
+		Config:    e.config,
+		StartedAt: time.Now().UTC(),
+		Fixtures:  make([]model.EvalFixtureResult, 0, len(fixtures)),
+	}
+
+	for _, fixture := range fixtures {
+		comments, err := e.review.ReviewDiff(ctx, fixture.Diff)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return run, ctxErr
+		}
+
+		result := ScoreEvalFixture(fixture, comments, e.config.LineTolerance)
+		if err != nil {
+			slog.Warn("error reviewing fixture", "fixture", fixture.Name, "error", err)
+			result.Error = err.Error()
+			result.Score.Failed = 1
+		}
+		run.Fixtures = append(run.Fixtures, result)
+	}
+
+	run.Score = sumEvalScores(run.Fixtures)
+	run.Duration = time.Since(run.StartedAt).Round(time.Millisecond).String()
+	return run, nil
+}
+
+// ScoreEvalFixture matches the comments to the expected findings of the fixture. A comment matches a finding on the
+// same path at most tolerance lines away, the closest pairs are matched first and each is matched at most once.
+func ScoreEvalFixture(fixture model.EvalFixture, comments []model.ReviewCommentRequest, tolerance int) model.EvalFixtureResult {
+	type pair struct {
+		comment, expected, distance int
+	}
+	var pairs []pair
+	for i, comment := range comments {
+		for j, expected := range fixture.Expected {
+			if comment.Path != expected.Path {
+				continue
+			}
+			if distance := abs(comment.Line - expected.Line); distance <= tolerance {
+				pairs = append(pairs, pair{comment: i, expected: j, distance: distance})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].distance < pairs[j].distance })
+
+	matchedComments := make([]bool, len(comments))
+	matchedExpected := make([]bool, len(fixture.Expected))
+	score := model.EvalScore{
+		Fixtures:           1,
+		Expected:           len(fixture.Expected),
+		Comments:           len(comments),
+		CommentsBySeverity: map[string]int{},
+	}
+	totalDistance := 0
+	for _, p := range pairs {
+		if matchedComments[p.comment] || matchedExpected[p.expected] {
+			continue
+		}
+		matchedComments[p.comment], matchedExpected[p.expected] = true, true
+
+		score.TruePositives++
+		totalDistance += p.distance
+		if p.distance == 0 {
+			score.ExactLines++
+		}
+	}
+
+	result := model.EvalFixtureResult{Name: fixture.Name}
+	for i, comment := range comments {
+		score.CommentsBySeverity[severityOrUnspecified(comment.Body)]++
+		if !matchedComments[i] {
+			result.Unexpected = append(result.Unexpected, comment)
+		}
+	}
+	for j, expected := range fixture.Expected {
+		if !matchedExpected[j] {
+			result.Missed = append(result.Missed, expected)
+		}
+	}
+
+	score.FalsePositives = len(result.Unexpected)
+	score.FalseNegatives = len(result.Missed)
+	score.MeanLineDistance = ratio(totalDistance, score.TruePositives)
+	finishEvalScore(&score)
+	result.Score = score
+
+	return result
+}
+
+// sumEvalScores adds up the counts of every fixture and computes the ratios over the totals
+func sumEvalScores(results []model.EvalFixtureResult) model.EvalScore {
+	total := model.EvalScore{CommentsBySeverity: map[string]int{}}
+	totalDistance := 0.0
+	for _, result := range results {
+		score := result.Score
+		total.Fixtures += score.Fixtures
+		total.Failed += score.Failed
+		total.Expected += score.Expected
+		total.Comments += score.Comments
+		total.TruePositives += score.TruePositives
+		total.FalsePositives += score.FalsePositives
+		total.FalseNegatives += score.FalseNegatives
+		total.ExactLines += score.ExactLines
+		totalDistance += score.MeanLineDistance * float64(score.TruePositives)
+		for severity, count := range score.CommentsBySeverity {
+			total.CommentsBySeverity[severity] += count
+		}
+	}
+
+	if total.TruePositives > 0 {
+		total.MeanLineDistance = totalDistance / float64(total.TruePositives)
+	}
+	finishEvalScore(&total)
+	return total
+}
+
+func finishEvalScore(score *model.EvalScore) {
+	score.Precision = ratio(score.TruePositives, score.Comments)
+	score.Recall = ratio(score.TruePositives, score.Expected)
+	if score.Precision+score.Recall > 0 {
+		score.F1 = 2 * score.Precision * score.Recall / (score.Precision + score.Recall)
+	}
+	score.LineAccuracy = ratio(score.ExactLines, score.TruePositives)
+	score.CommentsPerFixture = ratio(score.Comments, score.Fixtures)
+}
+
+// CompareEvalRuns lists how every metric moved from the base run to the candidate, and the fixtures scored differently
+func CompareEvalRuns(base model.EvalRun, candidate model.EvalRun) model.EvalComparison {
+	metric := func(name string, baseValue, candidateValue float64, higherIsBetter bool) model.EvalMetricDelta {
+		return model.EvalMetricDelta{
+			Name:           name,
+			Base:           baseValue,
+			Candidate:      candidateValue,
+			Delta:          candidateValue - baseValue,
+			HigherIsBetter: higherIsBetter,
+		}
+	}
+
+	b, c := base.Score, candidate.Score
+	comparison := model.EvalComparison{
+		Base:      base.Config,
+		Candidate: candidate.Config,
+		Metrics: []model.EvalMetricDelta{
+			metric("precision", b.Precision, c.Precision, true),
+			metric("recall", b.Recall, c.Recall, true),
+			metric("f1", b.F1, c.F1, true),
+			metric("line_accuracy", b.LineAccuracy, c.LineAccuracy, true),
+			metric("mean_line_distance", b.MeanLineDistance, c.MeanLineDistance, false),
+			metric("comments", float64(b.Comments), float64(c.Comments), false),
+			metric("comments_per_fixture", b.CommentsPerFixture, c.CommentsPerFixture, false),
+			metric("false_positives", float64(b.FalsePositives), float64(c.FalsePositives), false),
+			metric("false_negatives", float64(b.FalseNegatives), float64(c.FalseNegatives), false),
+			metric("failed", float64(b.Failed), float64(c.Failed), false),
+		},
+	}
+
+	candidateFixtures := make(map[string]model.EvalScore, len(candidate.Fixtures))
+	for _, fixture := range candidate.Fixtures {
+		candidateFixtures[fixture.Name] = fixture.Score
+	}
+	for _, fixture := range base.Fixtures {
+		candidateScore, ok := candidateFixtures[fixture.Name]
+		if !ok {
+			continue
+		}
+		if candidateScore.TruePositives == fixture.Score.TruePositives && candidateScore.FalsePositives == fixture.Score.FalsePositives {
+			continue
+		}
+		comparison.Fixtures = append(comparison.Fixtures, model.EvalFixtureDelta{
+			Name:                    fixture.Name,
+			BaseTruePositives:       fixture.Score.TruePositives,
+			CandidateTruePositives:  candidateScore.TruePositives,
+			BaseFalsePositives:      fixture.Score.FalsePositives,
+			CandidateFalsePositives: candidateScore.FalsePositives,
+		})
+	}
+
+	return comparison
+}
+
+func severityOrUnspecified(body string) string {
+	if severity := utils.ParseSeverity(body); severity != "" {
+		return severity
+	}
+	return "UNSPECIFIED"
+}
+
+// ratio divides the counts, 0 when there is nothing to divide by
+func ratio(numerator int, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
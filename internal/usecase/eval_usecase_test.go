@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+func TestScoreEvalFixture(t *testing.T) {
+	fixture := model.EvalFixture{
+		Name: "scoring",
+		Expected: []model.ExpectedFinding{
+			{Path: "a.go", Line: 10},
+			{Path: "a.go", Line: 12},
+			{Path: "b.go", Line: 5},
+		},
+	}
+	comments := []model.ReviewCommentRequest{
+		// Closest to line 12, it must not take line 10 from the exact comment below
+		{Path: "a.go", Line: 11, Body: "IMPORTANT: off by one"},
+		{Path: "a.go", Line: 10, Body: "BLOCKING: exact"},
+		// Right line, wrong file
+		{Path: "c.go", Line: 5, Body: "NIT: elsewhere"},
+		// Too far from b.go:5
+		{Path: "b.go", Line: 9, Body: "no severity"},
+	}
+
+	result := ScoreEvalFixture(fixture, comments, 3)
+	score := result.Score
+
+	if score.TruePositives != 2 || score.FalsePositives != 2 || score.FalseNegatives != 1 {
+		t.Fatalf("expected 2 true positives, 2 false positives and 1 false negative, got %+v", score)
+	}
+	if score.ExactLines != 1 || score.LineAccuracy != 0.5 || score.MeanLineDistance != 0.5 {
+		t.Errorf("expected one exact line out of two at a mean distance of 0.5, got %+v", score)
+	}
+	if score.Precision != 0.5 || score.Recall != 2.0/3.0 {
+		t.Errorf("expected precision 0.5 and recall 0.667, got %v and %v", score.Precision, score.Recall)
+	}
+	if len(result.Missed) != 1 || result.Missed[0].Path != "b.go" {
+		t.Errorf("expected b.go:5 to be missed, got %+v", result.Missed)
+	}
+	if score.CommentsBySeverity["UNSPECIFIED"] != 1 || score.CommentsBySeverity[utils.SEVERITY_BLOCKING] != 1 {
+		t.Errorf("unexpected severities %v", score.CommentsBySeverity)
+	}
+
+	if strict := ScoreEvalFixture(fixture, comments, 0).Score; strict.TruePositives != 1 {
+		t.Errorf("expected only the exact comment to match without tolerance, got %d", strict.TruePositives)
+	}
+}
+
+// The fixtures in eval/fixtures reviewed by the fake LLM, so changes to the pipeline which lose comments fail CI
+func TestEvalHarnessWithFakeLLM(t *testing.T) {
+	fixtures, err := utils.LoadEvalFixtures(filepath.Join("..", "..", "eval", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := utils.LoadLanguageProfiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRun := func() model.EvalRun {
+		review := NewLocalReviewUsecase(nil, repository.NewFakeLLMRepository(), utils.NewRedactor(nil), NewOutputPolicy(DEFAULT_ALLOWED_LINK_HOSTS, nil), model.PromptContext{}, profiles, nil)
+		run, err := NewEvalUsecase(review, model.EvalConfig{Provider: "fake", LineTolerance: DEFAULT_EVAL_LINE_TOLERANCE}).Run(context.Background(), fixtures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return run
+	}
+
+	run := newRun()
+	score := run.Score
+	if score.Fixtures != len(fixtures) || score.Failed != 0 {
+		t.Fatalf("expected every fixture to be reviewed, got %+v", score)
+	}
+	if score.Precision < 0.75 || score.Recall < 0.5 || score.LineAccuracy < 1 {
+		t.Errorf("scores of the fake LLM dropped, got precision %.3f, recall %.3f and line accuracy %.3f", score.Precision, score.Recall, score.LineAccuracy)
+	}
+	for _, fixture := range run.Fixtures {
+		if fixture.Score.Expected == 0 && fixture.Score.Comments > 0 {
+			t.Errorf("fixture %s expects no findings but got %d comment(s)", fixture.Name, fixture.Score.Comments)
+		}
+		// Its findings are far from the start of their hunk, they are only found when the line numbers the LLM
+		// is shown are the ones the fixtures and github use
+		if fixture.Name == "go-multi-hunk" && (fixture.Score.TruePositives != fixture.Score.Expected || fixture.Score.ExactLines != fixture.Score.Expected) {
+			t.Errorf("expected every finding of %s on its exact line, got %+v", fixture.Name, fixture.Score)
+		}
+	}
+
+	if again := newRun(); !reflect.DeepEqual(again.Fixtures, run.Fixtures) {
+		t.Error("runs of the fake LLM differ")
+	}
+}
+
+func TestCompareEvalRuns(t *testing.T) {
+	base := model.EvalRun{
+		Score: model.EvalScore{Precision: 0.5, Comments: 4},
+		Fixtures: []model.EvalFixtureResult{
+			{Name: "same", Score: model.EvalScore{TruePositives: 1}},
+			{Name: "changed", Score: model.EvalScore{TruePositives: 1, FalsePositives: 2}},
+			{Name: "only-in-base"},
+		},
+	}
+	candidate := model.EvalRun{
+		Score: model.EvalScore{Precision: 0.75, Comments: 4},
+		Fixtures: []model.EvalFixtureResult{
+			{Name: "same", Score: model.EvalScore{TruePositives: 1}},
+			{Name: "changed", Score: model.EvalScore{TruePositives: 2}},
+		},
+	}
+
+	comparison := CompareEvalRuns(base, candidate)
+
+	if comparison.Metrics[0].Name != "precision" || comparison.Metrics[0].Delta != 0.25 {
+		t.Errorf("expected precision to improve by 0.25, got %+v", comparison.Metrics[0])
+	}
+	if len(comparison.Fixtures) != 1 || comparison.Fixtures[0].Name != "changed" {
+		t.Fatalf("expected only the changed fixture, got %+v", comparison.Fixtures)
+	}
+	if delta := comparison.Fixtures[0]; delta.CandidateTruePositives != 2 || delta.CandidateFalsePositives != 0 {
+		t.Errorf("unexpected fixture delta %+v", delta)
+	}
+}
@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/google/go-github/v74/github"
+)
+
+const CREATED_ACTION = "created"
+
+// How far back posted comments are polled for reactions, older comments rarely get new feedback
+const FEEDBACK_LOOKBACK = 30 * 24 * time.Hour
+
+// How many rejected comments are passed to the prompt so it doesn't grow without bound
+const MAX_REJECTED_PATTERNS = 10
+
+// Maximum length of a rejected comment in the prompt, the first sentences carry the issue
+const MAX_REJECTED_PATTERN_LENGTH = 200
+
+var falsePositiveReplyPattern = regexp.MustCompile(`(?i)\b(false positive|not (an|a real) issue|not applicable|doesn'?t apply|irrelevant|incorrect|not helpful|useless|ignore this|wont fix|won'?t fix|by design|intentional)\b`)
+var helpfulReplyPattern = regexp.MustCompile(`(?i)\b(good catch|nice catch|great catch|fixed|done|addressed|thanks|thank you|helpful|agreed|good point|makes sense)\b`)
+
+// ReviewCommentFeedback records replies to the bot's comments, such as "false positive" or "good catch"
+func (g *GithubUsecase) ReviewCommentFeedback(ctx context.Context, event *github.PullRequestReviewCommentEvent) error {
+	if event.GetAction() != CREATED_ACTION || event.GetComment().InReplyTo == nil {
+		return nil
+	}
+
+	// Ignore bots, including our own "Resolved in" replies
+	if event.GetComment().GetUser().GetType() == "Bot" {
+		return nil
+	}
+
+	kind := classifyFeedbackReply(event.GetComment().GetBody())
+	if kind == "" {
+		return nil
+	}
+
+	comment, err := g.feedback.GetCommentByGithubID(ctx, event.GetComment().GetInReplyTo())
+	if errors.Is(err, repository.ErrNotFound) {
+		// A reply to someone else's comment
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	feedback := &model.CommentFeedback{
+		CommentID:     comment.ID,
+		GithubReplyID: event.GetComment().GetID(),
+		Author:        event.GetComment().GetUser().GetLogin(),
+		Kind:          kind,
+		Body:          event.GetComment().GetBody(),
+		CreatedAt:     event.GetComment().GetCreatedAt().Time,
+	}
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+
+	if err := g.feedback.AddReplyFeedback(ctx, feedback); err != nil {
+		return err
+	}
+
+	slog.Info("feedback on review comment has been recorded", "comment", comment.ID, "kind", kind, "author", feedback.Author)
+	return nil
+}
+
+// PollFeedback syncs reactions on posted comments every interval until the context is cancelled.
+// Github doesn't send webhooks for reactions, so they have to be polled.
+func (g *GithubUsecase) PollFeedback(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.SyncReactions(ctx); err != nil {
+				slog.Error("error syncing reactions on review comments", "error", err)
+			}
+		}
+	}
+}
+
+// SyncReactions stores the current 👍/👎 counts of every recently posted comment
+func (g *GithubUsecase) SyncReactions(ctx context.Context) error {
+	comments, err := g.feedback.ListPostedComments(ctx, time.Now().Add(-FEEDBACK_LOOKBACK))
+	if err != nil {
+		return err
+	}
+
+	// Group by pull request, a single listing returns the reactions of every comment on it
+	type pullRequestKey struct {
+		installationID int64
+		owner          string
+		repo           string
+		pullNumber     int
+	}
+	byPullRequest := map[pullRequestKey][]model.PostedComment{}
+	for _, comment := range comments {
+		key := pullRequestKey{comment.InstallationID, comment.Owner, comment.Repo, comment.PullNumber}
+		byPullRequest[key] = append(byPullRequest[key], comment)
+	}
+
+	clients := map[int64]*github.Client{}
+	for key, storedComments := range byPullRequest {
+		client, ok := clients[key.installationID]
+		if !ok {
+			client, err = g.newInstallationClient(ctx, key.installationID)
+			if err != nil {
+				slog.Warn("error creating client to sync reactions", "error", err, "installationID", key.installationID)
+				continue
+			}
+			clients[key.installationID] = client
+		}
+
+		githubComments, err := g.repository.ListReviewComments(ctx, client, key.owner, key.repo, key.pullNumber)
+		if err != nil {
+			slog.Warn("error listing review comments to sync reactions", "error", err, "owner", key.owner, "repo", key.repo, "pullNumber", key.pullNumber)
+			continue
+		}
+
+		reactions := map[int64]*github.Reactions{}
+		for _, githubComment := range githubComments {
+			reactions[githubComment.GetID()] = githubComment.GetReactions()
+		}
+
+		for _, storedComment := range storedComments {
+			commentReactions, ok := reactions[storedComment.GithubCommentID]
+			if !ok {
+				continue
+			}
+			err := g.feedback.UpdateReactions(ctx, storedComment.ID, commentReactions.GetPlusOne(), commentReactions.GetMinusOne())
+			if err != nil {
+				slog.Error("error storing reactions", "error", err, "comment", storedComment.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rejectedPatterns returns the comments developers rejected on the repository, shortened for the prompt
+func (g *GithubUsecase) rejectedPatterns(ctx context.Context, owner string, repo string) []string {
+	summaries, err := g.feedback.ListFeedbackSummaries(ctx, owner, repo)
+	if err != nil {
+		slog.Warn("error fetching rejected comments, reviewing without them", "error", err, "owner", owner, "repo", repo)
+		return nil
+	}
+
+	var patterns []string
+	seen := map[string]bool{}
+	// Newest first, recent rejections are the most relevant
+	for i := len(summaries) - 1; i >= 0 && len(patterns) < MAX_REJECTED_PATTERNS; i-- {
+		if !summaries[i].IsRejected() {
+			continue
+		}
+
+		pattern := strings.Join(strings.Fields(summaries[i].Body), " ")
+		if len(pattern) > MAX_REJECTED_PATTERN_LENGTH {
+			pattern = pattern[:MAX_REJECTED_PATTERN_LENGTH] + "..."
+		}
+		if seen[pattern] {
+			continue
+		}
+		seen[pattern] = true
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+// classifyFeedbackReply returns the kind of feedback a reply gives, or an empty string for regular discussion
+func classifyFeedbackReply(body string) string {
+	if falsePositiveReplyPattern.MatchString(body) {
+		return model.FEEDBACK_FALSE_POSITIVE
+	}
+	if helpfulReplyPattern.MatchString(body) {
+		return model.FEEDBACK_HELPFUL
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+)
+
+// toPRFiles converts the files returned by the github API into the model used for formatting
+func toPRFiles(files []*github.CommitFile) []model.PRFile {
+	prFiles := make([]model.PRFile, 0, len(files))
+
+	for _, file := range files {
+		prFiles = append(prFiles, model.PRFile{
+			SHA:       file.GetSHA(),
+			Filename:  file.GetFilename(),
+			Status:    file.GetStatus(),
+			Additions: file.GetAdditions(),
+			Deletions: file.GetDeletions(),
+			Changes:   file.GetChanges(),
+			Patch:     file.GetPatch(),
+		})
+	}
+
+	return prFiles
+}
+
+func formatFilesForLLM(files []model.PRFile) string {
+	var formattedFiles []string
+
+	for _, file := range files {
+		if file.Patch == "" {
+			continue // Skip binary or unchanged files
+		}
+
+		formatted := fmt.Sprintf(`
+			FILE: %s
+			STATUS: %s (+%d, -%d)
+			DIFF (lines start with [LINE:N], comment on line N):
+			%s
+			---END FILE---`,
+			file.Filename,
+			file.Status,
+			file.Additions,
+			file.Deletions,
+			formatPatchWithLineNumbers(file.Patch),
+		)
+
+		formattedFiles = append(formattedFiles, formatted)
+	}
+
+	return strings.Join(formattedFiles, "\n\n")
+}
+
+// formatPatchWithLineNumbers prefixes the added and unchanged lines of the patch with [LINE:N], N being their
+// line number in the new version of the file, which is the line comments are posted on
+func formatPatchWithLineNumbers(patch string) string {
+	lines := strings.Split(patch, "\n")
+
+	// Hunk headers and deleted lines are kept as they are, without a line number
+	for _, line := range utils.PatchNewLines(patch) {
+		lines[line.Index] = fmt.Sprintf("[%s%d] %s", utils.LINE_MARKER_PREFIX, line.Line, lines[line.Index])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatPatchWithLineNumbers(t *testing.T) {
+	patch := strings.Join([]string{
+		"@@ -3,3 +3,3 @@ import (",
+		" \t\"fmt\"",
+		"-\t\"io\"",
+		"+\t\"os\"",
+		" )",
+		"@@ -40,2 +40,3 @@ func main() {",
+		" \tdefer f.Close()",
+		"+\tfmt.Println(f.Name())",
+		" }",
+		"\\ No newline at end of file",
+	}, "\n")
+
+	// Markers carry the line number in the new file, which is what the LLM comments on and github expects
+	expected := strings.Join([]string{
+		"@@ -3,3 +3,3 @@ import (",
+		"[LINE:3]  \t\"fmt\"",
+		"-\t\"io\"",
+		"[LINE:4] +\t\"os\"",
+		"[LINE:5]  )",
+		"@@ -40,2 +40,3 @@ func main() {",
+		"[LINE:40]  \tdefer f.Close()",
+		"[LINE:41] +\tfmt.Println(f.Name())",
+		"[LINE:42]  }",
+		"\\ No newline at end of file",
+	}, "\n")
+
+	if formatted := formatPatchWithLineNumbers(patch); formatted != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, formatted)
+	}
+}
@@ -2,41 +2,116 @@ package usecase
 
 import (
 	"context"
-	"crypto/rsa"
 	"fmt"
 	"log/slog"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
 	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/go-github/v74/github"
-	"golang.org/x/oauth2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type GithubUsecase struct {
-	repository *repository.GithubRepository
-	gemini     *repository.GeminiRepository
-	appID      int64
-	privateKey *rsa.PrivateKey
+	repository    repository.GithubRepository
+	llm           LLMRepository
+	reviews       repository.ReviewRepository
+	feedback      repository.FeedbackRepository
+	installations repository.InstallationRepository
+	budget        *BudgetUsecase
+	redactor      *utils.Redactor
+	outputPolicy  *OutputPolicy
+	promptVersion string
+	// Reviews of chunks reviewed before, nil reviews everything with the LLM
+	reviewCache *ReviewCache
+	dryRun      *DryRunPolicy
+	delays      ReviewDelays
+	appID       int64
+	// Re-read on rotation, so it is parsed whenever a JWT is signed
+	privateKey *secrets.Secret
+
+	// Slug of the github app, fetched lazily to recognise the bot's own comments
+	appSlugMu sync.Mutex
+	appSlug   string
 }
 
 const OPENED_ACTION = "opened"
 const REOPENED_ACTION = "reopened"
+const SYNCHRONIZE_ACTION = "synchronize"
 
-func NewGithubUsecase(repository *repository.GithubRepository, gemini *repository.GeminiRepository, appID int64, privateKey *rsa.PrivateKey) *GithubUsecase {
+// Action of the pull request events built by the admin API to review a pull request again
+const RERUN_ACTION = "rerun"
+
+// ReviewDelays are the pauses between github writes during a review, to stay under github's secondary rate limits
+type ReviewDelays struct {
+	// Between review comments
+	Comment time.Duration
+	// Between pages of changed files
+	Page time.Duration
+}
+
+var DEFAULT_REVIEW_DELAYS = ReviewDelays{Comment: 5 * time.Second, Page: 15 * time.Second}
+
+// GithubUsecaseDependencies are what a GithubUsecase is created with, the optional ones can be left unset
+type GithubUsecaseDependencies struct {
+	Github repository.GithubRepository
+	LLM    LLMRepository
+
+	// Review history, feedback on posted comments and the installation registry, usually the same storage
+	Reviews       repository.ReviewRepository
+	Feedback      repository.FeedbackRepository
+	Installations repository.InstallationRepository
+
+	Budget *BudgetUsecase
+	// Masks secrets in code before it is sent to the LLM, nil disables redaction
+	Redactor *utils.Redactor
+	// Links and mentions allowed in what the LLM generates
+	OutputPolicy *OutputPolicy
+	// Version of the bundled prompt templates, the default version when empty
+	PromptVersion string
+	// Reviews of chunks reviewed before, nil reviews everything with the LLM
+	ReviewCache *ReviewCache
+	DryRun      *DryRunPolicy
+	Delays      ReviewDelays
+
+	// Github app the JWTs are signed for
+	AppID      int64
+	PrivateKey *secrets.Secret
+}
+
+func NewGithubUsecase(deps GithubUsecaseDependencies) *GithubUsecase {
 	return &GithubUsecase{
-		repository: repository,
-		gemini:     gemini,
-		appID:      appID,
-		privateKey: privateKey,
+		repository:    deps.Github,
+		llm:           deps.LLM,
+		reviews:       deps.Reviews,
+		feedback:      deps.Feedback,
+		installations: deps.Installations,
+		budget:        deps.Budget,
+		redactor:      deps.Redactor,
+		outputPolicy:  deps.OutputPolicy,
+		promptVersion: deps.PromptVersion,
+		reviewCache:   deps.ReviewCache,
+		dryRun:        deps.DryRun,
+		delays:        deps.Delays,
+		appID:         deps.AppID,
+		privateKey:    deps.PrivateKey,
 	}
 }
 
 func (g *GithubUsecase) PullRequestReviewer(ctx context.Context, event *github.PullRequestEvent) error {
 	action := event.GetAction()
 
+	if err := g.checkInstallation(ctx, event.GetInstallation().GetID()); err != nil {
+		return err
+	}
+
 	switch action {
 	case OPENED_ACTION:
 		err := g.reviewPullRequest(ctx, event)
@@ -50,6 +125,18 @@ func (g *GithubUsecase) PullRequestReviewer(ctx context.Context, event *github.P
 			return err
 		}
 		slog.Info("pull request review completed successfully")
+	case RERUN_ACTION:
+		err := g.reviewPullRequest(ctx, event)
+		if err != nil {
+			return err
+		}
+		slog.Info("pull request review completed successfully")
+	case SYNCHRONIZE_ACTION:
+		err := g.resolveFixedComments(ctx, event)
+		if err != nil {
+			return err
+		}
+		slog.Info("checking previous review comments completed successfully")
 	default:
 		slog.Info("recieved an action which is not supported yet", "action", action)
 	}
@@ -57,14 +144,26 @@ func (g *GithubUsecase) PullRequestReviewer(ctx context.Context, event *github.P
 	return nil
 }
 
+// PullRequestJobName names the queue job handling the pull request event
+func PullRequestJobName(event *github.PullRequestEvent) string {
+	return fmt.Sprintf("%s %s#%d", event.GetAction(), event.GetRepo().GetFullName(), event.GetPullRequest().GetNumber())
+}
+
 // Private methods
 
-func (g *GithubUsecase) reviewPullRequest(ctx context.Context, event *github.PullRequestEvent) error {
+func (g *GithubUsecase) reviewPullRequest(ctx context.Context, event *github.PullRequestEvent) (err error) {
 	owner := event.GetRepo().GetOwner().GetLogin()
 	repo := event.GetRepo().GetName()
 	pullNumber := event.GetPullRequest().GetNumber()
 	installationID := event.Installation.GetID()
 	commitID := event.GetPullRequest().GetHead().GetSHA()
+	dryRun := g.dryRun.Enabled(installationID, owner+"/"+repo)
+
+	ctx, span := tracing.Start(ctx, "GithubUsecase.reviewPullRequest", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	span.SetAttributes(attribute.String("github.head_sha", commitID), attribute.Bool("review.dry_run", dryRun))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	// Debug logging
 	slog.Info("Processing PR review",
@@ -72,20 +171,76 @@ func (g *GithubUsecase) reviewPullRequest(ctx context.Context, event *github.Pul
 		"repo", repo,
 		"pullNumber", pullNumber,
 		"installationID", installationID,
-		"commitID", commitID)
+		"commitID", commitID,
+		"dryRun", dryRun)
+
+	// Once the installation or repository is over budget the review is degraded, llm is nil when nothing is reviewed
+	budget := g.checkBudget(ctx, installationID, owner, repo)
+	llm := g.budget.LLMFor(budget, g.llm)
+	reviewModel := g.llm.Model()
+	if llm != nil {
+		reviewModel = llm.Model()
+	}
+	span.SetAttributes(attribute.Bool("review.budget_exceeded", budget.Exceeded))
+
+	// Record the run, whatever happens below ends up in the review history
+	run := &model.ReviewRun{
+		InstallationID: installationID,
+		Owner:          owner,
+		Repo:           repo,
+		PullNumber:     pullNumber,
+		HeadSHA:        commitID,
+		Trigger:        event.GetAction(),
+		Model:          reviewModel,
+		PromptVersion:  utils.PromptVersionID(g.promptVersion, model.PromptOverrides{}),
+		DryRun:         dryRun,
+		Status:         model.REVIEW_STATUS_RUNNING,
+		StartedAt:      time.Now(),
+	}
+	g.startRun(ctx, run)
+	defer func() {
+		g.finishRun(ctx, run, err)
+	}()
 
 	// Create new bot client for this request
-	jwt, err := g.generateJWT()
+	client, err := g.newInstallationClient(ctx, installationID)
 	if err != nil {
-		return fmt.Errorf("error generating jwt token for github client: %v", err)
+		return err
+	}
+
+	// The prompt version recorded with the run includes the repository's overrides
+	promptCtx := g.promptContext(ctx, client, event)
+	run.PromptVersion = utils.PromptVersionID(promptCtx.PromptVersion, promptCtx.PromptOverrides)
+	span.SetAttributes(attribute.String("review.prompt_version", run.PromptVersion))
+
+	switch {
+	case llm == nil && budget.Action == model.BUDGET_ACTION_SUMMARY_ONLY:
+		return g.summarizePullRequest(ctx, client, run, budget, promptCtx)
+	case llm == nil:
+		return g.skipReview(ctx, client, run, budget)
+	case budget.Exceeded:
+		metrics.BudgetExceeded.WithLabelValues(model.BUDGET_ACTION_SMALLER_MODEL).Inc()
 	}
-	installationToken, err := g.getInstallationToken(ctx, installationID, jwt)
-	if err != nil {
-		return fmt.Errorf("error retrieving installation token from github: %v", err)
 
+	// Fetch what has already been said on the pull request so we don't repeat it
+	existingComments, err := g.repository.ListReviewComments(ctx, client, owner, repo, pullNumber)
+	if err != nil {
+		slog.Warn("error fetching existing review comments, continuing without deduplication", "error", err, "owner", owner, "repo", repo, "pullNumber", pullNumber)
+	}
+	existing := toExistingComments(existingComments)
+
+	// Steer the LLM away from comments developers rejected on this repository before
+	promptCtx.RejectedPatterns = g.rejectedPatterns(ctx, owner, repo)
+	profiles := g.languageProfiles(ctx, client, event)
+
+	llmCall := model.LLMCall{
+		RunID:          run.ID,
+		InstallationID: installationID,
+		Owner:          owner,
+		Repo:           repo,
+		Model:          llm.Model(),
+		Operation:      model.LLM_OPERATION_CODE_REVIEW,
 	}
-	client := github.NewClient(oauth2.NewClient(context.Background(),
-		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: installationToken})))
 
 	// Loop until there are no more pages of files to review, pages start from 1
 	pageCount := 1
@@ -102,16 +257,46 @@ func (g *GithubUsecase) reviewPullRequest(ctx context.Context, event *github.Pul
 		}
 
 		// Parse the files to send to the LLM
-		formattedDiffs := g.formatFilesForLLM(files)
-		reviews, err := g.gemini.GetCodeReviews(formattedDiffs)
-		if err != nil {
-			slog.Error("error getting code reviews from LLM", "error", err)
-			return err
+		pageCtx, pageSpan := tracing.Start(ctx, "GithubUsecase.reviewPage", attribute.Int("github.page", pageCount), attribute.Int("github.files", len(files)))
+		// Secrets are masked before the diff leaves for the LLM, and flagged where they were added
+		prFiles, secretFindings := g.redactor.RedactFiles(toPRFiles(files))
+		// Each language is reviewed in a call of its own, with its checklist and the rules applying to its files
+		var reviews []model.ReviewCommentRequest
+		for _, group := range profiles.Group(prFiles) {
+			languageCtx, err := reviewPromptContext(promptCtx, group)
+			if err != nil {
+				tracing.End(pageSpan, err)
+				return err
+			}
+			languageReviews, usage, cached, err := reviewChunk(pageCtx, llm, g.reviewCache, group.Files, languageCtx)
+			run.Usage.Add(usage)
+			g.recordLLMCall(ctx, llmCall, usage)
+			if err != nil {
+				slog.Error("error getting code reviews from LLM", "error", err, "language", languageCtx.Language)
+				tracing.End(pageSpan, err)
+				return err
+			}
+			slog.Info("reviews have been created by the LLM", "number_of_reviews", len(languageReviews), "language", languageCtx.Language, "files", len(group.Files), "cached", cached)
+			reviews = append(reviews, languageReviews...)
+		}
+		// The diff may have been written to steer the LLM, so its comments are checked before anything is posted
+		reviews = g.outputPolicy.Filter(reviews, prFiles)
+		if len(secretFindings) > 0 {
+			slog.Warn("secrets found in the pull request", "owner", owner, "repo", repo, "pullNumber", pullNumber, "number_of_secrets", len(secretFindings))
+			reviews = append(secretComments(secretFindings, commitID), reviews...)
 		}
-		slog.Info("reviews have been created by the LLM", "number_of_reviews", len(reviews))
 
 		// Create each review
 		for _, review := range reviews {
+			if duplicate, found := findDuplicateComment(review, existing); found {
+				slog.Info("skipping review comment already raised on the pull request",
+					"path", review.Path,
+					"line", review.Line,
+					"raised_by_bot", duplicate.IsBot)
+				metrics.ReviewComments.WithLabelValues(metrics.COMMENT_DUPLICATE).Inc()
+				continue
+			}
+
 			comment := &github.PullRequestComment{
 				Body:     &review.Body,
 				CommitID: &commitID,
@@ -119,71 +304,52 @@ func (g *GithubUsecase) reviewPullRequest(ctx context.Context, event *github.Pul
 				Line:     &review.Line,
 			}
 
-			time.Sleep(time.Second * 5)
-
-			err = g.repository.CreateReviewComments(ctx, client, owner, repo, pullNumber, comment)
-			if err != nil {
-				slog.Error("error creating review comment", "error", err, "comment", comment)
+			if dryRun {
+				slog.Info("dry run, not posting review comment", "path", review.Path, "line", review.Line, "body", review.Body)
+				g.recordComment(ctx, run, review, model.COMMENT_STATUS_DRY_RUN, 0)
+			} else {
+				tracing.Sleep(pageCtx, "comment rate limit", g.delays.Comment)
+
+				created, err := g.repository.CreateReviewComments(pageCtx, client, owner, repo, pullNumber, comment)
+				if err != nil {
+					slog.Error("error creating review comment", "error", err, "comment", comment)
+					g.recordComment(ctx, run, review, model.COMMENT_STATUS_FAILED, 0)
+					continue
+				}
+				g.recordComment(ctx, run, review, model.COMMENT_STATUS_POSTED, created.GetID())
 			}
+
+			// Remember the new comment so later pages don't repeat it either
+			existing = append(existing, existingComment{
+				Path:      review.Path,
+				StartLine: review.Line,
+				EndLine:   review.Line,
+				Body:      review.Body,
+				IsBot:     true,
+			})
 		}
 
+		tracing.End(pageSpan, nil)
+
 		pageCount++
-		time.Sleep(time.Second * 15)
+		tracing.Sleep(ctx, "page rate limit", g.delays.Page)
 	}
 
 	return nil
 }
 
-func (g *GithubUsecase) formatFilesForLLM(files []*github.CommitFile) string {
-	var formattedFiles []string
-
-	for _, file := range files {
-		if file.GetPatch() == "" {
-			continue // Skip binary or unchanged files
-		}
-
-		formatted := fmt.Sprintf(`
-			FILE: %s
-			STATUS: %s (+%d, -%d)
-			DIFF:
-			%s
-			---END FILE---`,
-			file.GetFilename(),
-			file.GetStatus(),
-			file.GetAdditions(),
-			file.GetDeletions(),
-			g.formatPatchWithLineNumbers(file.GetPatch()),
-		)
-
-		formattedFiles = append(formattedFiles, formatted)
+// newInstallationClient creates a github client authenticated as the app installation
+func (g *GithubUsecase) newInstallationClient(ctx context.Context, installationID int64) (*github.Client, error) {
+	jwt, err := g.generateJWT()
+	if err != nil {
+		return nil, fmt.Errorf("error generating jwt token for github client: %v", err)
 	}
-
-	return strings.Join(formattedFiles, "\n\n")
-}
-
-func (g *GithubUsecase) formatPatchWithLineNumbers(patch string) string {
-	lines := strings.Split(patch, "\n")
-	var formatted []string
-	position := 0
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "@@") {
-			formatted = append(formatted, line) // Keep hunk headers as-is
-			position = 0                        // Reset position counter
-			continue
-		}
-
-		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ") {
-			position++
-			formatted = append(formatted, fmt.Sprintf("[POS:%d] %s", position, line))
-		} else if strings.HasPrefix(line, "-") {
-			formatted = append(formatted, line) // Deleted lines don't get positions
-		} else {
-			formatted = append(formatted, line)
-		}
+	installationToken, err := g.getInstallationToken(ctx, installationID, jwt)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving installation token from github: %v", err)
 	}
 
-	return strings.Join(formatted, "\n")
+	return g.repository.NewClient(installationToken), nil
 }
 
 func (g *GithubUsecase) generateJWT() (string, error) {
@@ -194,6 +360,11 @@ func (g *GithubUsecase) generateJWT() (string, error) {
 		return "", fmt.Errorf("app ID is not set")
 	}
 
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(g.privateKey.Value())
+	if err != nil {
+		return "", fmt.Errorf("error parsing private key: %w", err)
+	}
+
 	now := time.Now()
 	claims := jwt.MapClaims{
 		"iat": now.Unix(),
@@ -202,22 +373,58 @@ func (g *GithubUsecase) generateJWT() (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(g.privateKey)
+	return token.SignedString(privateKey)
 }
 
-func (g *GithubUsecase) getInstallationToken(ctx context.Context, installationID int64, jwtToken string) (string, error) {
-	// Create GitHub client with JWT
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwtToken})
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
-
-	// Get installation access token
-	installation, _, err := client.Apps.CreateInstallationToken(
-		ctx, installationID, &github.InstallationTokenOptions{},
-	)
+// newAppClient creates a github client authenticated as the app itself using a JWT
+func (g *GithubUsecase) newAppClient() (*github.Client, error) {
+	jwt, err := g.generateJWT()
+	if err != nil {
+		return nil, fmt.Errorf("error generating jwt token for github client: %v", err)
+	}
+
+	return g.repository.NewClient(jwt), nil
+}
+
+// getAppSlug returns the slug of the github app, caching it after the first successful lookup
+func (g *GithubUsecase) getAppSlug(ctx context.Context) (string, error) {
+	g.appSlugMu.Lock()
+	defer g.appSlugMu.Unlock()
+
+	if g.appSlug != "" {
+		return g.appSlug, nil
+	}
+
+	client, err := g.newAppClient()
 	if err != nil {
 		return "", err
 	}
 
-	return installation.GetToken(), nil
+	slug, err := g.repository.GetAppSlug(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	g.appSlug = slug
+
+	return slug, nil
+}
+
+// CheckAppAuth authenticates as the app with a fresh JWT (GET /app) and returns its slug,
+// a wrong private key or app ID fails here before any review does
+func (g *GithubUsecase) CheckAppAuth(ctx context.Context) (string, error) {
+	client, err := g.newAppClient()
+	if err != nil {
+		return "", err
+	}
+
+	slug, err := g.repository.GetAppSlug(ctx, client)
+	if err != nil {
+		return "", fmt.Errorf("error authenticating as the github app: %w", err)
+	}
+
+	return slug, nil
+}
+
+func (g *GithubUsecase) getInstallationToken(ctx context.Context, installationID int64, jwtToken string) (string, error) {
+	return g.repository.CreateInstallationToken(ctx, g.repository.NewClient(jwtToken), installationID)
 }
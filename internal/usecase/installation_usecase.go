@@ -0,0 +1,379 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+)
+
+// Actions of installation events
+const (
+	CREATED_INSTALLATION_ACTION   = "created"
+	DELETED_INSTALLATION_ACTION   = "deleted"
+	SUSPEND_INSTALLATION_ACTION   = "suspend"
+	UNSUSPEND_INSTALLATION_ACTION = "unsuspend"
+)
+
+// Actions of installation_repositories events
+const (
+	ADDED_REPOSITORIES_ACTION   = "added"
+	REMOVED_REPOSITORIES_ACTION = "removed"
+)
+
+// Repository selection of installations on every repository of the account, which includes repositories created later
+const ALL_REPOSITORY_SELECTION = "all"
+
+const WELCOME_ISSUE_TITLE = "AI PR reviewer has been installed"
+
+// WELCOME_ISSUE_BODY explains what the bot does and the files a repository configures it with
+const WELCOME_ISSUE_BODY = `Hi! I'm an AI code reviewer and I now have access to this repository.
+
+**What I do**
+- When a pull request is opened or reopened, I review the changed files and leave inline comments prefixed with a severity (BLOCKING, IMPORTANT, NIT or QUESTION).
+- When new commits are pushed, I check my unresolved comments and resolve the ones that have been fixed.
+- I don't repeat comments which are already on the pull request.
+
+**Configuration**
+These files are optional, they are read from the base branch of each pull request:
+- ` + "`" + utils.REPOSITORY_PROMPTS_DIR + "/`" + `: replace the ` + utils.REVIEW_PROMPT + `, ` + utils.SUMMARY_PROMPT + ` or ` + utils.RESOLUTION_PROMPT + ` prompt with a ` +
+	"`<name>" + utils.PROMPT_TEMPLATE_EXT + "`" + ` template, and set the variables templates read in ` + "`" + utils.PROMPT_CONFIG_FILE + "`" + `.
+- ` + "`" + utils.REPOSITORY_LANGUAGE_PROFILES_FILE + "`" + `: add languages under ` + "`profiles`" + `, or extend the ` + "`checklist`" + ` and ` + "`rules`" + ` of a bundled language by using its name.
+
+The rules I review against are rule files set up by the maintainers of this installation. A rule file is scoped with front matter listing the ` +
+	"`paths`" + ` (globs) and ` + "`languages`" + ` it applies to and the ` + "`severity`" + ` of its comments, JSON rule files use a ` + "`" + utils.RULE_JSON_SCOPE_KEY + "`" + ` key instead. Ask the maintainers to run me in dry-run mode first if you'd like to see my comments before they are posted.
+
+Access to this repository is managed from the GitHub App installation settings of the account.
+
+**Feedback**
+React with 👍 or 👎 on my comments, or reply to explain why a comment is wrong. Rejected comments steer future reviews of this repository.
+
+You can close this issue.`
+
+// ErrInstallationSuspended is returned when reviewing for an installation which is suspended or deleted
+var ErrInstallationSuspended = errors.New("installation is suspended or deleted")
+
+// InstallationUsecase keeps the registry of installations and their repositories in sync with github
+type InstallationUsecase struct {
+	github        *GithubUsecase
+	installations repository.InstallationRepository
+	dryRun        *DryRunPolicy
+	welcome       bool
+}
+
+// NewInstallationUsecase creates the usecase, welcome opens a welcome issue on the repositories the app is added to
+func NewInstallationUsecase(github *GithubUsecase, installations repository.InstallationRepository, dryRun *DryRunPolicy, welcome bool) *InstallationUsecase {
+	return &InstallationUsecase{
+		github:        github,
+		installations: installations,
+		dryRun:        dryRun,
+		welcome:       welcome,
+	}
+}
+
+// InstallationEvent records the app being installed, suspended, unsuspended or uninstalled.
+// It returns the repositories which should be welcomed.
+func (u *InstallationUsecase) InstallationEvent(ctx context.Context, event *github.InstallationEvent) ([]string, error) {
+	action := event.GetAction()
+	installation := toInstallation(event.GetInstallation())
+	now := time.Now()
+
+	switch action {
+	case CREATED_INSTALLATION_ACTION:
+		installation.Status = model.INSTALLATION_STATUS_ACTIVE
+	case DELETED_INSTALLATION_ACTION:
+		installation.Status = model.INSTALLATION_STATUS_DELETED
+		installation.DeletedAt = &now
+	case SUSPEND_INSTALLATION_ACTION:
+		installation.Status = model.INSTALLATION_STATUS_SUSPENDED
+		if installation.SuspendedAt == nil {
+			installation.SuspendedAt = &now
+		}
+	case UNSUSPEND_INSTALLATION_ACTION:
+		installation.Status = model.INSTALLATION_STATUS_ACTIVE
+		installation.SuspendedAt = nil
+	default:
+		// new_permissions_accepted and other actions don't change the registry
+		slog.Info("recieved an installation action which is not supported yet", "action", action)
+		return nil, nil
+	}
+
+	if err := u.saveInstallation(ctx, installation); err != nil {
+		return nil, err
+	}
+	slog.Info("installation updated", "installationID", installation.ID, "account", installation.Account, "status", installation.Status)
+
+	if action != CREATED_INSTALLATION_ACTION {
+		return nil, nil
+	}
+
+	fullNames := repositoryNames(event.Repositories)
+	if err := u.installations.AddInstallationRepositories(ctx, installation.ID, fullNames, false); err != nil {
+		return nil, err
+	}
+
+	return u.toWelcome(installation, fullNames), nil
+}
+
+// InstallationRepositoriesEvent records repositories being added to or removed from an installation.
+// It returns the repositories which should be welcomed.
+func (u *InstallationUsecase) InstallationRepositoriesEvent(ctx context.Context, event *github.InstallationRepositoriesEvent) ([]string, error) {
+	installation := toInstallation(event.GetInstallation())
+	installation.Status = model.INSTALLATION_STATUS_ACTIVE
+	if installation.SuspendedAt != nil {
+		installation.Status = model.INSTALLATION_STATUS_SUSPENDED
+	}
+	if err := u.saveInstallation(ctx, installation); err != nil {
+		return nil, err
+	}
+
+	added := repositoryNames(event.RepositoriesAdded)
+	removed := repositoryNames(event.RepositoriesRemoved)
+	slog.Info("installation repositories changed", "installationID", installation.ID, "added", added, "removed", removed)
+
+	if err := u.installations.AddInstallationRepositories(ctx, installation.ID, added, false); err != nil {
+		return nil, err
+	}
+	if err := u.installations.RemoveInstallationRepositories(ctx, installation.ID, removed); err != nil {
+		return nil, err
+	}
+
+	if event.GetAction() != ADDED_REPOSITORIES_ACTION {
+		return nil, nil
+	}
+	return u.toWelcome(installation, added), nil
+}
+
+// Welcome opens a welcome issue on each repository, once per repository
+func (u *InstallationUsecase) Welcome(ctx context.Context, installationID int64, fullNames []string) error {
+	client, err := u.github.newInstallationClient(ctx, installationID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, fullName := range fullNames {
+		claimed, err := u.installations.ClaimRepositoryWelcome(ctx, installationID, fullName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		owner, repo, _ := strings.Cut(fullName, "/")
+		issue, err := u.github.repository.CreateIssue(ctx, client, owner, repo, WELCOME_ISSUE_TITLE, WELCOME_ISSUE_BODY)
+		if err != nil {
+			// Issues may be disabled on the repository, or the app may not have the issues permission
+			errs = append(errs, fmt.Errorf("error opening welcome issue on %s: %w", fullName, err))
+			continue
+		}
+		slog.Info("welcome issue opened", "repo", fullName, "issue", issue.GetNumber())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sync brings the registry up to date with github, for installations which happened before the registry
+// existed or while the server was down. Repositories found this way are not welcomed.
+func (u *InstallationUsecase) Sync(ctx context.Context) error {
+	githubInstallations, err := u.github.listInstallations(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing installations: %w", err)
+	}
+
+	seen := map[int64]bool{}
+	for _, githubInstallation := range githubInstallations {
+		installation := toInstallation(githubInstallation)
+		seen[installation.ID] = true
+
+		installation.Status = model.INSTALLATION_STATUS_ACTIVE
+		if installation.SuspendedAt != nil {
+			installation.Status = model.INSTALLATION_STATUS_SUSPENDED
+		}
+		if err := u.saveInstallation(ctx, installation); err != nil {
+			return err
+		}
+
+		// Suspended installations can't get a token to list their repositories
+		if installation.Status != model.INSTALLATION_STATUS_ACTIVE {
+			continue
+		}
+		if err := u.syncRepositories(ctx, installation.ID); err != nil {
+			slog.Warn("error syncing installation repositories", "installationID", installation.ID, "error", err)
+		}
+	}
+
+	// Anything github doesn't know about anymore was uninstalled
+	registered, err := u.installations.ListInstallations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, installation := range registered {
+		if seen[installation.ID] || installation.Status == model.INSTALLATION_STATUS_DELETED {
+			continue
+		}
+
+		now := time.Now()
+		installation.Status = model.INSTALLATION_STATUS_DELETED
+		installation.DeletedAt = &now
+		if err := u.saveInstallation(ctx, &installation); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("installations synced", "installations", len(githubInstallations))
+	return nil
+}
+
+// List returns the registered installations with their dry-run settings
+func (u *InstallationUsecase) List(ctx context.Context) ([]model.Installation, error) {
+	installations, err := u.installations.ListInstallations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := u.dryRun.Settings()
+	for i := range installations {
+		installations[i].DryRun = u.dryRun.Enabled(installations[i].ID, "")
+		for _, repo := range settings.Repos {
+			if strings.HasPrefix(repo, strings.ToLower(installations[i].Account)+"/") {
+				installations[i].DryRunRepos = append(installations[i].DryRunRepos, repo)
+			}
+		}
+	}
+
+	return installations, nil
+}
+
+// toWelcome returns the repositories to welcome, none unless welcomes are enabled or when the installation is on all repositories
+func (u *InstallationUsecase) toWelcome(installation *model.Installation, fullNames []string) []string {
+	if !u.welcome || len(fullNames) == 0 {
+		return nil
+	}
+	// Nobody picked the repositories of installations on the whole account, welcoming each of them would
+	// open an issue on every repository of a large organisation
+	if installation.RepositorySelection == ALL_REPOSITORY_SELECTION {
+		slog.Info("not welcoming repositories of an installation on all repositories", "installationID", installation.ID, "account", installation.Account, "repositories", len(fullNames))
+		return nil
+	}
+	return fullNames
+}
+
+func (u *InstallationUsecase) syncRepositories(ctx context.Context, installationID int64) error {
+	client, err := u.github.newInstallationClient(ctx, installationID)
+	if err != nil {
+		return err
+	}
+
+	repositories, err := u.github.repository.ListInstallationRepositories(ctx, client)
+	if err != nil {
+		return err
+	}
+	current := repositoryNames(repositories)
+
+	installation, err := u.installations.GetInstallation(ctx, installationID)
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, fullName := range installation.Repositories {
+		if !containsFold(current, fullName) {
+			removed = append(removed, fullName)
+		}
+	}
+
+	if err := u.installations.AddInstallationRepositories(ctx, installationID, current, true); err != nil {
+		return err
+	}
+	return u.installations.RemoveInstallationRepositories(ctx, installationID, removed)
+}
+
+// saveInstallation keeps the time of the first installation when the installation is already registered
+func (u *InstallationUsecase) saveInstallation(ctx context.Context, installation *model.Installation) error {
+	existing, err := u.installations.GetInstallation(ctx, installation.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	installation.UpdatedAt = now
+	if existing != nil {
+		installation.InstalledAt = existing.InstalledAt
+	} else if installation.InstalledAt.IsZero() {
+		installation.InstalledAt = now
+	}
+
+	return u.installations.SaveInstallation(ctx, installation)
+}
+
+// checkInstallation refuses reviews for installations the registry knows to be suspended or deleted,
+// installations which aren't registered yet are allowed
+func (g *GithubUsecase) checkInstallation(ctx context.Context, installationID int64) error {
+	installation, err := g.installations.GetInstallation(ctx, installationID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		// The registry being unavailable shouldn't stop reviews
+		slog.Warn("error checking installation status, reviewing anyway", "installationID", installationID, "error", err)
+		return nil
+	}
+
+	if installation.Status != model.INSTALLATION_STATUS_ACTIVE {
+		return fmt.Errorf("%w: installation %d of %s is %s", ErrInstallationSuspended, installationID, installation.Account, installation.Status)
+	}
+	return nil
+}
+
+// listInstallations lists the installations of the app, authenticated as the app
+func (g *GithubUsecase) listInstallations(ctx context.Context) ([]*github.Installation, error) {
+	client, err := g.newAppClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return g.repository.ListInstallations(ctx, client)
+}
+
+func toInstallation(githubInstallation *github.Installation) *model.Installation {
+	installation := &model.Installation{
+		ID:                  githubInstallation.GetID(),
+		Account:             githubInstallation.GetAccount().GetLogin(),
+		AccountType:         githubInstallation.GetAccount().GetType(),
+		RepositorySelection: githubInstallation.GetRepositorySelection(),
+		InstalledAt:         githubInstallation.GetCreatedAt().Time,
+	}
+	if githubInstallation.SuspendedAt != nil {
+		suspendedAt := githubInstallation.GetSuspendedAt().Time
+		installation.SuspendedAt = &suspendedAt
+	}
+
+	return installation
+}
+
+func repositoryNames(repositories []*github.Repository) []string {
+	fullNames := make([]string, 0, len(repositories))
+	for _, repository := range repositories {
+		fullNames = append(fullNames, repository.GetFullName())
+	}
+	return fullNames
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
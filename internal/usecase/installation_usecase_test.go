@@ -0,0 +1,367 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/fakegithub"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/secrets"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+	_ "modernc.org/sqlite"
+)
+
+const TEST_APP_ID = 4242
+
+// installationTest is the installation usecase against a fake github and a sqlite registry
+type installationTest struct {
+	usecase       *InstallationUsecase
+	github        *fakegithub.Server
+	installations *repository.SQLReviewRepository
+}
+
+func newInstallationTest(t *testing.T, welcome bool) *installationTest {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := secrets.Static(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	fake := fakegithub.NewServer(TEST_APP_ID, &key.PublicKey)
+	t.Cleanup(fake.Close)
+	githubRepository, err := repository.NewGithubAPIRepository(secrets.Static([]byte("webhook-secret")), privateKey, fake.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "reviews.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	installations, err := repository.NewSQLReviewRepository(context.Background(), db, repository.SQLITE_DIALECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { installations.Close() })
+
+	githubUsecase := NewGithubUsecase(GithubUsecaseDependencies{
+		Github:        githubRepository,
+		Reviews:       installations,
+		Feedback:      installations,
+		Installations: installations,
+		AppID:         TEST_APP_ID,
+		PrivateKey:    privateKey,
+	})
+
+	return &installationTest{
+		usecase:       NewInstallationUsecase(githubUsecase, installations, nil, welcome),
+		github:        fake,
+		installations: installations,
+	}
+}
+
+func (it *installationTest) get(t *testing.T, id int64) *model.Installation {
+	t.Helper()
+
+	installation, err := it.installations.GetInstallation(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return installation
+}
+
+func githubInstallation(id int64, selection string) *github.Installation {
+	return &github.Installation{
+		ID:                  github.Ptr(id),
+		Account:             &github.User{Login: github.Ptr("octo"), Type: github.Ptr("Organization")},
+		RepositorySelection: github.Ptr(selection),
+	}
+}
+
+func githubRepositories(fullNames ...string) []*github.Repository {
+	repositories := make([]*github.Repository, 0, len(fullNames))
+	for _, fullName := range fullNames {
+		repositories = append(repositories, &github.Repository{FullName: github.Ptr(fullName)})
+	}
+	return repositories
+}
+
+func TestRepositoriesToWelcome(t *testing.T) {
+	added := []string{"octo/api", "octo/web"}
+
+	tests := []struct {
+		name      string
+		welcome   bool
+		selection string
+		expected  []string
+	}{
+		{name: "welcomes disabled", welcome: false, selection: "selected"},
+		{name: "selected repositories", welcome: true, selection: "selected", expected: added},
+		{name: "installation on all repositories", welcome: true, selection: ALL_REPOSITORY_SELECTION},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			usecase := NewInstallationUsecase(nil, nil, nil, test.welcome)
+			got := usecase.toWelcome(&model.Installation{ID: 1, Account: "octo", RepositorySelection: test.selection}, added)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInstallationEvent(t *testing.T) {
+	it := newInstallationTest(t, true)
+
+	// Each step is delivered after the previous ones, like the life of an installation
+	tests := []struct {
+		action    string
+		status    string
+		welcome   []string
+		suspended bool
+		deleted   bool
+	}{
+		{action: CREATED_INSTALLATION_ACTION, status: model.INSTALLATION_STATUS_ACTIVE, welcome: []string{"octo/api", "octo/web"}},
+		{action: SUSPEND_INSTALLATION_ACTION, status: model.INSTALLATION_STATUS_SUSPENDED, suspended: true},
+		{action: UNSUSPEND_INSTALLATION_ACTION, status: model.INSTALLATION_STATUS_ACTIVE},
+		{action: "new_permissions_accepted", status: model.INSTALLATION_STATUS_ACTIVE},
+		{action: DELETED_INSTALLATION_ACTION, status: model.INSTALLATION_STATUS_DELETED, deleted: true},
+	}
+
+	var installedAt string
+	for _, test := range tests {
+		t.Run(test.action, func(t *testing.T) {
+			welcome, err := it.usecase.InstallationEvent(context.Background(), &github.InstallationEvent{
+				Action:       github.Ptr(test.action),
+				Installation: githubInstallation(1, "selected"),
+				Repositories: githubRepositories("octo/api", "octo/web"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(welcome, test.welcome) {
+				t.Errorf("expected to welcome %v, got %v", test.welcome, welcome)
+			}
+
+			installation := it.get(t, 1)
+			if installation.Status != test.status || installation.Account != "octo" {
+				t.Errorf("expected a %s installation of octo, got %+v", test.status, installation)
+			}
+			if (installation.SuspendedAt != nil) != test.suspended || (installation.DeletedAt != nil) != test.deleted {
+				t.Errorf("expected suspended %t and deleted %t, got %+v", test.suspended, test.deleted, installation)
+			}
+			if !reflect.DeepEqual(installation.Repositories, []string{"octo/api", "octo/web"}) {
+				t.Errorf("expected the repositories of the installation, got %v", installation.Repositories)
+			}
+
+			// Later events keep the time of the installation
+			if installedAt == "" {
+				installedAt = installation.InstalledAt.String()
+			} else if installation.InstalledAt.String() != installedAt {
+				t.Errorf("expected the installation time %s to be kept, got %s", installedAt, installation.InstalledAt)
+			}
+		})
+	}
+}
+
+func TestInstallationEventOnAllRepositoriesWelcomesNobody(t *testing.T) {
+	it := newInstallationTest(t, true)
+
+	welcome, err := it.usecase.InstallationEvent(context.Background(), &github.InstallationEvent{
+		Action:       github.Ptr(CREATED_INSTALLATION_ACTION),
+		Installation: githubInstallation(1, ALL_REPOSITORY_SELECTION),
+		Repositories: githubRepositories("octo/api"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if welcome != nil {
+		t.Errorf("expected nobody to be welcomed, got %v", welcome)
+	}
+	if repositories := it.get(t, 1).Repositories; !reflect.DeepEqual(repositories, []string{"octo/api"}) {
+		t.Errorf("expected the repository to be registered, got %v", repositories)
+	}
+}
+
+func TestInstallationRepositoriesEvent(t *testing.T) {
+	it := newInstallationTest(t, true)
+
+	tests := []struct {
+		name         string
+		action       string
+		added        []string
+		removed      []string
+		welcome      []string
+		repositories []string
+	}{
+		// The installation isn't registered yet, it is registered with the event
+		{name: "added to an unregistered installation", action: ADDED_REPOSITORIES_ACTION, added: []string{"octo/api", "octo/web"}, welcome: []string{"octo/api", "octo/web"}, repositories: []string{"octo/api", "octo/web"}},
+		{name: "removed", action: REMOVED_REPOSITORIES_ACTION, removed: []string{"octo/web"}, repositories: []string{"octo/api"}},
+		{name: "added again", action: ADDED_REPOSITORIES_ACTION, added: []string{"octo/web"}, welcome: []string{"octo/web"}, repositories: []string{"octo/api", "octo/web"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			welcome, err := it.usecase.InstallationRepositoriesEvent(context.Background(), &github.InstallationRepositoriesEvent{
+				Action:              github.Ptr(test.action),
+				Installation:        githubInstallation(1, "selected"),
+				RepositoriesAdded:   githubRepositories(test.added...),
+				RepositoriesRemoved: githubRepositories(test.removed...),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(welcome, test.welcome) {
+				t.Errorf("expected to welcome %v, got %v", test.welcome, welcome)
+			}
+
+			installation := it.get(t, 1)
+			if installation.Status != model.INSTALLATION_STATUS_ACTIVE {
+				t.Errorf("expected an active installation, got %s", installation.Status)
+			}
+			if !reflect.DeepEqual(installation.Repositories, test.repositories) {
+				t.Errorf("expected repositories %v, got %v", test.repositories, installation.Repositories)
+			}
+		})
+	}
+}
+
+func TestWelcomeOpensOneIssuePerRepository(t *testing.T) {
+	it := newInstallationTest(t, true)
+	it.github.AddInstallation(1, "octo", "octo/api", "octo/web")
+
+	ctx := context.Background()
+	welcome, err := it.usecase.InstallationEvent(ctx, &github.InstallationEvent{
+		Action:       github.Ptr(CREATED_INSTALLATION_ACTION),
+		Installation: githubInstallation(1, "selected"),
+		Repositories: githubRepositories("octo/api", "octo/web"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Redelivered events don't open a second issue
+	for range 2 {
+		if err := it.usecase.Welcome(ctx, 1, welcome); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, fullName := range welcome {
+		issues := it.github.Issues(fullName)
+		if len(issues) != 1 || issues[0].GetTitle() != WELCOME_ISSUE_TITLE {
+			t.Fatalf("expected a single welcome issue on %s, got %d", fullName, len(issues))
+		}
+		// The issue explains where the repository configures reviews
+		for _, configuration := range []string{utils.REPOSITORY_PROMPTS_DIR, utils.PROMPT_CONFIG_FILE, utils.REPOSITORY_LANGUAGE_PROFILES_FILE, "front matter", utils.RULE_JSON_SCOPE_KEY} {
+			if !strings.Contains(issues[0].GetBody(), configuration) {
+				t.Errorf("expected the welcome issue to mention %s", configuration)
+			}
+		}
+	}
+}
+
+func TestSyncInstallations(t *testing.T) {
+	it := newInstallationTest(t, true)
+	ctx := context.Background()
+
+	it.github.AddInstallation(1, "octo", "octo/api", "octo/web")
+	it.github.AddInstallation(2, "hubber", "hubber/dotfiles")
+	it.github.SuspendInstallation(2)
+
+	// Registered before the server went down: a repository was removed from installation 1 since, and
+	// installation 3 was uninstalled
+	for _, installation := range []*model.Installation{
+		{ID: 1, Account: "octo", Status: model.INSTALLATION_STATUS_ACTIVE},
+		{ID: 3, Account: "gone", Status: model.INSTALLATION_STATUS_ACTIVE},
+	} {
+		if err := it.installations.SaveInstallation(ctx, installation); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := it.installations.AddInstallationRepositories(ctx, 1, []string{"octo/api", "octo/old"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := it.usecase.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id           int64
+		status       string
+		repositories []string
+	}{
+		{id: 1, status: model.INSTALLATION_STATUS_ACTIVE, repositories: []string{"octo/api", "octo/web"}},
+		// Suspended installations can't list their repositories
+		{id: 2, status: model.INSTALLATION_STATUS_SUSPENDED, repositories: []string{}},
+		{id: 3, status: model.INSTALLATION_STATUS_DELETED, repositories: []string{}},
+	}
+	for _, test := range tests {
+		installation := it.get(t, test.id)
+		if installation.Status != test.status || !reflect.DeepEqual(installation.Repositories, test.repositories) {
+			t.Errorf("expected installation %d to be %s with %v, got %s with %v", test.id, test.status, test.repositories, installation.Status, installation.Repositories)
+		}
+	}
+
+	// Repositories found by syncing predate the registry, they are not welcomed
+	if claimed, err := it.installations.ClaimRepositoryWelcome(ctx, 1, "octo/web"); err != nil || claimed {
+		t.Errorf("expected a synced repository to count as welcomed, got claimed %t and error %v", claimed, err)
+	}
+	if issues := it.github.Issues("octo/web"); len(issues) != 0 {
+		t.Errorf("expected no welcome issue, got %d", len(issues))
+	}
+}
+
+func TestCheckInstallation(t *testing.T) {
+	it := newInstallationTest(t, false)
+	ctx := context.Background()
+
+	for id, status := range map[int64]string{
+		1: model.INSTALLATION_STATUS_ACTIVE,
+		2: model.INSTALLATION_STATUS_SUSPENDED,
+		3: model.INSTALLATION_STATUS_DELETED,
+	} {
+		if err := it.installations.SaveInstallation(ctx, &model.Installation{ID: id, Account: "octo", Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		id      int64
+		refused bool
+	}{
+		{name: "active", id: 1},
+		{name: "suspended", id: 2, refused: true},
+		{name: "deleted", id: 3, refused: true},
+		// Installations from before the registry are reviewed until they are synced
+		{name: "not registered", id: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := it.usecase.github.checkInstallation(ctx, test.id)
+			if refused := errors.Is(err, ErrInstallationSuspended); refused != test.refused {
+				t.Errorf("expected refused to be %t, got %v", test.refused, err)
+			}
+			if !test.refused && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// LLMRepository is implemented by every LLM provider the reviewer can run against
+type LLMRepository interface {
+	// Model returns the name of the model reviews are generated with
+	Model() string
+	// Ping checks the provider is reachable and the model can be used, without generating anything
+	Ping(ctx context.Context) error
+	// GetCodeReviews reviews the formatted diff and returns the comments to post, and the tokens used
+	GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) ([]model.ReviewCommentRequest, model.TokenUsage, error)
+	// CheckIssueResolved decides whether a previous review comment is addressed by the updated code
+	CheckIssueResolved(ctx context.Context, comment string, originalHunk string, updatedCode string) (*model.IssueResolution, model.TokenUsage, error)
+	// Summarize writes a short markdown summary of the formatted diff
+	Summarize(ctx context.Context, code string, promptCtx model.PromptContext) (string, model.TokenUsage, error)
+}
+
+// ContextCachingLLM is implemented by providers which can cache the review context, the system prompt with the
+// rules, language profile and repository context every chunk of a pull request shares, so chunks reviewed with
+// a cached context don't send it again. Providers which don't implement it get the whole prompt with every chunk.
+type ContextCachingLLM interface {
+	// CacheReviewContext caches the context of reviews with the prompt context, or keeps the one cached already.
+	// Reviews send the whole prompt when it fails.
+	CacheReviewContext(ctx context.Context, promptCtx model.PromptContext) error
+}
+
+// cacheReviewContext caches the review context when the provider supports it, failing to only costs tokens
+func cacheReviewContext(ctx context.Context, llm LLMRepository, promptCtx model.PromptContext) {
+	cachingLLM, ok := llm.(ContextCachingLLM)
+	if !ok {
+		return
+	}
+
+	if err := cachingLLM.CacheReviewContext(ctx, promptCtx); err != nil {
+		slog.Warn("error caching review context, sending it with every chunk", "error", err, "model", llm.Model())
+	}
+}
+
+// instrumentedLLM records latency, outcome and token usage of every LLM call in the metrics
+type instrumentedLLM struct {
+	LLMRepository
+}
+
+// NewInstrumentedLLM wraps an LLM provider so its calls show up in the metrics
+func NewInstrumentedLLM(llm LLMRepository) LLMRepository {
+	return &instrumentedLLM{LLMRepository: llm}
+}
+
+func (i *instrumentedLLM) GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) ([]model.ReviewCommentRequest, model.TokenUsage, error) {
+	start := time.Now()
+	reviews, usage, err := i.LLMRepository.GetCodeReviews(ctx, code, promptCtx)
+	i.observe(model.LLM_OPERATION_CODE_REVIEW, start, usage, err)
+
+	return reviews, usage, err
+}
+
+// CacheReviewContext passes the review context on to the provider, when it can cache it
+func (i *instrumentedLLM) CacheReviewContext(ctx context.Context, promptCtx model.PromptContext) error {
+	cachingLLM, ok := i.LLMRepository.(ContextCachingLLM)
+	if !ok {
+		return nil
+	}
+	return cachingLLM.CacheReviewContext(ctx, promptCtx)
+}
+
+func (i *instrumentedLLM) CheckIssueResolved(ctx context.Context, comment string, originalHunk string, updatedCode string) (*model.IssueResolution, model.TokenUsage, error) {
+	start := time.Now()
+	resolution, usage, err := i.LLMRepository.CheckIssueResolved(ctx, comment, originalHunk, updatedCode)
+	i.observe(model.LLM_OPERATION_RESOLUTION_CHECK, start, usage, err)
+
+	return resolution, usage, err
+}
+
+func (i *instrumentedLLM) Summarize(ctx context.Context, code string, promptCtx model.PromptContext) (string, model.TokenUsage, error) {
+	start := time.Now()
+	summary, usage, err := i.LLMRepository.Summarize(ctx, code, promptCtx)
+	i.observe(model.LLM_OPERATION_SUMMARY, start, usage, err)
+
+	return summary, usage, err
+}
+
+func (i *instrumentedLLM) observe(operation string, start time.Time, usage model.TokenUsage, err error) {
+	llmModel := i.Model()
+
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+
+	metrics.LLMRequests.WithLabelValues(llmModel, operation, status).Inc()
+	metrics.LLMRequestDuration.WithLabelValues(llmModel, operation).Observe(time.Since(start).Seconds())
+	metrics.LLMTokens.WithLabelValues(llmModel, "prompt").Add(float64(usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(llmModel, "completion").Add(float64(usage.CompletionTokens))
+	metrics.LLMTokens.WithLabelValues(llmModel, "cached_prompt").Add(float64(usage.CachedPromptTokens))
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// Files sent to the LLM at once, matching the page size github uses for pull request files
+const LOCAL_REVIEW_FILES_PER_CHUNK = 30
+
+// LocalReviewUsecase reviews diffs from a local checkout without talking to github
+type LocalReviewUsecase struct {
+	git          *repository.GitRepository
+	llm          LLMRepository
+	redactor     *utils.Redactor
+	outputPolicy *OutputPolicy
+	// Prompt version and overrides every chunk is reviewed with
+	prompt model.PromptContext
+	// Languages files are grouped by, each group is reviewed with the checklist of its language
+	profiles *utils.LanguageProfiles
+	// Reviews of chunks reviewed before, nil reviews everything with the LLM
+	cache *ReviewCache
+}
+
+func NewLocalReviewUsecase(git *repository.GitRepository, llm LLMRepository, redactor *utils.Redactor, outputPolicy *OutputPolicy, prompt model.PromptContext, profiles *utils.LanguageProfiles, cache *ReviewCache) *LocalReviewUsecase {
+	return &LocalReviewUsecase{
+		git:          git,
+		llm:          llm,
+		redactor:     redactor,
+		outputPolicy: outputPolicy,
+		prompt:       prompt,
+		profiles:     profiles,
+		cache:        cache,
+	}
+}
+
+// PromptVersion is the prompt version reviews are generated with, including the overrides
+func (l *LocalReviewUsecase) PromptVersion() string {
+	return utils.PromptVersionID(l.prompt.PromptVersion, l.prompt.PromptOverrides)
+}
+
+// ReviewGitDiff reviews the output of git diff with the given arguments, e.g. a range such as main...HEAD
+func (l *LocalReviewUsecase) ReviewGitDiff(ctx context.Context, args ...string) ([]model.ReviewCommentRequest, error) {
+	diff, err := l.git.Diff(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.ReviewDiff(ctx, diff)
+}
+
+// ReviewDiff reviews a unified diff, such as a patch file
+func (l *LocalReviewUsecase) ReviewDiff(ctx context.Context, diff string) ([]model.ReviewCommentRequest, error) {
+	files := utils.ParseUnifiedDiff(diff)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("the diff does not contain any changed files")
+	}
+
+	// Secrets are reported first, and masked in what is sent to the LLM
+	files, secretFindings := l.redactor.RedactFiles(files)
+	reviews := secretComments(secretFindings, "")
+	for start := 0; start < len(files); start += LOCAL_REVIEW_FILES_PER_CHUNK {
+		end := min(start+LOCAL_REVIEW_FILES_PER_CHUNK, len(files))
+
+		// Each language is reviewed in a call of its own, with its checklist and the rules applying to its files
+		for _, group := range l.profiles.Group(files[start:end]) {
+			languageCtx, err := reviewPromptContext(l.prompt, group)
+			if err != nil {
+				return nil, err
+			}
+			languageReviews, _, cached, err := reviewChunk(ctx, l.llm, l.cache, group.Files, languageCtx)
+			if err != nil {
+				return nil, fmt.Errorf("error getting code reviews from LLM: %w", err)
+			}
+			slog.Debug("reviews have been created by the LLM", "number_of_reviews", len(languageReviews), "language", languageCtx.Language, "cached", cached)
+
+			reviews = append(reviews, l.outputPolicy.Filter(languageReviews, group.Files)...)
+		}
+	}
+
+	return reviews, nil
+}
@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// DEFAULT_ALLOWED_LINK_HOSTS are the documentation sites review comments may link to, subdomains included
+var DEFAULT_ALLOWED_LINK_HOSTS = []string{
+	"docs.github.com",
+	"go.dev",
+	"golang.org",
+	"developer.mozilla.org",
+	"owasp.org",
+	"cwe.mitre.org",
+	"docs.python.org",
+	"nodejs.org",
+	"typescriptlang.org",
+	"rust-lang.org",
+	"docs.oracle.com",
+	"learn.microsoft.com",
+	"kubernetes.io",
+	"postgresql.org",
+}
+
+var (
+	linkHostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	loginPattern    = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,38})(?:/[a-z0-9_.-]+)?$`)
+
+	fencedCodePattern = regexp.MustCompile("(?s)```.*?(?:```|$)|~~~.*?(?:~~~|$)")
+	inlineCodePattern = regexp.MustCompile("`[^`\n]+`")
+	// Anything with a scheme, or starting with www. which github links too
+	urlPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)[^\s<>"'` + "`" + `]+`)
+	// Targets of markdown links and images, and of src and href attributes
+	linkTargetPattern = regexp.MustCompile(`(?i)\]\(\s*<?([^)\s>]+)|(?:src|href)\s*=\s*["']?([^"'\s>]+)`)
+	// An @ not preceded by a word character, so email addresses aren't mentions
+	mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@./-])@([A-Za-z0-9](?:[A-Za-z0-9-]{0,38})(?:/[A-Za-z0-9_.-]+)?)`)
+)
+
+// OutputPolicy checks what the LLM generated before it is posted, as the diff it read may have been written
+// to make it comment elsewhere, link to a phishing site or ping people. A nil OutputPolicy only checks paths.
+type OutputPolicy struct {
+	linkHosts []string
+	// Lowercase logins and org/team names which may be mentioned
+	mentions map[string]bool
+}
+
+func NewOutputPolicy(linkHosts []string, mentions []string) *OutputPolicy {
+	policy := &OutputPolicy{
+		linkHosts: make([]string, 0, len(linkHosts)),
+		mentions:  map[string]bool{},
+	}
+	for _, host := range linkHosts {
+		policy.linkHosts = append(policy.linkHosts, strings.ToLower(host))
+	}
+	for _, mention := range mentions {
+		policy.mentions[strings.ToLower(strings.TrimPrefix(mention, "@"))] = true
+	}
+
+	return policy
+}
+
+// ParseLinkHosts parses a comma separated list of hosts, like go.dev
+func ParseLinkHosts(value string) ([]string, error) {
+	hosts := splitList(value)
+	for _, host := range hosts {
+		if !linkHostPattern.MatchString(strings.ToLower(host)) {
+			return nil, fmt.Errorf("invalid host %q, expected a host name without a scheme or path like go.dev", host)
+		}
+	}
+
+	return hosts, nil
+}
+
+// ParseMentions parses a comma separated list of logins or org/team names, with or without the @
+func ParseMentions(value string) ([]string, error) {
+	mentions := splitList(value)
+	for _, mention := range mentions {
+		if !loginPattern.MatchString(strings.ToLower(strings.TrimPrefix(mention, "@"))) {
+			return nil, fmt.Errorf("invalid mention %q, expected a github login or org/team", mention)
+		}
+	}
+
+	return mentions, nil
+}
+
+// Filter drops the comments which don't meet the policy, files are those of the diff the comments were generated from
+func (o *OutputPolicy) Filter(reviews []model.ReviewCommentRequest, files []model.PRFile) []model.ReviewCommentRequest {
+	paths := make(map[string]bool, len(files))
+	for _, file := range files {
+		paths[file.Filename] = true
+	}
+
+	filtered := make([]model.ReviewCommentRequest, 0, len(reviews))
+	for _, review := range reviews {
+		reason := ""
+		if !paths[review.Path] {
+			reason = "the file is not in the diff"
+		} else {
+			reason = o.CheckBody(review.Body)
+		}
+
+		if reason != "" {
+			slog.Warn("rejecting review comment generated by the LLM", "reason", reason, "path", review.Path, "line", review.Line)
+			metrics.ReviewComments.WithLabelValues(metrics.COMMENT_REJECTED).Inc()
+			continue
+		}
+		filtered = append(filtered, review)
+	}
+
+	return filtered
+}
+
+// CheckBody returns why the markdown can't be posted, or an empty string when it can.
+// Code blocks and spans are skipped as github neither links nor notifies from them.
+func (o *OutputPolicy) CheckBody(body string) string {
+	if o == nil {
+		return ""
+	}
+
+	text := fencedCodePattern.ReplaceAllString(body, "")
+	text = inlineCodePattern.ReplaceAllString(text, "")
+
+	for _, link := range urlPattern.FindAllString(text, -1) {
+		if !o.allowedLink(link) {
+			return fmt.Sprintf("links to %q which is not an allowed host", link)
+		}
+	}
+	for _, match := range linkTargetPattern.FindAllStringSubmatch(text, -1) {
+		target := match[1] + match[2]
+		// Relative links stay on github
+		if !strings.Contains(target, ":") && !strings.HasPrefix(target, "//") {
+			continue
+		}
+		if !o.allowedLink(target) {
+			return fmt.Sprintf("links to %q which is not an allowed host", target)
+		}
+	}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if !o.mentions[strings.ToLower(match[1])] {
+			return fmt.Sprintf("mentions @%s who is not allowed", match[1])
+		}
+	}
+
+	return ""
+}
+
+// allowedLink reports whether the link is http or https to an allowed host or one of its subdomains
+func (o *OutputPolicy) allowedLink(link string) bool {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		link = "https://" + link
+	}
+	if strings.HasPrefix(link, "//") {
+		link = "https:" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range o.linkHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+func TestOutputPolicyFilter(t *testing.T) {
+	policy := NewOutputPolicy(DEFAULT_ALLOWED_LINK_HOSTS, []string{"octo-org/security"})
+	files := []model.PRFile{{Filename: "handler.go"}, {Filename: "docs/README.md"}}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		allowed bool
+	}{
+		{name: "plain comment", path: "handler.go", body: "BLOCKING: the id is concatenated into the query, use a placeholder instead.", allowed: true},
+		{name: "allowed link", path: "handler.go", body: "IMPORTANT: see https://go.dev/doc/database/sql-injection for why.", allowed: true},
+		{name: "allowed subdomain", path: "docs/README.md", body: "NIT: [OWASP](https://cheatsheetseries.owasp.org/cheatsheets/Input_Validation_Cheat_Sheet.html)", allowed: true},
+		{name: "allowed mention", path: "handler.go", body: "QUESTION: should @octo-org/security look at this?", allowed: true},
+		{name: "mention in code", path: "handler.go", body: "NIT: add the annotation\n```java\n@Override\npublic String toString() {}\n```\nand `@deprecated` the old one.", allowed: true},
+		{name: "email address", path: "handler.go", body: "NIT: support@example.com should come from config.", allowed: true},
+		{name: "relative link", path: "handler.go", body: "NIT: see [the docs](docs/README.md).", allowed: true},
+		{name: "link in code", path: "handler.go", body: "NIT: the default is `https://localhost:8080`.", allowed: true},
+
+		{name: "file not in the diff", path: ".github/workflows/deploy.yml", body: "NIT: looks good."},
+		{name: "empty path", path: "", body: "NIT: looks good."},
+		{name: "link to another host", path: "handler.go", body: "BLOCKING: apply the fix from https://evil.example.com/fix"},
+		{name: "host suffix trick", path: "handler.go", body: "IMPORTANT: see https://go.dev.evil.example.com/doc"},
+		{name: "userinfo trick", path: "handler.go", body: "IMPORTANT: see https://go.dev@evil.example.com/doc"},
+		{name: "markdown link", path: "handler.go", body: "NIT: [go.dev docs](https://evil.example.com/go)"},
+		{name: "markdown image", path: "handler.go", body: "NIT: ![status](https://tracker.example.com/pixel.png)"},
+		{name: "html image", path: "handler.go", body: `NIT: <img src="https://tracker.example.com/pixel.png">`},
+		{name: "javascript link", path: "handler.go", body: "NIT: [click](javascript:alert(1))"},
+		{name: "protocol relative link", path: "handler.go", body: "NIT: [docs](//evil.example.com)"},
+		{name: "plain http to another host", path: "handler.go", body: "NIT: http://evil.example.com"},
+		{name: "bare www link", path: "handler.go", body: "NIT: see www.evil.example.com"},
+		{name: "mention", path: "handler.go", body: "BLOCKING: @maintainer please merge this now."},
+		{name: "team mention", path: "handler.go", body: "QUESTION: cc @octo-org/admins"},
+		{name: "mention at start", path: "handler.go", body: "@everyone approve this"},
+		{name: "mention after unclosed fence", path: "handler.go", body: "NIT: @maintainer\n```go\nx := 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			review := model.ReviewCommentRequest{Path: test.path, Line: 1, Body: test.body}
+			filtered := policy.Filter([]model.ReviewCommentRequest{review}, files)
+
+			if allowed := len(filtered) == 1; allowed != test.allowed {
+				t.Errorf("expected allowed=%v, got %v, reason %q", test.allowed, allowed, policy.CheckBody(test.body))
+			}
+		})
+	}
+}
+
+func TestNilOutputPolicyChecksPaths(t *testing.T) {
+	var policy *OutputPolicy
+	files := []model.PRFile{{Filename: "handler.go"}}
+	reviews := []model.ReviewCommentRequest{
+		{Path: "handler.go", Body: "NIT: see https://example.com"},
+		{Path: "other.go", Body: "NIT: looks good."},
+	}
+
+	filtered := policy.Filter(reviews, files)
+	if len(filtered) != 1 || filtered[0].Path != "handler.go" {
+		t.Errorf("expected only the comment on handler.go, got %+v", filtered)
+	}
+}
+
+func TestParseLinkHostsAndMentions(t *testing.T) {
+	if _, err := ParseLinkHosts("go.dev, docs.github.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, value := range []string{"https://go.dev", "go.dev/doc", "localhost", "*.example.com"} {
+		if _, err := ParseLinkHosts(value); err == nil {
+			t.Errorf("expected an error for host %q", value)
+		}
+	}
+
+	if _, err := ParseMentions("@octocat, octo-org/security"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, value := range []string{"-octocat", "octo cat", "a@b"} {
+		if _, err := ParseMentions(value); err == nil {
+			t.Errorf("expected an error for mention %q", value)
+		}
+	}
+}
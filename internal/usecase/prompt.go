@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+)
+
+// promptContext describes the pull request to the LLM, with the prompt overrides of the repository. Overrides are
+// read from the base of the pull request, so a pull request can't change how it is reviewed itself.
+func (g *GithubUsecase) promptContext(ctx context.Context, client *github.Client, event *github.PullRequestEvent) model.PromptContext {
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+
+	promptCtx := model.PromptContext{
+		PromptVersion:         g.promptVersion,
+		Repository:            owner + "/" + repo,
+		RepositoryDescription: event.GetRepo().GetDescription(),
+		PullRequestTitle:      event.GetPullRequest().GetTitle(),
+		PullRequestBody:       event.GetPullRequest().GetBody(),
+	}
+
+	// Without a base commit the files are read from the default branch
+	ref := event.GetPullRequest().GetBase().GetSHA()
+	overrides, err := utils.ReadPromptOverrides(func(name string) (string, bool, error) {
+		return g.repository.GetFileContent(ctx, client, owner, repo, path.Join(utils.REPOSITORY_PROMPTS_DIR, name), ref)
+	})
+	if err == nil {
+		_, err = utils.LoadPromptTemplates(g.promptVersion, overrides)
+	}
+	if err != nil {
+		slog.Warn("ignoring the prompt overrides of the repository", "error", err, "owner", owner, "repo", repo)
+		return promptCtx
+	}
+
+	promptCtx.PromptOverrides = overrides
+	return promptCtx
+}
+
+// ReadLocalPromptOverrides reads prompt overrides from a directory of a local checkout, an empty dir reads nothing
+func ReadLocalPromptOverrides(dir string) (model.PromptOverrides, error) {
+	if dir == "" {
+		return model.PromptOverrides{}, nil
+	}
+
+	return utils.ReadPromptOverrides(func(name string) (string, bool, error) {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(content), true, nil
+	})
+}
+
+// languageProfiles returns the bundled language profiles with the additions of the repository, read from the base
+// of the pull request like the prompt overrides
+func (g *GithubUsecase) languageProfiles(ctx context.Context, client *github.Client, event *github.PullRequestEvent) *utils.LanguageProfiles {
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+
+	content, _, err := g.repository.GetFileContent(ctx, client, owner, repo, utils.REPOSITORY_LANGUAGE_PROFILES_FILE, event.GetPullRequest().GetBase().GetSHA())
+	if err != nil {
+		slog.Warn("failed to read the language profiles of the repository", "error", err, "owner", owner, "repo", repo)
+		content = ""
+	}
+	profiles, err := utils.LoadLanguageProfiles(content)
+	if err != nil {
+		slog.Warn("ignoring the language profiles of the repository", "error", err, "owner", owner, "repo", repo)
+		profiles, _ = utils.LoadLanguageProfiles("")
+	}
+	return profiles
+}
+
+// ReadLocalLanguageProfiles returns the bundled language profiles with the additions in file,
+// an empty or missing file adds nothing
+func ReadLocalLanguageProfiles(file string) (*utils.LanguageProfiles, error) {
+	if file == "" {
+		return utils.LoadLanguageProfiles("")
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read the language profiles: %w", err)
+	}
+	return utils.LoadLanguageProfiles(string(content))
+}
+
+// reviewPromptContext is the prompt context of a review of the files of a language, with the checklist of the
+// language and the repository rules applying to the files. Files of unknown languages are reviewed without a checklist.
+func reviewPromptContext(promptCtx model.PromptContext, group utils.LanguageGroup) (model.PromptContext, error) {
+	if group.Profile != nil {
+		promptCtx.Language = group.Profile.Name
+		promptCtx.LanguageChecklist = group.Profile.Checklist
+		promptCtx.LanguageRules = group.Profile.Rules
+	}
+
+	filenames := make([]string, 0, len(group.Files))
+	for _, file := range group.Files {
+		filenames = append(filenames, file.Filename)
+	}
+	rules, err := utils.RepositoryRulesFor(filenames, promptCtx.Language)
+	if err != nil {
+		return model.PromptContext{}, fmt.Errorf("failed to load the repository rules: %w", err)
+	}
+	promptCtx.Rules = rules
+
+	return promptCtx, nil
+}
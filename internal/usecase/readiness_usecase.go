@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+)
+
+// How long a single readiness check may take before it counts as failed
+const READINESS_CHECK_TIMEOUT = 5 * time.Second
+
+// How long the result of a check calling a remote dependency is reused, probes within it don't call the dependency
+const READINESS_CACHE_TTL = 15 * time.Second
+
+// ReadinessUsecase checks the dependencies a review needs, unlike the liveness check which only
+// says the process is up
+type ReadinessUsecase struct {
+	llm     LLMRepository
+	github  *GithubUsecase
+	reviews repository.ReviewRepository
+	queue   *ReviewQueue
+
+	// Last results of the checks calling github, the LLM and storage
+	llmCheck, githubCheck, storageCheck *cachedReadinessCheck
+}
+
+// cachedReadinessCheck keeps the last result of a check until it is older than the ttl. Probes arriving while
+// it runs wait for its result instead of running it again.
+type cachedReadinessCheck struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	result model.ReadinessCheck
+}
+
+func NewReadinessUsecase(llm LLMRepository, github *GithubUsecase, reviews repository.ReviewRepository, queue *ReviewQueue) *ReadinessUsecase {
+	return &ReadinessUsecase{
+		llm:     llm,
+		github:  github,
+		reviews: reviews,
+		queue:   queue,
+
+		llmCheck:     &cachedReadinessCheck{ttl: READINESS_CACHE_TTL},
+		githubCheck:  &cachedReadinessCheck{ttl: READINESS_CACHE_TTL},
+		storageCheck: &cachedReadinessCheck{ttl: READINESS_CACHE_TTL},
+	}
+}
+
+// Ready runs every check concurrently and reports each of them. Checks calling remote dependencies reuse
+// their last result for READINESS_CACHE_TTL, the queue is always checked.
+func (u *ReadinessUsecase) Ready(ctx context.Context) model.Readiness {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) (string, error)
+		// nil when the check runs on every probe
+		cache *cachedReadinessCheck
+	}{
+		{model.READINESS_CHECK_LLM, u.checkLLM, u.llmCheck},
+		{model.READINESS_CHECK_GITHUB, u.checkGithub, u.githubCheck},
+		{model.READINESS_CHECK_STORAGE, u.checkStorage, u.storageCheck},
+		{model.READINESS_CHECK_QUEUE, u.checkQueue, nil},
+	}
+
+	readiness := model.Readiness{
+		Ready:  true,
+		Checks: make([]model.ReadinessCheck, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readiness.Checks[i] = check.cache.run(ctx, check.name, check.check)
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range readiness.Checks {
+		if !check.Ready {
+			readiness.Ready = false
+		}
+	}
+
+	return readiness
+}
+
+// run returns the last result of the check while it is fresh, and runs it otherwise. A nil cache always runs it.
+func (c *cachedReadinessCheck) run(ctx context.Context, name string, check func(ctx context.Context) (string, error)) model.ReadinessCheck {
+	if c == nil {
+		return runReadinessCheck(ctx, name, check)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.result.CheckedAt.IsZero() && time.Since(c.result.CheckedAt) < c.ttl {
+		return c.result
+	}
+	c.result = runReadinessCheck(ctx, name, check)
+	return c.result
+}
+
+func runReadinessCheck(ctx context.Context, name string, check func(ctx context.Context) (string, error)) model.ReadinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, READINESS_CHECK_TIMEOUT)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+
+	result := model.ReadinessCheck{
+		Name:       name,
+		Ready:      err == nil,
+		Detail:     detail,
+		DurationMs: time.Since(start).Milliseconds(),
+		CheckedAt:  start,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func (u *ReadinessUsecase) checkLLM(ctx context.Context) (string, error) {
+	return u.llm.Model(), u.llm.Ping(ctx)
+}
+
+func (u *ReadinessUsecase) checkGithub(ctx context.Context) (string, error) {
+	slug, err := u.github.CheckAppAuth(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return "authenticated as " + slug, nil
+}
+
+func (u *ReadinessUsecase) checkStorage(ctx context.Context) (string, error) {
+	return "", u.reviews.CheckWritable(ctx)
+}
+
+func (u *ReadinessUsecase) checkQueue(ctx context.Context) (string, error) {
+	detail := fmt.Sprintf("%d/%d queued, %d/%d workers busy", u.queue.Pending(), u.queue.Capacity(), u.queue.Busy(), u.queue.Workers())
+	if u.queue.Saturated() {
+		return detail, fmt.Errorf("review queue is saturated")
+	}
+
+	return detail, nil
+}
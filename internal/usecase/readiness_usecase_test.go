@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedReadinessCheckReusesResults(t *testing.T) {
+	var calls atomic.Int32
+	check := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "", errors.New("github is down")
+	}
+	cache := &cachedReadinessCheck{ttl: time.Hour}
+
+	// Probes arriving together wait for one run of the check
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result := cache.run(context.Background(), "github", check); result.Ready || result.Error != "github is down" {
+				t.Errorf("expected the failure to be reported, got %+v", result)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("expected the check to run once, ran %d times", calls.Load())
+	}
+
+	// Expired results are checked again
+	cache.result.CheckedAt = time.Now().Add(-2 * time.Hour)
+	cache.run(context.Background(), "github", check)
+	if calls.Load() != 2 {
+		t.Errorf("expected an expired result to be checked again, ran %d times", calls.Load())
+	}
+
+	// Checks without a cache run on every probe
+	var uncached *cachedReadinessCheck
+	uncached.run(context.Background(), "queue", check)
+	uncached.run(context.Background(), "queue", check)
+	if calls.Load() != 4 {
+		t.Errorf("expected uncached checks to run every time, ran %d times", calls.Load())
+	}
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// SECRET_COMMENT is posted on lines which add a secret, it names the kind of secret but never repeats it
+const SECRET_COMMENT = "BLOCKING: This line adds what looks like a %s. Anything committed stays in the git history even after it is removed, so revoke and rotate it now, then load it from the environment or a secret manager instead. The value was masked before the code was sent to the AI reviewer."
+
+// secretComments builds a review comment for each secret added by the pull request
+func secretComments(findings []model.SecretFinding, commitID string) []model.ReviewCommentRequest {
+	comments := make([]model.ReviewCommentRequest, 0, len(findings))
+	for _, finding := range findings {
+		metrics.SecretsDetected.WithLabelValues(finding.Rule).Inc()
+
+		comments = append(comments, model.ReviewCommentRequest{
+			Body:     fmt.Sprintf(SECRET_COMMENT, finding.Description),
+			CommitID: commitID,
+			Path:     finding.Path,
+			Line:     finding.Line,
+		})
+	}
+
+	return comments
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/tracing"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+	"github.com/google/go-github/v74/github"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// How many lines either side of the flagged line are sent to the LLM when checking a fix
+const RESOLUTION_CONTEXT_LINES = 15
+
+// resolveFixedComments goes through the bot's unresolved review threads after new commits were pushed,
+// and resolves the ones whose issue has been fixed. Threads on lines that didn't change are left untouched.
+func (g *GithubUsecase) resolveFixedComments(ctx context.Context, event *github.PullRequestEvent) (err error) {
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	pullNumber := event.GetPullRequest().GetNumber()
+	installationID := event.Installation.GetID()
+	before := event.GetBefore()
+	after := event.GetAfter()
+	if after == "" {
+		after = event.GetPullRequest().GetHead().GetSHA()
+	}
+
+	ctx, span := tracing.Start(ctx, "GithubUsecase.resolveFixedComments", tracing.PullRequestAttributes(owner, repo, pullNumber)...)
+	span.SetAttributes(attribute.String("github.before_sha", before), attribute.String("github.after_sha", after))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	slog.Info("Checking previous review comments for fixes",
+		"owner", owner,
+		"repo", repo,
+		"pullNumber", pullNumber,
+		"before", before,
+		"after", after)
+
+	// Following up isn't worth spending more once over budget, unless a smaller model is configured
+	llm := g.budget.LLMFor(g.checkBudget(ctx, installationID, owner, repo), g.llm)
+	if llm == nil {
+		slog.Info("budget exceeded, not checking previous review comments", "owner", owner, "repo", repo, "pullNumber", pullNumber)
+		return nil
+	}
+	llmCall := model.LLMCall{
+		InstallationID: installationID,
+		Owner:          owner,
+		Repo:           repo,
+		Model:          llm.Model(),
+		Operation:      model.LLM_OPERATION_RESOLUTION_CHECK,
+	}
+
+	client, err := g.newInstallationClient(ctx, installationID)
+	if err != nil {
+		return err
+	}
+
+	appSlug, err := g.getAppSlug(ctx)
+	if err != nil {
+		return fmt.Errorf("error retrieving github app slug: %v", err)
+	}
+
+	threads, err := g.repository.ListReviewThreads(ctx, client, owner, repo, pullNumber)
+	if err != nil {
+		return fmt.Errorf("error listing review threads: %v", err)
+	}
+
+	// Find which files the push changed, if this fails we can still rely on github marking threads as outdated
+	patches := map[string]string{}
+	if before != "" {
+		files, err := g.repository.CompareCommits(ctx, client, owner, repo, before, after)
+		if err != nil {
+			slog.Warn("error comparing pushed commits, relying on outdated threads only", "error", err)
+		}
+		for _, file := range files {
+			patches[file.GetFilename()] = file.GetPatch()
+		}
+		// Files left out of a truncated comparison are treated as unchanged, their threads are only checked once outdated
+		if len(files) >= repository.COMPARE_COMMITS_MAX_FILES {
+			slog.Warn("comparison of pushed commits may be truncated, relying on outdated threads for the other files", "files", len(files), "before", before, "after", after)
+		}
+	}
+
+	for _, thread := range threads {
+		if thread.IsResolved || !isAppLogin(thread.AuthorLogin, appSlug) {
+			continue
+		}
+
+		if !threadLinesChanged(thread, patches) {
+			continue
+		}
+
+		resolution, usage, err := g.checkThreadResolved(ctx, llm, client, owner, repo, after, thread)
+		g.recordLLMCall(ctx, llmCall, usage)
+		if err != nil {
+			slog.Error("error checking if review thread was resolved", "error", err, "thread", thread.ID)
+			continue
+		}
+
+		if !resolution.Resolved {
+			slog.Info("review thread issue is still present", "thread", thread.ID, "path", thread.Path, "reason", resolution.Reason)
+			continue
+		}
+
+		if g.dryRun.Enabled(installationID, owner+"/"+repo) {
+			slog.Info("dry run, not resolving review thread", "thread", thread.ID, "path", thread.Path, "reason", resolution.Reason)
+			continue
+		}
+
+		err = g.repository.ReplyToReviewComment(ctx, client, owner, repo, pullNumber, thread.CommentID, fmt.Sprintf("Resolved in %s", after))
+		if err != nil {
+			slog.Error("error replying to resolved review thread", "error", err, "thread", thread.ID)
+			continue
+		}
+
+		err = g.repository.ResolveReviewThread(ctx, client, thread.ID)
+		if err != nil {
+			slog.Error("error resolving review thread", "error", err, "thread", thread.ID)
+			continue
+		}
+
+		slog.Info("review thread has been resolved", "thread", thread.ID, "path", thread.Path, "reason", resolution.Reason)
+	}
+
+	return nil
+}
+
+// checkThreadResolved fetches the current code around the thread and asks the LLM whether the issue was fixed
+func (g *GithubUsecase) checkThreadResolved(ctx context.Context, llm LLMRepository, client *github.Client, owner, repo, ref string, thread model.ReviewThread) (*model.IssueResolution, model.TokenUsage, error) {
+	content, found, err := g.repository.GetFileContent(ctx, client, owner, repo, thread.Path, ref)
+	if err != nil {
+		return nil, model.TokenUsage{}, err
+	}
+
+	updatedCode := "The file has been deleted."
+	if found {
+		updatedCode = utils.ExtractLines(content, threadLine(thread), RESOLUTION_CONTEXT_LINES)
+	}
+
+	return llm.CheckIssueResolved(ctx, thread.Body, g.redactor.RedactText(thread.DiffHunk), g.redactor.RedactText(updatedCode))
+}
+
+// threadLinesChanged reports whether the push touched the lines the thread was left on. Threads which aren't
+// outdated point at lines of the pushed head, which are on the new side of the push's patches.
+func threadLinesChanged(thread model.ReviewThread, patches map[string]string) bool {
+	if thread.IsOutdated {
+		return true
+	}
+
+	// Threads on removed lines are on the base of the pull request, which pushes to its head don't change
+	if thread.Side == model.DIFF_SIDE_LEFT || thread.Line == 0 {
+		return false
+	}
+
+	patch, ok := patches[thread.Path]
+	if !ok {
+		return false
+	}
+
+	startLine := thread.StartLine
+	if startLine == 0 {
+		startLine = thread.Line
+	}
+
+	return utils.PatchTouchesLines(patch, startLine, thread.Line, true)
+}
+
+// threadLine returns the line the thread currently points at, or where it was made once outdated
+func threadLine(thread model.ReviewThread) int {
+	if thread.Line > 0 {
+		return thread.Line
+	}
+	return thread.OriginalLine
+}
+
+// isAppLogin reports whether a login belongs to this github app, GraphQL omits the "[bot]" suffix REST uses
+func isAppLogin(login string, appSlug string) bool {
+	return login == appSlug || login == appSlug+"[bot]"
+}
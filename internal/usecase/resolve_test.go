@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+func TestThreadLinesChanged(t *testing.T) {
+	// Lines 10-12 of the old file were replaced by 40-41 of the new one, after lines were added above them
+	patches := map[string]string{"store.go": "@@ -10,3 +40,2 @@ func Close() {\n-\ta()\n-\tb()\n-\tc()\n+\td()\n+\te()"}
+
+	tests := []struct {
+		name     string
+		thread   model.ReviewThread
+		expected bool
+	}{
+		{name: "changed line", thread: model.ReviewThread{Path: "store.go", Line: 41, Side: model.DIFF_SIDE_RIGHT}, expected: true},
+		{name: "range ending on a changed line", thread: model.ReviewThread{Path: "store.go", StartLine: 35, Line: 40, Side: model.DIFF_SIDE_RIGHT}, expected: true},
+		{name: "line only the old side covers", thread: model.ReviewThread{Path: "store.go", Line: 11, Side: model.DIFF_SIDE_RIGHT}},
+		{name: "thread on the base", thread: model.ReviewThread{Path: "store.go", Line: 41, Side: model.DIFF_SIDE_LEFT}},
+		{name: "file the push didn't change", thread: model.ReviewThread{Path: "cache.go", Line: 41, Side: model.DIFF_SIDE_RIGHT}},
+		{name: "outdated thread", thread: model.ReviewThread{Path: "cache.go", OriginalLine: 3, IsOutdated: true}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changed := threadLinesChanged(test.thread, patches); changed != test.expected {
+				t.Errorf("expected changed to be %v", test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// How long a cached review is used before the chunk is sent to the LLM again
+const DEFAULT_REVIEW_CACHE_TTL = 7 * 24 * time.Hour
+
+// ReviewCache keeps the comments the LLM generated for chunks of diffs, so reopening a pull request or reviewing
+// it again doesn't pay for the same review twice. Chunks are keyed by the model, the prompt version, the rules
+// they were reviewed with and their content without line numbers. A chunk moved by a rebase is still found,
+// and its comments are moved to the lines they are on now. A nil cache caches nothing.
+type ReviewCache struct {
+	memory repository.ReviewCacheRepository
+	// Optional, looked up when a review isn't in memory
+	disk repository.ReviewCacheRepository
+	ttl  time.Duration
+}
+
+// NewReviewCache caches reviews in memory and, when disk isn't nil, on disk. Reviews older than ttl are ignored.
+func NewReviewCache(memory repository.ReviewCacheRepository, disk repository.ReviewCacheRepository, ttl time.Duration) *ReviewCache {
+	return &ReviewCache{
+		memory: memory,
+		disk:   disk,
+		ttl:    ttl,
+	}
+}
+
+// Key identifies a chunk of files reviewed by the model with the prompt context
+func (c *ReviewCache) Key(llmModel string, promptCtx model.PromptContext, files []model.PRFile) string {
+	// The rules the review follows, the description of the pull request only gives context
+	rules := sha256.New()
+	writeHashFields(rules, promptCtx.Rules, promptCtx.Language, promptCtx.LanguageRules)
+	// Lists are preceded by their length, so items can't move from one list to the other
+	writeHashFields(rules, strconv.Itoa(len(promptCtx.LanguageChecklist)))
+	writeHashFields(rules, promptCtx.LanguageChecklist...)
+	writeHashFields(rules, strconv.Itoa(len(promptCtx.RejectedPatterns)))
+	writeHashFields(rules, promptCtx.RejectedPatterns...)
+
+	chunk := sha256.New()
+	for _, file := range files {
+		if file.Patch == "" {
+			continue
+		}
+		writeHashFields(chunk, file.Filename, file.Status, utils.NormalizePatch(file.Patch))
+	}
+
+	key := sha256.New()
+	writeHashFields(key,
+		llmModel,
+		utils.PromptVersionID(promptCtx.PromptVersion, promptCtx.PromptOverrides),
+		hex.EncodeToString(rules.Sum(nil)),
+		hex.EncodeToString(chunk.Sum(nil)),
+	)
+	return hex.EncodeToString(key.Sum(nil))
+}
+
+// Get returns the cached comments on the files, moved to the current line numbers of the lines they were made on.
+// Comments on lines which aren't in the files any more are dropped.
+func (c *ReviewCache) Get(ctx context.Context, key string, files []model.PRFile) ([]model.ReviewCommentRequest, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	review, found := c.lookup(ctx, key)
+	if !found {
+		metrics.ReviewCacheLookups.WithLabelValues(metrics.CACHE_MISS).Inc()
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(review.CreatedAt) > c.ttl {
+		metrics.ReviewCacheLookups.WithLabelValues(metrics.CACHE_EXPIRED).Inc()
+		c.delete(ctx, key)
+		return nil, false
+	}
+	metrics.ReviewCacheLookups.WithLabelValues(metrics.CACHE_HIT).Inc()
+
+	lines := patchLinesByIndex(files)
+	reviews := make([]model.ReviewCommentRequest, 0, len(review.Comments))
+	for _, comment := range review.Comments {
+		line, found := lines[comment.Path][comment.PatchIndex]
+		if !found || line.Content != comment.Content {
+			slog.Info("dropping cached review comment, its line is no longer in the diff", "path", comment.Path)
+			continue
+		}
+
+		reviews = append(reviews, model.ReviewCommentRequest{
+			Body:        comment.Body,
+			Path:        comment.Path,
+			Line:        line.Line,
+			SubjectType: comment.SubjectType,
+		})
+	}
+	return reviews, true
+}
+
+// Put caches the comments the model generated for the files, on the new file lines of their [LINE:N] markers.
+// Comments on lines outside the diff can't be moved with the diff, so they aren't cached.
+func (c *ReviewCache) Put(ctx context.Context, key string, llmModel string, promptCtx model.PromptContext, files []model.PRFile, reviews []model.ReviewCommentRequest) {
+	if c == nil {
+		return
+	}
+
+	review := &model.CachedReview{
+		Model:         llmModel,
+		PromptVersion: utils.PromptVersionID(promptCtx.PromptVersion, promptCtx.PromptOverrides),
+		Comments:      []model.CachedReviewComment{},
+		CreatedAt:     time.Now(),
+	}
+
+	lines := patchLinesByLine(files)
+	for _, comment := range reviews {
+		line, found := lines[comment.Path][comment.Line]
+		if !found {
+			continue
+		}
+
+		review.Comments = append(review.Comments, model.CachedReviewComment{
+			Path:        comment.Path,
+			PatchIndex:  line.Index,
+			Content:     line.Content,
+			Body:        comment.Body,
+			SubjectType: comment.SubjectType,
+		})
+	}
+
+	if err := c.memory.Put(ctx, key, review); err != nil {
+		slog.Warn("error caching review in memory", "error", err)
+	}
+	if c.disk != nil {
+		if err := c.disk.Put(ctx, key, review); err != nil {
+			slog.Warn("error caching review on disk", "error", err)
+		}
+	}
+}
+
+// lookup finds the review in memory and then on disk, reviews found on disk are kept in memory from then on
+func (c *ReviewCache) lookup(ctx context.Context, key string) (*model.CachedReview, bool) {
+	review, found, err := c.memory.Get(ctx, key)
+	if err != nil {
+		slog.Warn("error reading review cache from memory", "error", err)
+	}
+	if found || c.disk == nil {
+		return review, found
+	}
+
+	review, found, err = c.disk.Get(ctx, key)
+	if err != nil {
+		slog.Warn("error reading review cache from disk", "error", err)
+		return nil, false
+	}
+	if found {
+		if err := c.memory.Put(ctx, key, review); err != nil {
+			slog.Warn("error caching review in memory", "error", err)
+		}
+	}
+	return review, found
+}
+
+func (c *ReviewCache) delete(ctx context.Context, key string) {
+	if err := c.memory.Delete(ctx, key); err != nil {
+		slog.Warn("error deleting expired review from memory", "error", err)
+	}
+	if c.disk != nil {
+		if err := c.disk.Delete(ctx, key); err != nil {
+			slog.Warn("error deleting expired review from disk", "error", err)
+		}
+	}
+}
+
+// reviewChunk reviews the files in one call to the LLM, unless the cache has a review of the same chunk. The
+// review context is cached on providers which support it, so the next chunks with the same context reuse it.
+// cached reports whether the comments came from the cache, which uses no tokens.
+func reviewChunk(ctx context.Context, llm LLMRepository, cache *ReviewCache, files []model.PRFile, promptCtx model.PromptContext) (reviews []model.ReviewCommentRequest, usage model.TokenUsage, cached bool, err error) {
+	formattedDiffs := formatFilesForLLM(files)
+	if formattedDiffs == "" {
+		return nil, model.TokenUsage{}, false, nil
+	}
+
+	var key string
+	if cache != nil {
+		key = cache.Key(llm.Model(), promptCtx, files)
+		if reviews, found := cache.Get(ctx, key, files); found {
+			return reviews, model.TokenUsage{}, true, nil
+		}
+	}
+
+	cacheReviewContext(ctx, llm, promptCtx)
+	reviews, usage, err = llm.GetCodeReviews(ctx, formattedDiffs, promptCtx)
+	if err != nil {
+		return nil, usage, false, err
+	}
+	cache.Put(ctx, key, llm.Model(), promptCtx, files, reviews)
+
+	return reviews, usage, false, nil
+}
+
+// patchLinesByIndex indexes the new side lines of the files by path and place in the patch
+func patchLinesByIndex(files []model.PRFile) map[string]map[int]utils.PatchLine {
+	lines := make(map[string]map[int]utils.PatchLine, len(files))
+	for _, file := range files {
+		lines[file.Filename] = map[int]utils.PatchLine{}
+		for _, line := range utils.PatchNewLines(file.Patch) {
+			lines[file.Filename][line.Index] = line
+		}
+	}
+	return lines
+}
+
+// patchLinesByLine indexes the new side lines of the files by path and line number
+func patchLinesByLine(files []model.PRFile) map[string]map[int]utils.PatchLine {
+	lines := make(map[string]map[int]utils.PatchLine, len(files))
+	for _, file := range files {
+		lines[file.Filename] = map[int]utils.PatchLine{}
+		for _, line := range utils.PatchNewLines(file.Patch) {
+			lines[file.Filename][line.Line] = line
+		}
+	}
+	return lines
+}
+
+// writeHashFields writes the fields to the hash separated so that no two lists of fields hash the same
+func writeHashFields(h hash.Hash, fields ...string) {
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s\x00", len(field), field)
+	}
+}
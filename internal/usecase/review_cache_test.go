@@ -0,0 +1,217 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+)
+
+// countingLLM counts the reviews the fake LLM is asked for
+type countingLLM struct {
+	*repository.FakeLLMRepository
+	calls int
+}
+
+func (c *countingLLM) GetCodeReviews(ctx context.Context, code string, promptCtx model.PromptContext) ([]model.ReviewCommentRequest, model.TokenUsage, error) {
+	c.calls++
+	return c.FakeLLMRepository.GetCodeReviews(ctx, code, promptCtx)
+}
+
+// contextCachingLLM fails to cache review contexts when err is set, like gemini with a short prompt
+type contextCachingLLM struct {
+	countingLLM
+	cached []model.PromptContext
+	err    error
+}
+
+func (c *contextCachingLLM) CacheReviewContext(ctx context.Context, promptCtx model.PromptContext) error {
+	c.cached = append(c.cached, promptCtx)
+	return c.err
+}
+
+func workerFile(hunkHeader string) []model.PRFile {
+	return []model.PRFile{{
+		Filename: "worker/run.go",
+		Status:   "modified",
+		Patch:    hunkHeader + "\n func run() {\n-\treturn\n+\tpanic(\"unreachable\")\n }",
+	}}
+}
+
+func TestReviewCacheReusesReviewsOfIdenticalChunks(t *testing.T) {
+	ctx := context.Background()
+	llm := &countingLLM{FakeLLMRepository: repository.NewFakeLLMRepository()}
+	cache := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), nil, time.Hour)
+	promptCtx := model.PromptContext{Rules: "Don't panic."}
+
+	first, _, cached, err := reviewChunk(ctx, llm, cache, workerFile("@@ -10,3 +10,3 @@"), promptCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached || len(first) != 1 || first[0].Line != 11 {
+		t.Fatalf("expected a comment on line 11 from the LLM, got %+v (cached %t)", first, cached)
+	}
+
+	again, usage, cached, err := reviewChunk(ctx, llm, cache, workerFile("@@ -10,3 +10,3 @@"), promptCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || llm.calls != 1 || usage.PromptTokens != 0 {
+		t.Errorf("expected the second review to come from the cache, got %d calls (cached %t)", llm.calls, cached)
+	}
+	if len(again) != 1 || again[0].Body != first[0].Body || again[0].Line != 11 {
+		t.Errorf("expected the cached comment, got %+v", again)
+	}
+
+	// Lines added above the change move it, the cached comment moves with it
+	moved, _, cached, err := reviewChunk(ctx, llm, cache, workerFile("@@ -10,3 +25,3 @@"), promptCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || len(moved) != 1 || moved[0].Line != 26 {
+		t.Errorf("expected the cached comment on line 26, got %+v (cached %t)", moved, cached)
+	}
+
+	// Other rules, prompt versions or models review the chunk again
+	for _, changed := range []model.PromptContext{
+		{Rules: "Panics are fine in workers."},
+		{Rules: "Don't panic.", PromptVersion: "v3"},
+		{Rules: "Don't panic.", RejectedPatterns: []string{"IMPORTANT: Panicking takes the whole process down"}},
+	} {
+		calls := llm.calls
+		if _, _, cached, err := reviewChunk(ctx, llm, cache, workerFile("@@ -10,3 +10,3 @@"), changed); err != nil || cached || llm.calls != calls+1 {
+			t.Errorf("expected %+v to miss the cache, got cached %t, %v", changed, cached, err)
+		}
+	}
+}
+
+func TestReviewCacheRemapsCommentsInLaterHunks(t *testing.T) {
+	ctx := context.Background()
+	llm := &countingLLM{FakeLLMRepository: repository.NewFakeLLMRepository()}
+	cache := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), nil, time.Hour)
+	files := func(firstStart, secondStart int) []model.PRFile {
+		return []model.PRFile{{
+			Filename: "worker/run.go",
+			Status:   "modified",
+			Patch: fmt.Sprintf("@@ -%d,2 +%d,3 @@ func start() {\n \tw.ready()\n+\tw.log.Info(\"started\")\n }\n", firstStart, firstStart) +
+				fmt.Sprintf("@@ -%d,3 +%d,3 @@ func run() {\n \tjob := w.next()\n-\tw.handle(job)\n+\t_ = w.handle(job)\n }", secondStart-1, secondStart),
+		}}
+	}
+
+	first, _, _, err := reviewChunk(ctx, llm, cache, files(12, 60), model.PromptContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 || first[0].Line != 61 {
+		t.Fatalf("expected a comment on line 61 of the second hunk, got %+v", first)
+	}
+
+	// Both hunks moved down, the comment follows its line rather than its place in the hunk
+	moved, _, cached, err := reviewChunk(ctx, llm, cache, files(30, 95), model.PromptContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || len(moved) != 1 || moved[0].Line != 96 || moved[0].Body != first[0].Body {
+		t.Errorf("expected the cached comment on line 96, got %+v (cached %t)", moved, cached)
+	}
+}
+
+func TestReviewCacheDropsCommentsOnChangedLines(t *testing.T) {
+	ctx := context.Background()
+	cache := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), nil, time.Hour)
+	files := workerFile("@@ -10,3 +10,3 @@")
+
+	key := cache.Key("fake", model.PromptContext{}, files)
+	cache.Put(ctx, key, "fake", model.PromptContext{}, files, []model.ReviewCommentRequest{
+		{Path: "worker/run.go", Line: 11, Body: "IMPORTANT: panic"},
+		{Path: "worker/run.go", Line: 40, Body: "NIT: outside the diff"},
+	})
+
+	reviews, found := cache.Get(ctx, key, files)
+	if !found || len(reviews) != 1 || reviews[0].Line != 11 {
+		t.Fatalf("expected only the comment on the diff to be cached, got %+v", reviews)
+	}
+
+	// A collision or a corrupted entry never posts a comment on a line it wasn't made on
+	changed := workerFile("@@ -10,3 +10,3 @@")
+	changed[0].Patch = "@@ -10,3 +10,3 @@\n func run() {\n-\treturn\n+\tos.Exit(1)\n }"
+	reviews, found = cache.Get(ctx, key, changed)
+	if !found || len(reviews) != 0 {
+		t.Errorf("expected the comment on a changed line to be dropped, got %+v", reviews)
+	}
+}
+
+func TestReviewCacheExpiresAndUsesDisk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	disk, err := repository.NewDiskReviewCacheRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := workerFile("@@ -10,3 +10,3 @@")
+
+	cache := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), disk, time.Hour)
+	key := cache.Key("fake", model.PromptContext{}, files)
+	cache.Put(ctx, key, "fake", model.PromptContext{}, files, []model.ReviewCommentRequest{{Path: "worker/run.go", Line: 11, Body: "IMPORTANT: panic"}})
+
+	// A new process only has the disk
+	restarted := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), disk, time.Hour)
+	if reviews, found := restarted.Get(ctx, key, files); !found || len(reviews) != 1 {
+		t.Fatalf("expected the review to be read from disk, got %+v", reviews)
+	}
+
+	review, _, err := disk.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review.CreatedAt = time.Now().Add(-2 * time.Hour)
+	if err := disk.Put(ctx, key, review); err != nil {
+		t.Fatal(err)
+	}
+	expired := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), disk, time.Hour)
+	if _, found := expired.Get(ctx, key, files); found {
+		t.Error("expected the expired review to be ignored")
+	}
+	if _, found, _ := disk.Get(ctx, key); found {
+		t.Error("expected the expired review to be deleted from disk")
+	}
+}
+
+func TestReviewChunkCachesReviewContext(t *testing.T) {
+	ctx := context.Background()
+	promptCtx := model.PromptContext{Rules: "Don't panic.", Language: "Go"}
+
+	for _, cacheErr := range []error{nil, errors.New("cached content is too small")} {
+		provider := &contextCachingLLM{countingLLM: countingLLM{FakeLLMRepository: repository.NewFakeLLMRepository()}, err: cacheErr}
+		// The metrics decorator passes the context on to the provider
+		llm := NewInstrumentedLLM(provider)
+
+		reviews, _, _, err := reviewChunk(ctx, llm, nil, workerFile("@@ -10,3 +10,3 @@"), promptCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(provider.cached) != 1 || provider.cached[0].Rules != promptCtx.Rules {
+			t.Errorf("expected the review context to be cached once, got %+v", provider.cached)
+		}
+		// Failing to cache only means the whole prompt is sent
+		if provider.calls != 1 || len(reviews) != 1 {
+			t.Errorf("expected the chunk to be reviewed when caching returns %v, got %d calls and %+v", cacheErr, provider.calls, reviews)
+		}
+	}
+
+	// Chunks found in the review cache don't need a context
+	provider := &contextCachingLLM{countingLLM: countingLLM{FakeLLMRepository: repository.NewFakeLLMRepository()}}
+	cache := NewReviewCache(repository.NewMemoryReviewCacheRepository(0), nil, time.Hour)
+	for range 2 {
+		if _, _, _, err := reviewChunk(ctx, provider, cache, workerFile("@@ -10,3 +10,3 @@"), promptCtx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(provider.cached) != 1 {
+		t.Errorf("expected the review context to be cached for the LLM call only, got %d", len(provider.cached))
+	}
+}
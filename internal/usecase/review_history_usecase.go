@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/repository"
+)
+
+// ReviewHistoryUsecase reads past review runs from storage
+type ReviewHistoryUsecase struct {
+	reviews  repository.ReviewRepository
+	feedback repository.FeedbackRepository
+}
+
+// Category of comments without a severity in the feedback stats
+const UNSPECIFIED_CATEGORY = "UNSPECIFIED"
+
+// Category summing up every comment of a repository in the feedback stats
+const ALL_CATEGORY = "ALL"
+
+func NewReviewHistoryUsecase(reviews repository.ReviewRepository, feedback repository.FeedbackRepository) *ReviewHistoryUsecase {
+	return &ReviewHistoryUsecase{
+		reviews:  reviews,
+		feedback: feedback,
+	}
+}
+
+// ListRuns lists review runs newest first, fullName (owner/repo) and dryRun are optional filters
+func (h *ReviewHistoryUsecase) ListRuns(ctx context.Context, fullName string, dryRun *bool, limit int) ([]model.ReviewRun, error) {
+	filter := model.ReviewRunFilter{
+		DryRun: dryRun,
+		Limit:  limit,
+	}
+	if fullName != "" {
+		filter.Owner, filter.Repo, _ = strings.Cut(fullName, "/")
+	}
+
+	return h.reviews.ListRuns(ctx, filter)
+}
+
+// GetRun returns a review run with its comments, repository.ErrNotFound when it doesn't exist
+func (h *ReviewHistoryUsecase) GetRun(ctx context.Context, id int64) (*model.ReviewRunDetail, error) {
+	run, err := h.reviews.GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := h.reviews.ListComments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ReviewRunDetail{
+		ReviewRun: *run,
+		Comments:  comments,
+	}, nil
+}
+
+// FeedbackStats returns the precision of posted comments per repository and category (severity),
+// based on reactions and replies. fullName (owner/repo) optionally limits the stats to one repository.
+func (h *ReviewHistoryUsecase) FeedbackStats(ctx context.Context, fullName string) ([]model.FeedbackStats, error) {
+	var owner, repo string
+	if fullName != "" {
+		owner, repo, _ = strings.Cut(fullName, "/")
+	}
+
+	summaries, err := h.feedback.ListFeedbackSummaries(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	type statsKey struct {
+		owner    string
+		repo     string
+		category string
+	}
+	statsByKey := map[statsKey]*model.FeedbackStats{}
+
+	add := func(summary model.CommentFeedbackSummary, category string) {
+		key := statsKey{summary.Owner, summary.Repo, category}
+		stats, ok := statsByKey[key]
+		if !ok {
+			stats = &model.FeedbackStats{Owner: summary.Owner, Repo: summary.Repo, Category: category}
+			statsByKey[key] = stats
+		}
+
+		stats.Comments++
+		if summary.IsHelpful() {
+			stats.Helpful++
+		}
+		if summary.IsRejected() {
+			stats.Rejected++
+		}
+	}
+
+	for _, summary := range summaries {
+		category := summary.Severity
+		if category == "" {
+			category = UNSPECIFIED_CATEGORY
+		}
+		add(summary, category)
+		add(summary, ALL_CATEGORY)
+	}
+
+	result := make([]model.FeedbackStats, 0, len(statsByKey))
+	for _, stats := range statsByKey {
+		if stats.Helpful+stats.Rejected > 0 {
+			precision := float64(stats.Helpful) / float64(stats.Helpful+stats.Rejected)
+			stats.Precision = &precision
+		}
+		result = append(result, *stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Owner != result[j].Owner {
+			return result[i].Owner < result[j].Owner
+		}
+		if result[i].Repo != result[j].Repo {
+			return result[i].Repo < result[j].Repo
+		}
+		return result[i].Category < result[j].Category
+	})
+
+	return result, nil
+}
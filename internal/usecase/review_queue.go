@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+const DEFAULT_REVIEW_WORKERS = 4
+const DEFAULT_REVIEW_QUEUE_SIZE = 50
+const DEFAULT_REVIEW_TIMEOUT = 15 * time.Minute
+
+// The queue is reported as saturated by the readiness probe once it is this full
+const REVIEW_QUEUE_SATURATION = 0.9
+
+// How many finished jobs are remembered for the admin API
+const MAX_FINISHED_JOBS = 100
+
+// ErrQueueFull is returned when there is no room left in the review queue
+var ErrQueueFull = errors.New("review queue is full")
+
+// ErrJobNotFound is returned for jobs the queue doesn't know, or has forgotten
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when cancelling a job which already finished
+var ErrJobFinished = errors.New("job already finished")
+
+// ReviewJob is a unit of background work, like reviewing a pull request
+type ReviewJob func(ctx context.Context) error
+
+type queuedJob struct {
+	// job is guarded by the queue's mutex
+	job       *model.Job
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled bool
+	run       ReviewJob
+}
+
+// ReviewQueue runs webhook work in the background on a fixed number of workers,
+// so a burst of pull requests can't start an unbounded number of reviews
+type ReviewQueue struct {
+	jobs    chan *queuedJob
+	workers int
+	timeout time.Duration
+	busy    atomic.Int64
+
+	mu       sync.Mutex
+	nextID   int64
+	tracked  map[int64]*queuedJob
+	finished []int64
+}
+
+func NewReviewQueue(workers int, size int, timeout time.Duration) *ReviewQueue {
+	if workers <= 0 {
+		workers = DEFAULT_REVIEW_WORKERS
+	}
+	if size <= 0 {
+		size = DEFAULT_REVIEW_QUEUE_SIZE
+	}
+	if timeout <= 0 {
+		timeout = DEFAULT_REVIEW_TIMEOUT
+	}
+
+	return &ReviewQueue{
+		jobs:    make(chan *queuedJob, size),
+		workers: workers,
+		timeout: timeout,
+		tracked: map[int64]*queuedJob{},
+	}
+}
+
+// Start starts the workers, they stop once ctx is done
+func (q *ReviewQueue) Start(ctx context.Context) {
+	for range q.workers {
+		go q.work(ctx)
+	}
+}
+
+// Submit queues the job without blocking. ctx should already be detached from the request,
+// it carries the trace and values of the webhook but each job gets its own timeout.
+func (q *ReviewQueue) Submit(ctx context.Context, name string, run ReviewJob) (model.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	queued := &queuedJob{
+		job: &model.Job{
+			ID:         q.nextID + 1,
+			Name:       name,
+			Status:     model.JOB_STATUS_QUEUED,
+			EnqueuedAt: time.Now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		run:    run,
+	}
+
+	select {
+	case q.jobs <- queued:
+	default:
+		cancel()
+		return model.Job{}, ErrQueueFull
+	}
+
+	q.nextID++
+	q.tracked[queued.job.ID] = queued
+	metrics.ReviewQueueDepth.Inc()
+
+	return *queued.job, nil
+}
+
+// Jobs returns the queued, running and recently finished jobs, newest first
+func (q *ReviewQueue) Jobs() []model.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]model.Job, 0, len(q.tracked))
+	for _, queued := range q.tracked {
+		jobs = append(jobs, *queued.job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID > jobs[j].ID
+	})
+
+	return jobs
+}
+
+// Cancel stops a running job, or drops a queued one before it starts
+func (q *ReviewQueue) Cancel(id int64) (model.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queued, ok := q.tracked[id]
+	if !ok {
+		return model.Job{}, ErrJobNotFound
+	}
+	if queued.job.FinishedAt != nil {
+		return *queued.job, ErrJobFinished
+	}
+
+	queued.cancelled = true
+	queued.cancel()
+
+	return *queued.job, nil
+}
+
+// Pending returns the number of jobs waiting for a worker
+func (q *ReviewQueue) Pending() int {
+	return len(q.jobs)
+}
+
+// Capacity returns how many jobs can wait for a worker
+func (q *ReviewQueue) Capacity() int {
+	return cap(q.jobs)
+}
+
+// Busy returns the number of workers running a job
+func (q *ReviewQueue) Busy() int {
+	return int(q.busy.Load())
+}
+
+// Workers returns the number of workers
+func (q *ReviewQueue) Workers() int {
+	return q.workers
+}
+
+// Saturated reports whether the queue is close to refusing jobs
+func (q *ReviewQueue) Saturated() bool {
+	return float64(q.Pending()) >= float64(q.Capacity())*REVIEW_QUEUE_SATURATION
+}
+
+func (q *ReviewQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case queued := <-q.jobs:
+			q.run(queued)
+		}
+	}
+}
+
+func (q *ReviewQueue) run(queued *queuedJob) {
+	defer metrics.ReviewQueueDepth.Dec()
+	defer queued.cancel()
+
+	// Jobs cancelled while queued are dropped
+	if queued.ctx.Err() != nil {
+		q.finish(queued, queued.ctx.Err())
+		return
+	}
+
+	q.mu.Lock()
+	startedAt := time.Now()
+	queued.job.Status = model.JOB_STATUS_RUNNING
+	queued.job.StartedAt = &startedAt
+	q.mu.Unlock()
+
+	q.busy.Add(1)
+	defer q.busy.Add(-1)
+
+	ctx, cancel := context.WithTimeout(queued.ctx, q.timeout)
+	defer cancel()
+
+	err := queued.run(ctx)
+	if err != nil {
+		slog.Error("error running background job", "job", queued.job.Name, "id", queued.job.ID, "error", err)
+	}
+	q.finish(queued, err)
+}
+
+// finish records the outcome of the job and forgets the oldest finished jobs
+func (q *ReviewQueue) finish(queued *queuedJob, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	finishedAt := time.Now()
+	queued.job.FinishedAt = &finishedAt
+	switch {
+	case queued.cancelled:
+		queued.job.Status = model.JOB_STATUS_CANCELLED
+	case err != nil:
+		queued.job.Status = model.JOB_STATUS_FAILED
+		queued.job.Error = err.Error()
+	default:
+		queued.job.Status = model.JOB_STATUS_SUCCEEDED
+	}
+
+	q.finished = append(q.finished, queued.job.ID)
+	for len(q.finished) > MAX_FINISHED_JOBS {
+		delete(q.tracked, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
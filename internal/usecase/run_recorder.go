@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/metrics"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// Storage failures are logged but never fail a review, the history is for debugging and analytics.
+// The same hooks update the prometheus metrics.
+
+// startRun stores a new review run
+func (g *GithubUsecase) startRun(ctx context.Context, run *model.ReviewRun) {
+	metrics.ReviewsStarted.Inc()
+
+	if err := g.reviews.CreateRun(ctx, run); err != nil {
+		slog.Error("error storing review run", "error", err, "owner", run.Owner, "repo", run.Repo, "pullNumber", run.PullNumber)
+	}
+}
+
+// finishRun records the outcome of a review run
+func (g *GithubUsecase) finishRun(ctx context.Context, run *model.ReviewRun, reviewErr error) {
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.DurationMs = finishedAt.Sub(run.StartedAt).Milliseconds()
+
+	run.Status = model.REVIEW_STATUS_SUCCEEDED
+	if reviewErr != nil {
+		run.Status = model.REVIEW_STATUS_FAILED
+		run.Error = reviewErr.Error()
+	}
+
+	metrics.ReviewsFinished.WithLabelValues(run.Status).Inc()
+	metrics.ReviewDuration.WithLabelValues(run.Status).Observe(finishedAt.Sub(run.StartedAt).Seconds())
+
+	if run.ID == 0 {
+		return
+	}
+
+	// The review context may already be cancelled or timed out, the outcome should still be stored
+	if err := g.reviews.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+		slog.Error("error storing review run outcome", "error", err, "run", run.ID)
+	}
+}
+
+// recordComment stores a comment generated during the run, githubCommentID is 0 when it wasn't posted
+func (g *GithubUsecase) recordComment(ctx context.Context, run *model.ReviewRun, review model.ReviewCommentRequest, status string, githubCommentID int64) {
+	metrics.ReviewComments.WithLabelValues(status).Inc()
+
+	if run.ID == 0 {
+		return
+	}
+
+	comment := &model.StoredComment{
+		RunID:           run.ID,
+		Path:            review.Path,
+		Line:            review.Line,
+		Body:            review.Body,
+		Severity:        utils.ParseSeverity(review.Body),
+		Status:          status,
+		GithubCommentID: githubCommentID,
+		CreatedAt:       time.Now(),
+	}
+
+	if err := g.reviews.AddComment(ctx, comment); err != nil {
+		slog.Error("error storing review comment", "error", err, "run", run.ID)
+	}
+}
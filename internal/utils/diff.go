@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// hunkHeaderPattern matches unified diff hunk headers such as "@@ -12,7 +12,9 @@ func main() {"
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Marks the lines of the diffs sent to the LLM with their line number in the new file, the number is what
+// comments are posted on so the LLM never has to count lines
+const LINE_MARKER_PREFIX = "LINE:"
+
+// DiffHunk describes the line ranges a single hunk of a unified diff covers
+type DiffHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+}
+
+// ParseHunks returns the hunks of a unified diff patch
+func ParseHunks(patch string) []DiffHunk {
+	var hunks []DiffHunk
+
+	for _, line := range strings.Split(patch, "\n") {
+		matches := hunkHeaderPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		hunks = append(hunks, DiffHunk{
+			OldStart: atoiOrDefault(matches[1], 0),
+			OldLines: atoiOrDefault(matches[2], 1),
+			NewStart: atoiOrDefault(matches[3], 0),
+			NewLines: atoiOrDefault(matches[4], 1),
+		})
+	}
+
+	return hunks
+}
+
+// PatchTouchesLines reports whether any hunk of the patch overlaps the line range, which is in the new
+// version of the file, or in the old one when newSide is false
+func PatchTouchesLines(patch string, startLine int, endLine int, newSide bool) bool {
+	for _, hunk := range ParseHunks(patch) {
+		start, lines := hunk.NewStart, hunk.NewLines
+		if !newSide {
+			start, lines = hunk.OldStart, hunk.OldLines
+		}
+		if rangesOverlap(start, start+lines-1, startLine, endLine) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PatchLine is a line on the new side of a patch, added or unchanged
+type PatchLine struct {
+	// Index of the line in the patch, hunk headers and deleted lines included
+	Index int
+	// Line number in the new version of the file
+	Line    int
+	Content string
+}
+
+// PatchNewLines returns the added and unchanged lines of a patch with their line numbers in the new file
+func PatchNewLines(patch string) []PatchLine {
+	var lines []PatchLine
+
+	newLine := 0
+	for index, line := range strings.Split(patch, "\n") {
+		if matches := hunkHeaderPattern.FindStringSubmatch(line); matches != nil {
+			newLine = atoiOrDefault(matches[3], 0)
+			continue
+		}
+		if newLine == 0 || !(strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ")) {
+			continue
+		}
+
+		lines = append(lines, PatchLine{Index: index, Line: newLine, Content: line[1:]})
+		newLine++
+	}
+
+	return lines
+}
+
+// NormalizePatch removes the line numbers from the hunk headers of a patch, so the same change made at
+// another place of a file normalizes to the same text
+func NormalizePatch(patch string) string {
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		if matches := hunkHeaderPattern.FindStringSubmatch(line); matches != nil {
+			lines[i] = "@@" + strings.TrimPrefix(line, matches[0])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ExtractLines returns the lines around center prefixed with their line numbers
+func ExtractLines(content string, center int, context int) string {
+	lines := strings.Split(content, "\n")
+
+	start := max(center-context, 1)
+	end := min(center+context, len(lines))
+
+	var builder strings.Builder
+	for i := start; i <= end; i++ {
+		builder.WriteString(strconv.Itoa(i))
+		builder.WriteString(": ")
+		builder.WriteString(lines[i-1])
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// ParseUnifiedDiff splits the output of git diff (or any unified diff) into per file patches,
+// shaped like the files the github API returns for a pull request
+func ParseUnifiedDiff(diff string) []model.PRFile {
+	var files []model.PRFile
+	var current *model.PRFile
+	var patch []string
+
+	// Lines left in the current hunk, used to tell hunk content apart from the next file header
+	oldRemaining, newRemaining := 0, 0
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Patch = strings.Join(patch, "\n")
+		current.Changes = current.Additions + current.Deletions
+		files = append(files, *current)
+		current = nil
+		patch = nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		inHunk := oldRemaining > 0 || newRemaining > 0
+
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.Additions++
+				newRemaining--
+			case strings.HasPrefix(line, "-"):
+				current.Deletions++
+				oldRemaining--
+			case strings.HasPrefix(line, "\\"):
+				// "\ No newline at end of file" doesn't count as a line
+			default:
+				oldRemaining--
+				newRemaining--
+			}
+			patch = append(patch, line)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &model.PRFile{Status: "modified"}
+			// Use the b/ path until the ---/+++ lines tell us better, they are missing for binary files
+			if index := strings.LastIndex(line, " b/"); index != -1 {
+				current.Filename = line[index+3:]
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// Plain unified diffs have no "diff --git" header, the file header starts here instead
+			if current == nil || len(patch) > 0 {
+				flush()
+				current = &model.PRFile{Status: "modified"}
+			}
+			oldPath := strings.TrimSpace(strings.TrimPrefix(line, "--- "))
+			if oldPath == "/dev/null" {
+				current.Status = "added"
+			} else if current.Filename == "" {
+				current.Filename = stripDiffPrefix(oldPath)
+			}
+		case strings.HasPrefix(line, "+++ ") && current != nil:
+			newPath := strings.TrimSpace(strings.TrimPrefix(line, "+++ "))
+			if newPath == "/dev/null" {
+				current.Status = "removed"
+			} else {
+				current.Filename = stripDiffPrefix(newPath)
+			}
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "removed"
+		case strings.HasPrefix(line, "rename to "):
+			current.Status = "renamed"
+			current.Filename = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "@@"):
+			if matches := hunkHeaderPattern.FindStringSubmatch(line); matches != nil {
+				oldRemaining = atoiOrDefault(matches[2], 1)
+				newRemaining = atoiOrDefault(matches[4], 1)
+			}
+			patch = append(patch, line)
+		}
+	}
+	flush()
+
+	return files
+}
+
+// stripDiffPrefix removes the a/ or b/ prefix git puts in front of paths
+func stripDiffPrefix(path string) string {
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+func rangesOverlap(startA, endA, startB, endB int) bool {
+	return startA <= endB && startB <= endA
+}
+
+func atoiOrDefault(value string, fallback int) int {
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return number
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// Files making up an evaluation fixture, each fixture is a directory holding both
+const (
+	EVAL_DIFF_FILE     = "diff.patch"
+	EVAL_EXPECTED_FILE = "expected.json"
+)
+
+// LoadEvalFixtures reads every fixture directory in dir, sorted by name so runs are comparable
+func LoadEvalFixtures(dir string) ([]model.EvalFixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var fixtures []model.EvalFixture
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		fixture, err := loadEvalFixture(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fixture.Name = entry.Name()
+		fixtures = append(fixtures, fixture)
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s, each fixture is a directory with %s and %s", dir, EVAL_DIFF_FILE, EVAL_EXPECTED_FILE)
+	}
+
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].Name < fixtures[j].Name })
+	return fixtures, nil
+}
+
+func loadEvalFixture(dir string) (model.EvalFixture, error) {
+	var fixture model.EvalFixture
+
+	diff, err := os.ReadFile(filepath.Join(dir, EVAL_DIFF_FILE))
+	if err != nil {
+		return fixture, fmt.Errorf("failed to read fixture diff: %w", err)
+	}
+
+	expected, err := os.ReadFile(filepath.Join(dir, EVAL_EXPECTED_FILE))
+	if err != nil {
+		return fixture, fmt.Errorf("failed to read expected findings: %w", err)
+	}
+	if err := json.Unmarshal(expected, &fixture); err != nil {
+		return fixture, fmt.Errorf("invalid expected findings in %s: %w", dir, err)
+	}
+	for _, finding := range fixture.Expected {
+		if finding.Path == "" || finding.Line <= 0 {
+			return fixture, fmt.Errorf("invalid expected finding in %s, path and line are required", dir)
+		}
+	}
+
+	fixture.Diff = string(diff)
+	return fixture, nil
+}
@@ -1,18 +1,23 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// ReadRepositoryRuleFile reads a file from docs/repository_rules directory
-// and converts it to plain text. Supports .md and .json files.
+// RepositoryRulesDir is the directory rule files are read from, the CLI points it at the reviewed checkout
+var RepositoryRulesDir = filepath.Join("docs", "repository_rules")
+
+// Rule files are converted to plain text by their extension
+var RULE_FILE_EXTENSIONS = []string{".md", ".json", ".yml", ".yaml"}
+
+// ReadRepositoryRuleFile reads a file from the RepositoryRulesDir directory
+// and converts it to plain text, without its front matter. Supports .md, .json and .yml files.
 func ReadRepositoryRuleFile(filename string) (string, error) {
 	// Construct the full path
-	fullPath := filepath.Join("docs", "repository_rules", filename)
+	fullPath := filepath.Join(RepositoryRulesDir, filename)
 
 	// Check if file exists
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
@@ -25,6 +30,15 @@ func ReadRepositoryRuleFile(filename string) (string, error) {
 		return "", fmt.Errorf("failed to read file %s: %w", fullPath, err)
 	}
 
+	rule, err := parseRepositoryRule(filepath.ToSlash(filename), content)
+	if err != nil {
+		return "", err
+	}
+	return rule.Content, nil
+}
+
+// ruleFileToText converts the content of a rule file to plain text by the extension of its name
+func ruleFileToText(filename string, content []byte) (string, error) {
 	// Get file extension
 	ext := strings.ToLower(filepath.Ext(filename))
 
@@ -33,53 +47,12 @@ func ReadRepositoryRuleFile(filename string) (string, error) {
 		// For markdown files, return content as-is (already plain text)
 		return string(content), nil
 	case ".json":
-		// For JSON files, convert to formatted plain text
+		// For JSON files, convert to Markdown in the order of the file
 		return formatJSONToText(content)
+	case ".yml", ".yaml":
+		// YAML files are converted the same way as JSON files
+		return formatYAMLToText(content)
 	default:
-		return "", fmt.Errorf("unsupported file type: %s. Only .md and .json files are supported", ext)
-	}
-}
-
-// formatJSONToText converts JSON content to a readable plain text format
-func formatJSONToText(jsonContent []byte) (string, error) {
-	var data interface{}
-
-	// Parse JSON
-	if err := json.Unmarshal(jsonContent, &data); err != nil {
-		return "", fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	// Convert to formatted text
-	var result strings.Builder
-	formatValue(&result, data, 0)
-
-	return result.String(), nil
-}
-
-// formatValue recursively formats JSON values into readable text
-func formatValue(builder *strings.Builder, value interface{}, indent int) {
-	indentStr := strings.Repeat("  ", indent)
-
-	switch v := value.(type) {
-	case map[string]interface{}:
-		for key, val := range v {
-			builder.WriteString(fmt.Sprintf("%s%s:\n", indentStr, key))
-			formatValue(builder, val, indent+1)
-		}
-	case []interface{}:
-		for i, val := range v {
-			builder.WriteString(fmt.Sprintf("%s[%d]:\n", indentStr, i))
-			formatValue(builder, val, indent+1)
-		}
-	case string:
-		builder.WriteString(fmt.Sprintf("%s%s\n", indentStr, v))
-	case float64:
-		builder.WriteString(fmt.Sprintf("%s%.2f\n", indentStr, v))
-	case bool:
-		builder.WriteString(fmt.Sprintf("%s%t\n", indentStr, v))
-	case nil:
-		builder.WriteString(fmt.Sprintf("%s<nil>\n", indentStr))
-	default:
-		builder.WriteString(fmt.Sprintf("%s%v\n", indentStr, v))
+		return "", fmt.Errorf("unsupported file type: %s. Only %s files are supported", ext, strings.Join(RULE_FILE_EXTENSIONS, ", "))
 	}
 }
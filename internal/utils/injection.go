@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Untrusted content is wrapped in tags starting with this prefix, followed by a random suffix
+// so the content can't close the tag and continue as instructions
+const UNTRUSTED_TAG_PREFIX = "untrusted_"
+
+// Replaces text which reads like instructions to the LLM
+const NEUTRALIZED_TEXT = "[instruction-like text removed]"
+
+// instructionPatterns match phrases trying to override the prompt, found in code comments, strings or docs
+var instructionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override|skip)\s+(?:all\s+|any\s+|every\s+|of\s+)*(?:the\s+|your\s+|these\s+|those\s+)?(?:previous|prior|above|earlier|preceding|foregoing|system|original|existing)\s+(?:instructions?|prompts?|rules|guidelines|directions|messages|context|requirements)\b`),
+	regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget)\s+(?:everything|anything|all)\s+(?:above|before|previously|you\s+(?:were|have\s+been)\s+told)\b`),
+	regexp.MustCompile(`(?i)\b(?:new|updated|real|actual|additional)\s+(?:system\s+)?instructions?\s*:`),
+	regexp.MustCompile(`(?i)\byou\s+are\s+(?:now|no\s+longer)\b`),
+	regexp.MustCompile(`(?i)\b(?:from\s+now\s+on|instead)\s*,?\s+you\s+(?:must|will|should)\b`),
+	regexp.MustCompile(`(?i)\b(?:note|message|instructions?)\s+(?:to|for)\s+(?:the\s+)?(?:ai|llm|assistant|reviewer|language\s+model|code\s+reviewer)\b`),
+	regexp.MustCompile(`(?i)\b(?:approve|lgtm)\s+this\s+(?:pull\s+request|pr|change)s?\s+(?:without|and\s+do\s+not)\b`),
+	regexp.MustCompile(`(?i)\b(?:do\s+not|don'?t|never)\s+(?:report|flag|mention|comment\s+on)\s+(?:any\s+)?(?:issues?|problems?|vulnerabilit(?:y|ies)|bugs?|this)\b`),
+	regexp.MustCompile(`(?i)\b(?:reveal|print|repeat|output|show)\s+(?:me\s+)?(?:your|the)\s+(?:system\s+)?(?:prompt|instructions)\b`),
+}
+
+// chatTokenPattern matches the special tokens chat models use to separate roles
+var chatTokenPattern = regexp.MustCompile(`(?i)<\|[a-z_]+\|>|\[/?INST\]|<</?SYS>>|<\|?(?:begin|end)_of_(?:text|turn)\|?>`)
+
+// promptTagPattern matches tags mimicking the structure of the prompts, including fake untrusted content tags
+var promptTagPattern = regexp.MustCompile(`(?i)</?\s*(?:system|system_role|assistant|user|human|developer|requirements|review_instructions|instructions|response_quality_requirements|previously_rejected_comments|review_comment|original_code|updated_code|repository|pull_request|language|untrusted_content_policy|untrusted_[a-z0-9_]*)\s*>`)
+
+// invisibleCharacters hide text from human reviewers but not from the LLM: zero width characters and
+// bidirectional overrides (trojan source)
+var invisibleCharacters = strings.NewReplacer(
+	"\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "", "\ufeff", "",
+	"\u202a", "", "\u202b", "", "\u202c", "", "\u202d", "", "\u202e", "",
+	"\u2066", "", "\u2067", "", "\u2068", "", "\u2069", "",
+)
+
+// NewUntrustedTag returns a tag name for wrapping untrusted content of the given kind, such as diff.
+// The random suffix can't be guessed by whoever wrote the content.
+func NewUntrustedTag(kind string) string {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		// crypto/rand doesn't fail on supported platforms, a fixed tag still separates the content
+		return UNTRUSTED_TAG_PREFIX + kind
+	}
+	return UNTRUSTED_TAG_PREFIX + kind + "_" + hex.EncodeToString(suffix)
+}
+
+// WrapUntrusted neutralizes the content and wraps it in the tag
+func WrapUntrusted(tag string, content string) string {
+	return "<" + tag + ">\n" + NeutralizeInstructions(content) + "\n</" + tag + ">"
+}
+
+// NeutralizeInstructions removes what untrusted content could use to pass itself off as instructions:
+// phrases addressing the LLM, chat template tokens, tags copying the prompt structure and invisible characters.
+// Code is otherwise left untouched so it can still be reviewed.
+func NeutralizeInstructions(content string) string {
+	content = invisibleCharacters.Replace(content)
+	content = chatTokenPattern.ReplaceAllString(content, NEUTRALIZED_TEXT)
+	content = promptTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(tag)
+	})
+	for _, pattern := range instructionPatterns {
+		content = pattern.ReplaceAllString(content, NEUTRALIZED_TEXT)
+	}
+
+	return content
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Profiles of the major languages, repositories add to them in REPOSITORY_LANGUAGE_PROFILES_FILE
+//
+//go:embed language_profiles.yml
+var bundledLanguageProfiles string
+
+// Read from the base of the pull request like the prompt overrides, with the same layout as the bundled profiles
+const REPOSITORY_LANGUAGE_PROFILES_FILE = ".github/ai-reviewer/profiles.yml"
+
+// Hunk header of a hunk starting on the first line of the new file, the only place a shebang can be
+var firstLineHunkPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+1(?:,\d+)? @@`)
+
+// LanguageProfiles detects the language of changed files and holds the profile of every language
+type LanguageProfiles struct {
+	profiles []model.LanguageProfile
+	// Index into profiles by exact file name, lower case extension and shebang interpreter
+	byFilename    map[string]int
+	byExtension   map[string]int
+	byInterpreter map[string]int
+}
+
+// LanguageGroup is the files of a pull request written in one language, Profile is nil for files of unknown languages
+type LanguageGroup struct {
+	Profile *model.LanguageProfile
+	Files   []model.PRFile
+}
+
+type languageProfilesFile struct {
+	Profiles []model.LanguageProfile `yaml:"profiles"`
+}
+
+// The bundled profiles are parsed once, LoadLanguageProfiles copies them before adding the repository's
+var parseBundledLanguageProfiles = sync.OnceValues(func() ([]model.LanguageProfile, error) {
+	return parseLanguageProfiles(bundledLanguageProfiles)
+})
+
+// LoadLanguageProfiles returns the bundled profiles extended with the profiles.yml of a repository, which may be empty.
+// A repository profile with the name of a bundled one adds to it, its detection rules taking precedence over the
+// bundled ones, any other name adds a language.
+func LoadLanguageProfiles(repositoryProfiles string) (*LanguageProfiles, error) {
+	bundled, err := parseBundledLanguageProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundled language profiles: %w", err)
+	}
+
+	profiles := &LanguageProfiles{
+		byFilename:    map[string]int{},
+		byExtension:   map[string]int{},
+		byInterpreter: map[string]int{},
+	}
+	for _, profile := range bundled {
+		profiles.add(profile)
+	}
+
+	if strings.TrimSpace(repositoryProfiles) == "" {
+		return profiles, nil
+	}
+	extra, err := parseLanguageProfiles(repositoryProfiles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", REPOSITORY_LANGUAGE_PROFILES_FILE, err)
+	}
+	for _, profile := range extra {
+		profiles.add(profile)
+	}
+
+	return profiles, nil
+}
+
+// Names lists the languages with a profile
+func (l *LanguageProfiles) Names() []string {
+	names := make([]string, 0, len(l.profiles))
+	for _, profile := range l.profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// Detect returns the profile of the language the file is written in, by its name, its extension and then
+// the shebang on its first line, or nil when the language is unknown
+func (l *LanguageProfiles) Detect(file model.PRFile) *model.LanguageProfile {
+	filename := path.Base(file.Filename)
+	if i, ok := l.byFilename[filename]; ok {
+		return &l.profiles[i]
+	}
+	if i, ok := l.byExtension[strings.ToLower(path.Ext(filename))]; ok {
+		return &l.profiles[i]
+	}
+
+	interpreter := shebangInterpreter(file.Patch)
+	if interpreter == "" {
+		return nil
+	}
+	if i, ok := l.byInterpreter[interpreter]; ok {
+		return &l.profiles[i]
+	}
+	// Versioned interpreters such as python3.12 are detected by their unversioned name
+	if i, ok := l.byInterpreter[strings.TrimRight(interpreter, "0123456789.")]; ok {
+		return &l.profiles[i]
+	}
+	return nil
+}
+
+// Group splits the files by language, in the order each language first appears so reviews are deterministic.
+// Files of unknown languages are grouped together, with a nil profile.
+func (l *LanguageProfiles) Group(files []model.PRFile) []LanguageGroup {
+	var groups []LanguageGroup
+	index := map[*model.LanguageProfile]int{}
+
+	for _, file := range files {
+		profile := l.Detect(file)
+		i, ok := index[profile]
+		if !ok {
+			i = len(groups)
+			index[profile] = i
+			groups = append(groups, LanguageGroup{Profile: profile})
+		}
+		groups[i].Files = append(groups[i].Files, file)
+	}
+
+	return groups
+}
+
+// add appends the profile, or merges it into the profile of the same name, and indexes its detection rules
+func (l *LanguageProfiles) add(profile model.LanguageProfile) {
+	i := slices.IndexFunc(l.profiles, func(existing model.LanguageProfile) bool {
+		return strings.EqualFold(existing.Name, profile.Name)
+	})
+	if i < 0 {
+		i = len(l.profiles)
+		l.profiles = append(l.profiles, model.LanguageProfile{Name: profile.Name})
+	}
+
+	merged := &l.profiles[i]
+	merged.Extensions = append(merged.Extensions, profile.Extensions...)
+	merged.Filenames = append(merged.Filenames, profile.Filenames...)
+	merged.Interpreters = append(merged.Interpreters, profile.Interpreters...)
+	merged.Checklist = append(merged.Checklist, profile.Checklist...)
+	if profile.Rules != "" {
+		merged.Rules = strings.TrimSpace(merged.Rules + "\n\n" + profile.Rules)
+	}
+
+	for _, extension := range profile.Extensions {
+		l.byExtension[extension] = i
+	}
+	for _, filename := range profile.Filenames {
+		l.byFilename[filename] = i
+	}
+	for _, interpreter := range profile.Interpreters {
+		l.byInterpreter[interpreter] = i
+	}
+}
+
+// parseLanguageProfiles reads and checks a profiles file, extensions are lower cased
+func parseLanguageProfiles(content string) ([]model.LanguageProfile, error) {
+	var file languageProfilesFile
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	for i := range file.Profiles {
+		profile := &file.Profiles[i]
+		profile.Name = strings.TrimSpace(profile.Name)
+		if profile.Name == "" {
+			return nil, fmt.Errorf("profile %d has no name", i+1)
+		}
+		for j, extension := range profile.Extensions {
+			if !strings.HasPrefix(extension, ".") || strings.ContainsAny(extension, "/ ") {
+				return nil, fmt.Errorf("profile %s: extension %q must start with a dot, like .go", profile.Name, extension)
+			}
+			profile.Extensions[j] = strings.ToLower(extension)
+		}
+		for _, filename := range profile.Filenames {
+			if filename == "" || strings.Contains(filename, "/") {
+				return nil, fmt.Errorf("profile %s: filename %q must be a file name without a directory", profile.Name, filename)
+			}
+		}
+		profile.Rules = strings.TrimSpace(profile.Rules)
+	}
+
+	return file.Profiles, nil
+}
+
+// shebangInterpreter returns the interpreter named by a shebang added on the first line of the file,
+// like python3 for #!/usr/bin/env python3, or an empty string
+func shebangInterpreter(patch string) string {
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		if !firstLineHunkPattern.MatchString(line) {
+			continue
+		}
+		// The first line of the new file is the first line of the hunk which isn't a deletion
+		for _, line := range lines[i+1:] {
+			if strings.HasPrefix(line, "-") || strings.HasPrefix(line, `\`) {
+				continue
+			}
+			if len(line) == 0 || !strings.HasPrefix(line[1:], "#!") {
+				return ""
+			}
+
+			fields := strings.Fields(line[3:])
+			if len(fields) == 0 {
+				return ""
+			}
+			interpreter := path.Base(fields[0])
+			if interpreter == "env" {
+				// #!/usr/bin/env -S deno run names the interpreter after the flags of env
+				interpreter = ""
+				for _, field := range fields[1:] {
+					if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+						interpreter = path.Base(field)
+						break
+					}
+				}
+			}
+			return interpreter
+		}
+		return ""
+	}
+	return ""
+}
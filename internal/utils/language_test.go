@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	profiles, err := LoadLanguageProfiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     model.PRFile
+		expected string
+	}{
+		{name: "extension", file: model.PRFile{Filename: "internal/server.go"}, expected: "Go"},
+		{name: "upper case extension", file: model.PRFile{Filename: "src/App.TSX"}, expected: "TypeScript"},
+		{name: "file name", file: model.PRFile{Filename: "deploy/Dockerfile"}, expected: "Dockerfile"},
+		{name: "shebang", file: model.PRFile{Filename: "bin/release", Patch: "@@ -0,0 +1,3 @@\n+#!/bin/bash\n+set -e\n+make"}, expected: "Shell"},
+		{name: "env shebang", file: model.PRFile{Filename: "scripts/migrate", Patch: "@@ -1,2 +1,2 @@\n-#!/usr/bin/env python\n+#!/usr/bin/env -S python3.12 -u\n import sys"}, expected: "Python"},
+		{name: "unchanged shebang", file: model.PRFile{Filename: "bin/serve", Patch: "@@ -1,3 +1,4 @@\n #!/usr/bin/env node\n+const port = 8080\n"}, expected: "JavaScript"},
+		{name: "shebang past the first line", file: model.PRFile{Filename: "bin/tool", Patch: "@@ -4,2 +4,3 @@\n+#!/bin/sh\n echo"}, expected: ""},
+		{name: "unknown", file: model.PRFile{Filename: "docs/README.md"}, expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var name string
+			if profile := profiles.Detect(test.file); profile != nil {
+				name = profile.Name
+			}
+			if name != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestGroupFilesByLanguage(t *testing.T) {
+	profiles, err := LoadLanguageProfiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := profiles.Group([]model.PRFile{
+		{Filename: "web/app.ts"},
+		{Filename: "main.go"},
+		{Filename: "README.md"},
+		{Filename: "web/api.ts"},
+		{Filename: "CHANGELOG.md"},
+		{Filename: "server.go"},
+	})
+
+	var got [][]string
+	for _, group := range groups {
+		files := []string{}
+		if group.Profile != nil {
+			files = append(files, group.Profile.Name+":")
+		}
+		for _, file := range group.Files {
+			files = append(files, file.Filename)
+		}
+		got = append(got, files)
+	}
+	expected := [][]string{
+		{"TypeScript:", "web/app.ts", "web/api.ts"},
+		{"Go:", "main.go", "server.go"},
+		{"README.md", "CHANGELOG.md"},
+	}
+	if !slices.EqualFunc(got, expected, slices.Equal) {
+		t.Errorf("expected groups %v, got %v", expected, got)
+	}
+}
+
+func TestRepositoryLanguageProfiles(t *testing.T) {
+	profiles, err := LoadLanguageProfiles(`
+profiles:
+  - name: go
+    filenames: [go.mod]
+    checklist:
+      - Use the internal/errors package to wrap errors.
+    rules: Handlers never log and return the same error.
+  - name: Protobuf
+    extensions: [.PROTO]
+    checklist:
+      - Field numbers are never reused.
+  - name: Internal scripts
+    extensions: [.sh]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golang := profiles.Detect(model.PRFile{Filename: "go.mod"})
+	if golang == nil || golang.Name != "Go" {
+		t.Fatalf("expected go.mod to be detected as Go, got %v", golang)
+	}
+	if last := golang.Checklist[len(golang.Checklist)-1]; last != "Use the internal/errors package to wrap errors." || len(golang.Checklist) < 2 {
+		t.Errorf("expected the repository checklist to be added to the bundled one, got %v", golang.Checklist)
+	}
+	if golang.Rules != "Handlers never log and return the same error." {
+		t.Errorf("unexpected rules %q", golang.Rules)
+	}
+
+	if proto := profiles.Detect(model.PRFile{Filename: "api/users.proto"}); proto == nil || proto.Name != "Protobuf" {
+		t.Errorf("expected a new language to be added, got %v", proto)
+	}
+	// The repository's detection rules take precedence over the bundled ones
+	if script := profiles.Detect(model.PRFile{Filename: "deploy.sh"}); script == nil || script.Name != "Internal scripts" {
+		t.Errorf("expected .sh files to use the repository profile, got %v", script)
+	}
+	if shell := profiles.Detect(model.PRFile{Filename: "deploy", Patch: "@@ -0,0 +1 @@\n+#!/bin/bash"}); shell == nil || shell.Name != "Shell" {
+		t.Errorf("expected the bundled shebang rules to still apply, got %v", shell)
+	}
+
+	// The bundled profiles are shared, a repository's additions never leak into another repository's
+	bundled, err := LoadLanguageProfiles("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(bundled.Detect(model.PRFile{Filename: "main.go"}).Checklist, "Use the internal/errors package to wrap errors.") {
+		t.Error("expected the bundled profile to be unchanged")
+	}
+}
+
+func TestInvalidRepositoryLanguageProfiles(t *testing.T) {
+	tests := map[string]string{
+		"invalid yaml":              "profiles: [",
+		"unknown field":             "profiles:\n  - name: Go\n    extension: [.go]\n",
+		"missing name":              "profiles:\n  - extensions: [.go]\n",
+		"extension missing dot":     "profiles:\n  - name: Go\n    extensions: [go]\n",
+		"filename with a directory": "profiles:\n  - name: Make\n    filenames: [build/Makefile]\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadLanguageProfiles(content); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
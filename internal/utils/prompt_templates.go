@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Every version of the prompts is a directory of templates, a version is never changed once reviews used it
+//
+//go:embed prompts
+var embeddedPrompts embed.FS
+
+// DEFAULT_PROMPT_VERSION is used unless the server or the CLI asks for another version
+const DEFAULT_PROMPT_VERSION = "v4"
+
+// Names of the prompt templates, each version has a <name>.tmpl file for every one of them
+const (
+	REVIEW_PROMPT     = "review"
+	SUMMARY_PROMPT    = "summary"
+	RESOLUTION_PROMPT = "resolution"
+)
+
+var PROMPT_NAMES = []string{REVIEW_PROMPT, SUMMARY_PROMPT, RESOLUTION_PROMPT}
+
+const PROMPT_TEMPLATE_EXT = ".tmpl"
+
+// Repositories override templates with <name>.tmpl files in this directory of their default branch,
+// and set the variables templates read as .Config in its config.yml
+const (
+	REPOSITORY_PROMPTS_DIR = ".github/ai-reviewer/prompts"
+	PROMPT_CONFIG_FILE     = "config.yml"
+)
+
+// Functions templates can use besides the text/template builtins
+var promptFuncs = template.FuncMap{
+	"oneLine": func(text string) string {
+		return strings.Join(strings.Fields(text), " ")
+	},
+}
+
+// ReviewPromptData is what the review template is rendered with. Fields written by users are already wrapped
+// in the untrusted tags UntrustedContentPolicy lists.
+type ReviewPromptData struct {
+	Rules                  string
+	Language               string
+	LanguageChecklist      []string
+	LanguageRules          string
+	Repository             string
+	RepositoryDescription  string
+	PullRequestTitle       string
+	PullRequestBody        string
+	RejectedPatterns       []string
+	Config                 map[string]string
+	UntrustedContentPolicy string
+}
+
+// SummaryPromptData is what the summary template is rendered with
+type SummaryPromptData struct {
+	Repository             string
+	RepositoryDescription  string
+	PullRequestTitle       string
+	PullRequestBody        string
+	Config                 map[string]string
+	UntrustedContentPolicy string
+}
+
+// ResolutionPromptData is what the resolution check template is rendered with
+type ResolutionPromptData struct {
+	Comment                string
+	OriginalCode           string
+	UpdatedCode            string
+	Config                 map[string]string
+	UntrustedContentPolicy string
+}
+
+// PromptTemplates are the parsed templates of a prompt version, with the overrides of a repository applied
+type PromptTemplates struct {
+	// Version identifies the templates in the review history, see PromptVersionID
+	Version   string
+	Config    map[string]string
+	templates map[string]*template.Template
+}
+
+// Parsed templates by version ID, the ID changes with the content of the overrides
+var promptTemplatesCache sync.Map
+
+// PromptVersions lists the embedded prompt versions
+func PromptVersions() []string {
+	entries, err := fs.ReadDir(embeddedPrompts, "prompts")
+	if err != nil {
+		return nil
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// PromptVersionID is the version recorded with reviews: the embedded version, followed by a hash of the
+// overrides when the repository has any, like v3+repo.1a2b3c4d
+func PromptVersionID(version string, overrides model.PromptOverrides) string {
+	if version == "" {
+		version = DEFAULT_PROMPT_VERSION
+	}
+	if overrides.Empty() {
+		return version
+	}
+	return version + "+repo." + hashPromptOverrides(overrides)[:8]
+}
+
+// LoadPromptTemplates parses the templates of the embedded version, replacing the ones the repository overrides
+func LoadPromptTemplates(version string, overrides model.PromptOverrides) (*PromptTemplates, error) {
+	if version == "" {
+		version = DEFAULT_PROMPT_VERSION
+	}
+	id := PromptVersionID(version, overrides)
+	if cached, ok := promptTemplatesCache.Load(id); ok {
+		return cached.(*PromptTemplates), nil
+	}
+
+	if _, err := fs.Stat(embeddedPrompts, path.Join("prompts", version)); err != nil {
+		return nil, fmt.Errorf("unknown prompt version %q, expected one of %s", version, strings.Join(PromptVersions(), ", "))
+	}
+
+	templates := &PromptTemplates{
+		Version:   id,
+		Config:    overrides.Config,
+		templates: make(map[string]*template.Template, len(PROMPT_NAMES)),
+	}
+	for name := range overrides.Templates {
+		if !slices.Contains(PROMPT_NAMES, name) {
+			return nil, fmt.Errorf("unknown prompt template %q, expected one of %s", name, strings.Join(PROMPT_NAMES, ", "))
+		}
+	}
+	for _, name := range PROMPT_NAMES {
+		source, overridden := overrides.Templates[name]
+		if !overridden {
+			content, err := embeddedPrompts.ReadFile(path.Join("prompts", version, name+PROMPT_TEMPLATE_EXT))
+			if err != nil {
+				return nil, fmt.Errorf("prompt version %s has no %s template: %w", version, name, err)
+			}
+			source = string(content)
+		}
+
+		tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=zero").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s prompt template: %w", name, err)
+		}
+		templates.templates[name] = tmpl
+	}
+
+	promptTemplatesCache.Store(id, templates)
+	return templates, nil
+}
+
+// Render executes the named template with the data
+func (p *PromptTemplates) Render(name string, data any) (string, error) {
+	tmpl, ok := p.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt template %q", name)
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("failed to render the %s prompt: %w", name, err)
+	}
+	return strings.TrimSpace(builder.String()) + "\n", nil
+}
+
+// ReadPromptOverrides reads the overrides of a repository through read, which returns found=false for missing
+// files. The templates are checked against the default version so mistakes are reported when they are read.
+func ReadPromptOverrides(read func(name string) (content string, found bool, err error)) (model.PromptOverrides, error) {
+	var overrides model.PromptOverrides
+
+	for _, name := range PROMPT_NAMES {
+		content, found, err := read(name + PROMPT_TEMPLATE_EXT)
+		if err != nil {
+			return model.PromptOverrides{}, fmt.Errorf("failed to read the %s prompt template: %w", name, err)
+		}
+		if !found {
+			continue
+		}
+		if overrides.Templates == nil {
+			overrides.Templates = map[string]string{}
+		}
+		overrides.Templates[name] = content
+	}
+
+	content, found, err := read(PROMPT_CONFIG_FILE)
+	if err != nil {
+		return model.PromptOverrides{}, fmt.Errorf("failed to read %s: %w", PROMPT_CONFIG_FILE, err)
+	}
+	if found {
+		overrides.Config, err = parsePromptConfig(content)
+		if err != nil {
+			return model.PromptOverrides{}, err
+		}
+	}
+
+	if _, err := LoadPromptTemplates(DEFAULT_PROMPT_VERSION, overrides); err != nil {
+		return model.PromptOverrides{}, err
+	}
+	return overrides, nil
+}
+
+// parsePromptConfig reads the flat map of variables in config.yml, values are rendered as text
+func parsePromptConfig(content string) (map[string]string, error) {
+	var document map[string]any
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", PROMPT_CONFIG_FILE, err)
+	}
+
+	config := make(map[string]string, len(document))
+	for key, value := range document {
+		switch value.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("invalid %s: %s must be a single value, not a list or map", PROMPT_CONFIG_FILE, key)
+		case nil:
+			config[key] = ""
+		default:
+			config[key] = fmt.Sprint(value)
+		}
+	}
+	return config, nil
+}
+
+// hashPromptOverrides hashes the overrides in a stable order
+func hashPromptOverrides(overrides model.PromptOverrides) string {
+	hash := sha256.New()
+	write := func(kind string, values map[string]string) {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", kind, key, values[key])
+		}
+	}
+	write("template", overrides.Templates)
+	write("config", overrides.Config)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
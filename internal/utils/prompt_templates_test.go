@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+var sectionTagPattern = regexp.MustCompile(`<(/?)([a-z_]+)>`)
+
+// Every section the prompts open must be closed, in order
+func assertBalancedSections(t *testing.T, name string, prompt string) {
+	t.Helper()
+
+	var open []string
+	for _, match := range sectionTagPattern.FindAllStringSubmatch(prompt, -1) {
+		closing, tag := match[1] == "/", match[2]
+		if !closing {
+			open = append(open, tag)
+			continue
+		}
+		if len(open) == 0 || open[len(open)-1] != tag {
+			t.Errorf("%s prompt closes </%s> while %v are open:\n%s", name, tag, open, prompt)
+			return
+		}
+		open = open[:len(open)-1]
+	}
+	if len(open) > 0 {
+		t.Errorf("%s prompt leaves %v open:\n%s", name, open, prompt)
+	}
+}
+
+func TestEmbeddedPromptVersionsRender(t *testing.T) {
+	versions := PromptVersions()
+	if len(versions) == 0 {
+		t.Fatal("no prompt versions are embedded")
+	}
+
+	for _, version := range versions {
+		promptCtx := model.PromptContext{
+			PromptVersion:         version,
+			Repository:            "octo-org/service",
+			RepositoryDescription: "Billing service",
+			PullRequestTitle:      "Add invoices",
+			PullRequestBody:       "Adds the invoices endpoint",
+			Language:              "Go",
+			RejectedPatterns:      []string{"NIT: rename\nthis variable"},
+		}
+
+		review, err := GenerateCodeReviewPrompt("Use the logger.", promptCtx, NewUntrustedTag("diff"))
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		summary, err := GenerateSummaryPrompt(promptCtx, NewUntrustedTag("diff"))
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		// Untrusted tags end with random hex, which the section pattern doesn't match
+		assertBalancedSections(t, version+" review", review)
+		assertBalancedSections(t, version+" summary", summary)
+
+		for _, expected := range []string{"Use the logger.", "octo-org/service", "Add invoices", "Go", "NIT: rename this variable"} {
+			if !strings.Contains(review, expected) {
+				t.Errorf("%s review prompt is missing %q", version, expected)
+			}
+		}
+	}
+
+	// Language profiles were added in v4
+	review, err := GenerateCodeReviewPrompt("rules", model.PromptContext{
+		Language:          "Go",
+		LanguageChecklist: []string{"Every returned error\nis checked."},
+		LanguageRules:     "Use the internal logger.",
+	}, NewUntrustedTag("diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"- Every returned error is checked.", "rules for Go code:\nUse the internal logger."} {
+		if !strings.Contains(review, expected) {
+			t.Errorf("review prompt is missing %q:\n%s", expected, review)
+		}
+	}
+
+	resolution, err := GenerateResolutionCheckPrompt("BLOCKING: unchecked error", "+_ = f()", "+if err := f(); err != nil {")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBalancedSections(t, "resolution", resolution)
+}
+
+func TestPromptOverrides(t *testing.T) {
+	files := map[string]string{
+		"review.tmpl": "Review {{.Repository}} for {{.Config.team}}, focus on {{.Config.focus}}.\n{{.Rules}}",
+		"config.yml":  "team: payments\nfocus: money handling\nretries: 3\n",
+	}
+	read := func(name string) (string, bool, error) {
+		content, found := files[name]
+		return content, found, nil
+	}
+
+	overrides, err := ReadPromptOverrides(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overrides.Config["retries"] != "3" {
+		t.Errorf("expected numbers to be read as text, got %q", overrides.Config["retries"])
+	}
+
+	prompt, err := GenerateCodeReviewPrompt("rules", model.PromptContext{Repository: "octo-org/service", PromptOverrides: overrides}, NewUntrustedTag("diff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt != "Review octo-org/service for payments, focus on money handling.\nrules\n" {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+
+	// The summary isn't overridden, but still sees the variables
+	summary, err := GenerateSummaryPrompt(model.PromptContext{PromptOverrides: overrides}, NewUntrustedTag("diff"))
+	if err != nil || !strings.Contains(summary, "<system_role>") {
+		t.Errorf("expected the bundled summary prompt, got %q, %v", summary, err)
+	}
+
+	id := PromptVersionID("", overrides)
+	if !strings.HasPrefix(id, DEFAULT_PROMPT_VERSION+"+repo.") {
+		t.Errorf("expected the default version with a hash of the overrides, got %s", id)
+	}
+	if again := PromptVersionID(DEFAULT_PROMPT_VERSION, overrides); again != id {
+		t.Errorf("expected the same ID for the same overrides, got %s and %s", id, again)
+	}
+	files["config.yml"] = "team: platform\n"
+	changed, err := ReadPromptOverrides(read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PromptVersionID("", changed) == id {
+		t.Error("expected the ID to change with the overrides")
+	}
+	if PromptVersionID("", model.PromptOverrides{}) != DEFAULT_PROMPT_VERSION {
+		t.Error("expected the plain version without overrides")
+	}
+}
+
+func TestInvalidPromptOverrides(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "template syntax", files: map[string]string{"review.tmpl": "{{.Rules"}},
+		{name: "nested config", files: map[string]string{"config.yml": "focus:\n  - security\n"}},
+		{name: "invalid yaml", files: map[string]string{"config.yml": "focus: [security"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadPromptOverrides(func(name string) (string, bool, error) {
+				content, found := test.files[name]
+				return content, found, nil
+			})
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := LoadPromptTemplates("v0", model.PromptOverrides{}); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,328 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// Name of the rules loaded from a patterns file
+const CUSTOM_SECRET_RULE = "custom"
+
+// SecretRule detects one kind of secret. Only the "secret" group of the pattern is masked when it has one,
+// otherwise the whole match is.
+type SecretRule struct {
+	Name string
+	// Description names the secret in the comment posted where it was committed
+	Description string
+	Pattern     *regexp.Regexp
+	// MinEntropy skips matches with fewer bits per character, such as example values, 0 keeps every match
+	MinEntropy float64
+	// IgnoredKeys skips matches whose "key" group matches, like identifiers naming a secret rather than holding one
+	IgnoredKeys *regexp.Regexp
+}
+
+// DEFAULT_SECRET_RULES detect the credentials most often committed by accident
+var DEFAULT_SECRET_RULES = []SecretRule{
+	{
+		Name:        "private_key",
+		Description: "private key",
+		Pattern:     regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----(?P<secret>.+?)(?:-----END|$)`),
+	},
+	{
+		Name:        "aws_access_key_id",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Name:        "aws_secret_access_key",
+		Description: "AWS secret access key",
+		Pattern:     regexp.MustCompile(`(?i)aws.{0,20}(?:secret|private).{0,20}?[:=]\s*["']?(?P<secret>[A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`),
+		MinEntropy:  3.5,
+	},
+	{
+		Name:        "github_token",
+		Description: "GitHub token",
+		Pattern:     regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	},
+	{
+		Name:        "google_api_key",
+		Description: "Google API key",
+		Pattern:     regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),
+	},
+	{
+		Name:        "slack_token",
+		Description: "Slack token",
+		Pattern:     regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	},
+	{
+		Name:        "jwt",
+		Description: "JSON Web Token",
+		Pattern:     regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	},
+	{
+		// Passwords are chosen by people, so they are masked however guessable they look
+		Name:        "connection_string",
+		Description: "password in a connection string",
+		Pattern:     regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s:/@"']+:(?P<secret>[^\s@"'/]{3,})@`),
+	},
+	{
+		Name:        "password",
+		Description: "hardcoded password",
+		Pattern:     regexp.MustCompile(`(?i)(?P<key>[A-Za-z0-9_-]*(?:password|passwd|pwd)[A-Za-z0-9_-]*)["']?\s*[:=]+\s*["'](?P<secret>[^"'\s]{4,})["']`),
+		IgnoredKeys: secretIdentifierPattern,
+	},
+	{
+		Name:        "generic_secret",
+		Description: "hardcoded secret",
+		Pattern:     regexp.MustCompile(`(?i)(?P<key>[A-Za-z0-9_-]*(?:secret|token|api[_-]?key|access[_-]?key|auth[_-]?key)[A-Za-z0-9_-]*)["']?\s*[:=]+\s*["'](?P<secret>[^"'\s]{8,})["']`),
+		MinEntropy:  3,
+		IgnoredKeys: secretIdentifierPattern,
+	},
+}
+
+// Keys which name, locate or describe a secret, like secretName or tokenURL, their values aren't secrets
+var secretIdentifierPattern = regexp.MustCompile(`(?i)(?:name|names|id|ids|url|uri|endpoint|path|file|dir|type|kind|field|header|prefix|suffix|length|len|count|env|var|ref|arn|version|provider|store)$`)
+
+// Values which only stand in for a secret, like those in examples and templates
+var secretPlaceholders = []string{"password", "passwd", "secret", "changeme", "example", "placeholder", "redacted", "your_password", "your-password"}
+
+var privateKeyBeginPattern = regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)
+var privateKeyEndPattern = regexp.MustCompile(`-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)
+
+// Redactor masks secrets in code before it is sent to the LLM. A nil Redactor leaves code untouched.
+type Redactor struct {
+	rules []SecretRule
+}
+
+// NewRedactor detects the default secrets and those matching the extra rules
+func NewRedactor(extra []SecretRule) *Redactor {
+	rules := make([]SecretRule, 0, len(DEFAULT_SECRET_RULES)+len(extra))
+	rules = append(rules, DEFAULT_SECRET_RULES...)
+	rules = append(rules, extra...)
+
+	return &Redactor{rules: rules}
+}
+
+// LoadSecretRules reads one regular expression per line, blank lines and lines starting with # are skipped.
+// A group named secret limits what is masked, like (?P<secret>...).
+func LoadSecretRules(path string) ([]SecretRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secret patterns: %w", err)
+	}
+	defer file.Close()
+
+	var rules []SecretRule
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern on line %d of %s: %w", lineNumber, path, err)
+		}
+		rules = append(rules, SecretRule{
+			Name:        CUSTOM_SECRET_RULE,
+			Description: "value matching a configured secret pattern",
+			Pattern:     pattern,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secret patterns: %w", err)
+	}
+
+	return rules, nil
+}
+
+// RedactFiles masks the secrets in the patches of the files, and returns where secrets were added.
+// Lines which remove or only surround a secret are masked too, but only added lines are reported.
+func (r *Redactor) RedactFiles(files []model.PRFile) ([]model.PRFile, []model.SecretFinding) {
+	if r == nil {
+		return files, nil
+	}
+
+	redacted := make([]model.PRFile, 0, len(files))
+	var findings []model.SecretFinding
+	for _, file := range files {
+		patch, fileFindings := r.redactPatch(file.Filename, file.Patch)
+		file.Patch = patch
+		redacted = append(redacted, file)
+		findings = append(findings, fileFindings...)
+	}
+
+	return redacted, findings
+}
+
+// RedactText masks the secrets in any text, such as code sent along with a previous review comment
+func (r *Redactor) RedactText(text string) string {
+	if r == nil {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	inPrivateKey := false
+	for i, line := range lines {
+		lines[i], _, inPrivateKey = r.redactLine(line, inPrivateKey)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (r *Redactor) redactPatch(path string, patch string) (string, []model.SecretFinding) {
+	var findings []model.SecretFinding
+
+	lines := strings.Split(patch, "\n")
+	newLine := 0
+	inPrivateKey := false
+	for i, line := range lines {
+		if matches := hunkHeaderPattern.FindStringSubmatch(line); matches != nil {
+			newLine = atoiOrDefault(matches[3], 0)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		prefix, content := line[:1], line[1:]
+		redactedContent, rule, stillInPrivateKey := r.redactLine(content, inPrivateKey)
+		lines[i] = prefix + redactedContent
+
+		// Only report the first line of a private key
+		if rule != nil && prefix == "+" && !inPrivateKey {
+			findings = append(findings, model.SecretFinding{
+				Path:        path,
+				Line:        newLine,
+				Rule:        rule.Name,
+				Description: rule.Description,
+			})
+		}
+		inPrivateKey = stillInPrivateKey
+
+		if prefix == "+" || prefix == " " {
+			newLine++
+		}
+	}
+
+	return strings.Join(lines, "\n"), findings
+}
+
+// redactLine masks the secrets in a single line and returns the first rule which matched. inPrivateKey is
+// true between the BEGIN and END lines of a private key, where whole lines are masked.
+func (r *Redactor) redactLine(line string, inPrivateKey bool) (string, *SecretRule, bool) {
+	if inPrivateKey {
+		privateKeyRule := &DEFAULT_SECRET_RULES[0]
+		end := privateKeyEndPattern.FindStringIndex(line)
+		switch {
+		case end == nil:
+			return mask(privateKeyRule.Name), privateKeyRule, true
+		case end[0] == 0:
+			return line, privateKeyRule, false
+		default:
+			return mask(privateKeyRule.Name) + line[end[0]:], privateKeyRule, false
+		}
+	}
+
+	type span struct {
+		start, end int
+		rule       *SecretRule
+	}
+	var spans []span
+	for i := range r.rules {
+		rule := &r.rules[i]
+		secretGroup := rule.Pattern.SubexpIndex("secret")
+
+		for _, match := range rule.Pattern.FindAllStringSubmatchIndex(line, -1) {
+			start, end := match[0], match[1]
+			if secretGroup > 0 && match[2*secretGroup] >= 0 {
+				start, end = match[2*secretGroup], match[2*secretGroup+1]
+			}
+			value := line[start:end]
+			if isSecretPlaceholder(value) || shannonEntropy(value) < rule.MinEntropy {
+				continue
+			}
+			if keyGroup := rule.Pattern.SubexpIndex("key"); rule.IgnoredKeys != nil && keyGroup > 0 && match[2*keyGroup] >= 0 &&
+				rule.IgnoredKeys.MatchString(line[match[2*keyGroup]:match[2*keyGroup+1]]) {
+				continue
+			}
+			spans = append(spans, span{start: start, end: end, rule: rule})
+		}
+	}
+
+	// The key continues on the next lines unless it ends on this one
+	beginsPrivateKey := privateKeyBeginPattern.MatchString(line)
+	startsPrivateKey := beginsPrivateKey && !privateKeyEndPattern.MatchString(line)
+
+	var firstRule *SecretRule
+	switch {
+	case beginsPrivateKey:
+		firstRule = &DEFAULT_SECRET_RULES[0]
+	case len(spans) > 0:
+		firstRule = spans[0].rule
+	default:
+		return line, nil, false
+	}
+
+	// Mask from the end so earlier offsets stay valid, skipping matches overlapping one already masked
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	maskedFrom := len(line) + 1
+	for _, s := range spans {
+		if s.end > maskedFrom {
+			continue
+		}
+		line = line[:s.start] + mask(s.rule.Name) + line[s.end:]
+		maskedFrom = s.start
+	}
+
+	return line, firstRule, startsPrivateKey
+}
+
+func mask(ruleName string) string {
+	return "[REDACTED:" + ruleName + "]"
+}
+
+func isSecretPlaceholder(value string) bool {
+	value = strings.ToLower(strings.Trim(value, `"'`))
+	if value == "" || strings.ContainsAny(value[:1], "${<%*") {
+		return true
+	}
+	if strings.Count(value, value[:1]) == len(value) {
+		return true
+	}
+	for _, placeholder := range secretPlaceholders {
+		if value == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the bits of entropy per character of the value
+func shannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	total := 0
+	for _, char := range value {
+		counts[char]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		probability := float64(count) / float64(total)
+		entropy -= probability * math.Log2(probability)
+	}
+	return entropy
+}
@@ -0,0 +1,196 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+// EvalFormats lists the formats evaluation runs and comparisons can be rendered in
+var EvalFormats = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_MARKDOWN}
+
+// RenderEval writes the scores of an evaluation run, the JSON format can be read back to compare runs
+func RenderEval(w io.Writer, format string, run model.EvalRun) error {
+	switch format {
+	case FORMAT_TEXT, "":
+		return renderEvalTable(w, run, false)
+	case FORMAT_MARKDOWN:
+		return renderEvalTable(w, run, true)
+	case FORMAT_JSON:
+		return renderIndentedJSON(w, run)
+	default:
+		return fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(EvalFormats, ", "))
+	}
+}
+
+// RenderEvalComparison writes how the metrics moved between two evaluation runs
+func RenderEvalComparison(w io.Writer, format string, comparison model.EvalComparison) error {
+	switch format {
+	case FORMAT_TEXT, "":
+		return renderComparisonTable(w, comparison, false)
+	case FORMAT_MARKDOWN:
+		return renderComparisonTable(w, comparison, true)
+	case FORMAT_JSON:
+		return renderIndentedJSON(w, comparison)
+	default:
+		return fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(EvalFormats, ", "))
+	}
+}
+
+func renderEvalTable(w io.Writer, run model.EvalRun, markdown bool) error {
+	var builder strings.Builder
+	score := run.Score
+
+	if markdown {
+		builder.WriteString("# Review evaluation\n\n")
+	}
+	builder.WriteString(fmt.Sprintf("%s, %d fixture(s), line tolerance %d, took %s\n\n", describeEvalConfig(run.Config), score.Fixtures, run.Config.LineTolerance, run.Duration))
+
+	table := newTable(&builder, markdown)
+	table.row("Metric", "Value")
+	table.row("precision", formatRatio(score.Precision))
+	table.row("recall", formatRatio(score.Recall))
+	table.row("f1", formatRatio(score.F1))
+	table.row("line accuracy", formatRatio(score.LineAccuracy))
+	table.row("mean line distance", fmt.Sprintf("%.2f", score.MeanLineDistance))
+	table.row("comments", fmt.Sprintf("%d (%.2f per fixture)", score.Comments, score.CommentsPerFixture))
+	table.row("expected findings", fmt.Sprintf("%d", score.Expected))
+	table.row("true positives", fmt.Sprintf("%d", score.TruePositives))
+	table.row("false positives", fmt.Sprintf("%d", score.FalsePositives))
+	table.row("false negatives", fmt.Sprintf("%d", score.FalseNegatives))
+	table.row("failed fixtures", fmt.Sprintf("%d", score.Failed))
+	for _, severity := range severityOrder {
+		if count := score.CommentsBySeverity[severity]; count > 0 {
+			table.row(severity+" comments", fmt.Sprintf("%d", count))
+		}
+	}
+	if err := table.flush(); err != nil {
+		return err
+	}
+
+	builder.WriteString("\n")
+	table = newTable(&builder, markdown)
+	table.row("Fixture", "Expected", "Comments", "Matched", "Precision", "Recall", "Error")
+	for _, fixture := range run.Fixtures {
+		s := fixture.Score
+		table.row(fixture.Name, fmt.Sprintf("%d", s.Expected), fmt.Sprintf("%d", s.Comments), fmt.Sprintf("%d", s.TruePositives), formatRatio(s.Precision), formatRatio(s.Recall), fixture.Error)
+	}
+	if err := table.flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func renderComparisonTable(w io.Writer, comparison model.EvalComparison, markdown bool) error {
+	var builder strings.Builder
+
+	if markdown {
+		builder.WriteString("# Review evaluation comparison\n\n")
+	}
+	builder.WriteString(fmt.Sprintf("base: %s\ncandidate: %s\n\n", describeEvalConfig(comparison.Base), describeEvalConfig(comparison.Candidate)))
+
+	table := newTable(&builder, markdown)
+	table.row("Metric", "Base", "Candidate", "Delta", "")
+	for _, metric := range comparison.Metrics {
+		table.row(metric.Name, formatMetric(metric.Base), formatMetric(metric.Candidate), fmt.Sprintf("%+.3f", metric.Delta), verdict(metric))
+	}
+	if err := table.flush(); err != nil {
+		return err
+	}
+
+	if len(comparison.Fixtures) > 0 {
+		builder.WriteString("\n")
+		table = newTable(&builder, markdown)
+		table.row("Fixture", "Matched", "False positives")
+		for _, fixture := range comparison.Fixtures {
+			table.row(fixture.Name,
+				fmt.Sprintf("%d -> %d", fixture.BaseTruePositives, fixture.CandidateTruePositives),
+				fmt.Sprintf("%d -> %d", fixture.BaseFalsePositives, fixture.CandidateFalsePositives))
+		}
+		if err := table.flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func describeEvalConfig(config model.EvalConfig) string {
+	description := fmt.Sprintf("%s/%s prompt %s", config.Provider, config.Model, config.PromptVersion)
+	if config.Label != "" {
+		description = config.Label + " (" + description + ")"
+	}
+	return description
+}
+
+// verdict says whether the change of the metric is an improvement
+func verdict(metric model.EvalMetricDelta) string {
+	switch {
+	case metric.Delta == 0:
+		return ""
+	case (metric.Delta > 0) == metric.HigherIsBetter:
+		return "better"
+	default:
+		return "worse"
+	}
+}
+
+func formatRatio(value float64) string {
+	return fmt.Sprintf("%.3f", value)
+}
+
+// formatMetric prints counts without decimals
+func formatMetric(value float64) string {
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return formatRatio(value)
+}
+
+func renderIndentedJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+	return nil
+}
+
+// table writes aligned columns, or a markdown table
+type table struct {
+	builder  *strings.Builder
+	writer   *tabwriter.Writer
+	markdown bool
+	rows     int
+}
+
+func newTable(builder *strings.Builder, markdown bool) *table {
+	return &table{
+		builder:  builder,
+		writer:   tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0),
+		markdown: markdown,
+	}
+}
+
+func (t *table) row(columns ...string) {
+	if t.markdown {
+		t.builder.WriteString("| " + strings.Join(columns, " | ") + " |\n")
+		if t.rows == 0 {
+			t.builder.WriteString(strings.Repeat("|---", len(columns)) + "|\n")
+		}
+	} else {
+		fmt.Fprintln(t.writer, strings.Join(columns, "\t"))
+	}
+	t.rows++
+}
+
+func (t *table) flush() error {
+	return t.writer.Flush()
+}
@@ -0,0 +1,57 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Bump when the JSON report changes in a way that breaks consumers
+const JSON_SCHEMA_VERSION = "1"
+
+type jsonTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type jsonSummary struct {
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+type jsonReport struct {
+	SchemaVersion string      `json:"schema_version"`
+	Tool          jsonTool    `json:"tool"`
+	Repository    string      `json:"repository,omitempty"`
+	PullNumber    int         `json:"pull_number,omitempty"`
+	CommitID      string      `json:"commit_id,omitempty"`
+	Summary       jsonSummary `json:"summary"`
+	Findings      []Finding   `json:"findings"`
+}
+
+// RenderJSON writes the findings as a versioned JSON document
+func RenderJSON(w io.Writer, findings []Finding, metadata Metadata) error {
+	report := jsonReport{
+		SchemaVersion: JSON_SCHEMA_VERSION,
+		Tool: jsonTool{
+			Name:    TOOL_NAME,
+			Version: metadata.ToolVersion,
+		},
+		Repository: metadata.Repository,
+		PullNumber: metadata.PullNumber,
+		CommitID:   metadata.CommitID,
+		Summary: jsonSummary{
+			Total:      len(findings),
+			BySeverity: countBySeverity(findings),
+		},
+		Findings: findings,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+// severityOrder is the order severities are listed in the summary table
+var severityOrder = []string{utils.SEVERITY_BLOCKING, utils.SEVERITY_IMPORTANT, utils.SEVERITY_NIT, utils.SEVERITY_QUESTION, "UNSPECIFIED"}
+
+// RenderMarkdown writes the findings as a human readable report, grouped by file
+func RenderMarkdown(w io.Writer, findings []Finding, metadata Metadata) error {
+	var builder strings.Builder
+
+	builder.WriteString("# AI Code Review\n\n")
+
+	if metadata.Repository != "" {
+		builder.WriteString(fmt.Sprintf("- **Repository:** %s\n", metadata.Repository))
+	}
+	if metadata.PullNumber != 0 {
+		builder.WriteString(fmt.Sprintf("- **Pull request:** #%d\n", metadata.PullNumber))
+	}
+	if metadata.CommitID != "" {
+		builder.WriteString(fmt.Sprintf("- **Commit:** `%s`\n", metadata.CommitID))
+	}
+	if metadata.Repository != "" || metadata.PullNumber != 0 || metadata.CommitID != "" {
+		builder.WriteString("\n")
+	}
+
+	if len(findings) == 0 {
+		builder.WriteString("No review comments, looks good.\n")
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}
+
+	counts := countBySeverity(findings)
+	builder.WriteString("| Severity | Count |\n|---|---|\n")
+	for _, severity := range severityOrder {
+		if counts[severity] == 0 {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("| %s | %d |\n", severity, counts[severity]))
+	}
+	builder.WriteString(fmt.Sprintf("| **Total** | **%d** |\n", len(findings)))
+
+	currentPath := ""
+	for _, finding := range findings {
+		if finding.Path != currentPath {
+			currentPath = finding.Path
+			builder.WriteString(fmt.Sprintf("\n## `%s`\n", currentPath))
+		}
+
+		builder.WriteString(fmt.Sprintf("\n### Line %d · %s\n\n", finding.Line, severityOrUnspecified(finding.Severity)))
+		builder.WriteString(finding.Body)
+		builder.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
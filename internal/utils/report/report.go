@@ -0,0 +1,143 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+const (
+	FORMAT_TEXT     = "text"
+	FORMAT_JSON     = "json"
+	FORMAT_SARIF    = "sarif"
+	FORMAT_MARKDOWN = "markdown"
+)
+
+const TOOL_NAME = "ai-pr-reviewer"
+const TOOL_INFORMATION_URI = "https://github.com/RakibulBh/AI-pr-reviewer"
+
+// Formats lists every supported output format
+var Formats = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_SARIF, FORMAT_MARKDOWN}
+
+// Metadata describes what was reviewed, every field is optional
+type Metadata struct {
+	Repository  string `json:"repository,omitempty"`
+	PullNumber  int    `json:"pull_number,omitempty"`
+	CommitID    string `json:"commit_id,omitempty"`
+	ToolVersion string `json:"tool_version,omitempty"`
+}
+
+// Finding is a review comment prepared for rendering
+type Finding struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Body     string `json:"body"`
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case FORMAT_JSON:
+		return "application/json"
+	case FORMAT_SARIF:
+		return "application/sarif+json"
+	case FORMAT_MARKDOWN:
+		return "text/markdown; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Render writes the review comments in the requested format
+func Render(w io.Writer, format string, comments []model.ReviewCommentRequest, metadata Metadata) error {
+	findings := ToFindings(comments)
+
+	switch format {
+	case FORMAT_TEXT, "":
+		return RenderText(w, findings)
+	case FORMAT_JSON:
+		return RenderJSON(w, findings, metadata)
+	case FORMAT_SARIF:
+		return RenderSARIF(w, findings, metadata)
+	case FORMAT_MARKDOWN:
+		return RenderMarkdown(w, findings, metadata)
+	default:
+		return fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// ToFindings converts review comments to findings, sorted by file and line so output is stable between runs
+func ToFindings(comments []model.ReviewCommentRequest) []Finding {
+	findings := make([]Finding, 0, len(comments))
+
+	for _, comment := range comments {
+		findings = append(findings, Finding{
+			ID:       fingerprint(comment),
+			Path:     comment.Path,
+			Line:     comment.Line,
+			Severity: utils.ParseSeverity(comment.Body),
+			Body:     strings.TrimSpace(comment.Body),
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings
+}
+
+// RenderText writes the findings grouped under their location, in a format editors can jump to (path:line)
+func RenderText(w io.Writer, findings []Finding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No review comments, looks good.")
+		return err
+	}
+
+	var builder strings.Builder
+	for _, finding := range findings {
+		builder.WriteString(fmt.Sprintf("%s:%d\n", finding.Path, finding.Line))
+		for _, line := range strings.Split(finding.Body, "\n") {
+			builder.WriteString(fmt.Sprintf("    %s\n", line))
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString(fmt.Sprintf("%d review comment(s)\n", len(findings)))
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// countBySeverity counts the findings per severity, findings without one are counted as UNSPECIFIED
+func countBySeverity(findings []Finding) map[string]int {
+	counts := map[string]int{}
+	for _, finding := range findings {
+		counts[severityOrUnspecified(finding.Severity)]++
+	}
+	return counts
+}
+
+func severityOrUnspecified(severity string) string {
+	if severity == "" {
+		return "UNSPECIFIED"
+	}
+	return severity
+}
+
+// fingerprint identifies a comment by its location and content, so the same finding keeps the same ID across runs
+func fingerprint(comment model.ReviewCommentRequest) string {
+	hash := sha256.Sum256([]byte(comment.Path + ":" + strconv.Itoa(comment.Line) + ":" + strings.TrimSpace(comment.Body)))
+	return hex.EncodeToString(hash[:8])
+}
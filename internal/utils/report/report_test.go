@@ -0,0 +1,225 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/model"
+)
+
+var testMetadata = Metadata{Repository: "octo/api", PullNumber: 7, CommitID: "abc123", ToolVersion: "1.2.0"}
+
+// testComments cover every severity, in an order ToFindings has to sort
+var testComments = []model.ReviewCommentRequest{
+	{Path: "worker/run.go", Line: 12, Body: "NIT: Rename this variable."},
+	{Path: "api/handler.go", Line: 40, Body: "**BLOCKING**: The query is built from user input.\n\nUse a parameterized query."},
+	{Path: "api/handler.go", Line: 8, Body: "IMPORTANT: The error is ignored."},
+	{Path: "worker/run.go", Line: 3, Body: "QUESTION: Is this retried?"},
+	{Path: "go.mod", Body: "The new dependency isn't used.", SubjectType: "file"},
+}
+
+func render(t *testing.T, format string, comments []model.ReviewCommentRequest, metadata Metadata) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := Render(&out, format, comments, metadata); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestToFindings(t *testing.T) {
+	findings := ToFindings(testComments)
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, finding.Path+":"+finding.Severity)
+	}
+	expected := []string{"api/handler.go:IMPORTANT", "api/handler.go:BLOCKING", "go.mod:", "worker/run.go:QUESTION", "worker/run.go:NIT"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected findings sorted by file and line %v, got %v", expected, got)
+	}
+
+	// IDs are stable between runs and differ between findings
+	again := ToFindings(testComments)
+	ids := map[string]bool{}
+	for i, finding := range findings {
+		if finding.ID != again[i].ID {
+			t.Errorf("expected the ID of %s:%d to be stable", finding.Path, finding.Line)
+		}
+		ids[finding.ID] = true
+	}
+	if len(ids) != len(findings) {
+		t.Errorf("expected %d distinct IDs, got %d", len(findings), len(ids))
+	}
+}
+
+func TestRenderSARIF(t *testing.T) {
+	out := render(t, FORMAT_SARIF, testComments, testMetadata)
+
+	var log sarifLog
+	if err := json.Unmarshal(out, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || log.Schema != SARIF_SCHEMA || len(log.Runs) != 1 {
+		t.Fatalf("expected a single run of a SARIF 2.1.0 log, got version %q with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+
+	var ruleIDs []string
+	for _, rule := range run.Tool.Driver.Rules {
+		ruleIDs = append(ruleIDs, rule.ID+"="+rule.DefaultConfiguration.Level)
+	}
+	expectedRules := []string{"ai-review/blocking=error", "ai-review/important=warning", "ai-review/nit=note", "ai-review/question=note", "ai-review/unspecified=warning"}
+	if !reflect.DeepEqual(ruleIDs, expectedRules) {
+		t.Errorf("expected rules %v, got %v", expectedRules, ruleIDs)
+	}
+
+	tests := []struct {
+		path  string
+		line  int
+		rule  string
+		level string
+	}{
+		{path: "api/handler.go", line: 8, rule: "ai-review/important", level: "warning"},
+		{path: "api/handler.go", line: 40, rule: "ai-review/blocking", level: "error"},
+		// File level comments have no line, SARIF lines start at 1
+		{path: "go.mod", line: 1, rule: "ai-review/unspecified", level: "warning"},
+		{path: "worker/run.go", line: 3, rule: "ai-review/question", level: "note"},
+		{path: "worker/run.go", line: 12, rule: "ai-review/nit", level: "note"},
+	}
+	if len(run.Results) != len(tests) {
+		t.Fatalf("expected %d results, got %d", len(tests), len(run.Results))
+	}
+	for i, test := range tests {
+		result := run.Results[i]
+		location := result.Locations[0].PhysicalLocation
+		if location.ArtifactLocation.URI != test.path || location.Region.StartLine != test.line {
+			t.Errorf("expected result %d at %s:%d, got %s:%d", i, test.path, test.line, location.ArtifactLocation.URI, location.Region.StartLine)
+		}
+		if result.RuleID != test.rule || result.Level != test.level {
+			t.Errorf("expected result %d to be %s at level %s, got %s at level %s", i, test.rule, test.level, result.RuleID, result.Level)
+		}
+		if run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("expected the rule index of result %d to point at %s", i, result.RuleID)
+		}
+		if result.PartialFingerprints["aiReviewFinding/v1"] == "" {
+			t.Errorf("expected result %d to have a fingerprint", i)
+		}
+	}
+	if !strings.Contains(run.Results[1].Message.Text, "Use a parameterized query.") {
+		t.Errorf("expected the whole comment as the message, got %q", run.Results[1].Message.Text)
+	}
+
+	if len(run.VersionControlProvenance) != 1 || run.VersionControlProvenance[0].RepositoryURI != "https://github.com/octo/api" || run.VersionControlProvenance[0].RevisionID != "abc123" {
+		t.Errorf("expected the repository and commit in the provenance, got %+v", run.VersionControlProvenance)
+	}
+	if run.Tool.Driver.Version != "1.2.0" {
+		t.Errorf("expected the tool version, got %q", run.Tool.Driver.Version)
+	}
+}
+
+func TestRenderSARIFWithoutFindings(t *testing.T) {
+	out := render(t, FORMAT_SARIF, nil, Metadata{})
+
+	var log map[string]any
+	if err := json.Unmarshal(out, &log); err != nil {
+		t.Fatal(err)
+	}
+	run := log["runs"].([]any)[0].(map[string]any)
+
+	// Null results mean the tool didn't run, an empty list means nothing was found
+	if results, ok := run["results"].([]any); !ok || len(results) != 0 {
+		t.Errorf("expected an empty list of results, got %#v", run["results"])
+	}
+	if rules := run["tool"].(map[string]any)["driver"].(map[string]any)["rules"].([]any); len(rules) != len(sarifRules) {
+		t.Errorf("expected every rule to be listed, got %d", len(rules))
+	}
+	for _, key := range []string{"versionControlProvenance", "properties"} {
+		if _, ok := run[key]; ok {
+			t.Errorf("expected no %s without metadata", key)
+		}
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		comments   []model.ReviewCommentRequest
+		total      int
+		bySeverity map[string]int
+	}{
+		{
+			name:       "findings",
+			comments:   testComments,
+			total:      5,
+			bySeverity: map[string]int{"BLOCKING": 1, "IMPORTANT": 1, "NIT": 1, "QUESTION": 1, "UNSPECIFIED": 1},
+		},
+		{name: "no findings", total: 0, bySeverity: map[string]int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := render(t, FORMAT_JSON, test.comments, testMetadata)
+
+			var report jsonReport
+			if err := json.Unmarshal(out, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.SchemaVersion != JSON_SCHEMA_VERSION || report.Tool.Name != TOOL_NAME || report.Tool.Version != "1.2.0" {
+				t.Errorf("expected the schema and tool versions, got %+v", report)
+			}
+			if report.Repository != "octo/api" || report.PullNumber != 7 || report.CommitID != "abc123" {
+				t.Errorf("expected the metadata, got %+v", report)
+			}
+			if report.Summary.Total != test.total || !reflect.DeepEqual(report.Summary.BySeverity, test.bySeverity) {
+				t.Errorf("expected %d findings by severity %v, got %+v", test.total, test.bySeverity, report.Summary)
+			}
+			if !reflect.DeepEqual(report.Findings, ToFindings(test.comments)) {
+				t.Errorf("expected the sorted findings, got %+v", report.Findings)
+			}
+			if test.total == 0 && !bytes.Contains(out, []byte(`"findings": []`)) {
+				t.Errorf("expected an empty list of findings, got:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	comments := []model.ReviewCommentRequest{
+		{Path: "worker/run.go", Line: 12, Body: "NIT: Rename this variable."},
+		{Path: "api/handler.go", Line: 40, Body: "BLOCKING: The query is built from user input."},
+		{Path: "api/handler.go", Line: 8, Body: "The error is ignored."},
+	}
+
+	expected := "# AI Code Review\n\n" +
+		"- **Repository:** octo/api\n" +
+		"- **Pull request:** #7\n" +
+		"- **Commit:** `abc123`\n\n" +
+		"| Severity | Count |\n|---|---|\n" +
+		"| BLOCKING | 1 |\n" +
+		"| NIT | 1 |\n" +
+		"| UNSPECIFIED | 1 |\n" +
+		"| **Total** | **3** |\n" +
+		"\n## `api/handler.go`\n" +
+		"\n### Line 8 · UNSPECIFIED\n\nThe error is ignored.\n" +
+		"\n### Line 40 · BLOCKING\n\nBLOCKING: The query is built from user input.\n" +
+		"\n## `worker/run.go`\n" +
+		"\n### Line 12 · NIT\n\nNIT: Rename this variable.\n"
+	if got := string(render(t, FORMAT_MARKDOWN, comments, testMetadata)); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := string(render(t, FORMAT_MARKDOWN, nil, Metadata{})); got != "# AI Code Review\n\nNo review comments, looks good.\n" {
+		t.Errorf("expected an empty report, got:\n%s", got)
+	}
+}
+
+func TestRenderUnsupportedFormat(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, "xml", testComments, Metadata{}); err == nil {
+		t.Error("expected an unsupported format to be rejected")
+	}
+}
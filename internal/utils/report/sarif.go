@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RakibulBh/AI-pr-reviewer/internal/utils"
+)
+
+const SARIF_VERSION = "2.1.0"
+const SARIF_SCHEMA = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool                     sarifTool                    `json:"tool"`
+	Results                  []sarifResult                `json:"results"`
+	VersionControlProvenance []sarifVersionControlDetails `json:"versionControlProvenance,omitempty"`
+	Properties               map[string]any               `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifVersionControlDetails struct {
+	RepositoryURI string `json:"repositoryUri"`
+	RevisionID    string `json:"revisionId,omitempty"`
+}
+
+// sarifRules has one rule per severity, code scanning groups and filters alerts by rule
+var sarifRules = []struct {
+	severity    string
+	level       string
+	description string
+}{
+	{utils.SEVERITY_BLOCKING, "error", "Must be fixed before merge"},
+	{utils.SEVERITY_IMPORTANT, "warning", "Should be fixed, significantly impacts code quality"},
+	{utils.SEVERITY_NIT, "note", "Minor suggestion, nice to have"},
+	{utils.SEVERITY_QUESTION, "note", "Question seeking clarification or discussion"},
+	{"", "warning", "Review comment without a severity"},
+}
+
+// RenderSARIF writes the findings as a SARIF 2.1.0 log, which can be uploaded to github code scanning
+func RenderSARIF(w io.Writer, findings []Finding, metadata Metadata) error {
+	rules := make([]sarifRule, 0, len(sarifRules))
+	ruleIndexes := map[string]int{}
+	for i, rule := range sarifRules {
+		id := sarifRuleID(rule.severity)
+		ruleIndexes[rule.severity] = i
+		rules = append(rules, sarifRule{
+			ID:                   id,
+			Name:                 strings.ToLower(severityOrUnspecified(rule.severity)),
+			ShortDescription:     sarifMessage{Text: rule.description},
+			DefaultConfiguration: sarifConfiguration{Level: rule.level},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, finding := range findings {
+		ruleIndex, ok := ruleIndexes[finding.Severity]
+		if !ok {
+			ruleIndex = ruleIndexes[""]
+		}
+
+		// SARIF lines start at 1, file level comments have no line
+		line := max(finding.Line, 1)
+
+		results = append(results, sarifResult{
+			RuleID:    rules[ruleIndex].ID,
+			RuleIndex: ruleIndex,
+			Level:     sarifRules[ruleIndex].level,
+			Message:   sarifMessage{Text: finding.Body},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: finding.Path, URIBaseID: "%SRCROOT%"},
+					Region:           sarifRegion{StartLine: line},
+				},
+			}},
+			PartialFingerprints: map[string]string{
+				"aiReviewFinding/v1": finding.ID,
+			},
+		})
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           TOOL_NAME,
+			Version:        metadata.ToolVersion,
+			InformationURI: TOOL_INFORMATION_URI,
+			Rules:          rules,
+		}},
+		Results: results,
+	}
+
+	if metadata.Repository != "" {
+		run.VersionControlProvenance = []sarifVersionControlDetails{{
+			RepositoryURI: "https://github.com/" + metadata.Repository,
+			RevisionID:    metadata.CommitID,
+		}}
+	}
+	if metadata.PullNumber != 0 {
+		run.Properties = map[string]any{"pullNumber": metadata.PullNumber}
+	}
+
+	log := sarifLog{
+		Schema:  SARIF_SCHEMA,
+		Version: SARIF_VERSION,
+		Runs:    []sarifRun{run},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(log); err != nil {
+		return fmt.Errorf("failed to encode SARIF report: %w", err)
+	}
+
+	return nil
+}
+
+func sarifRuleID(severity string) string {
+	return "ai-review/" + strings.ToLower(severityOrUnspecified(severity))
+}
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MAIN_RULE_FILE is sent first, before the other rule files of the directory
+const MAIN_RULE_FILE = "main.md"
+
+// Rule files start with a front matter block between two --- lines, which scopes the rules of the file
+const RULE_FRONT_MATTER_DELIMITER = "---"
+
+// JSON rule files can't start with front matter and stay valid JSON, they are scoped by this key of their top level object
+const RULE_JSON_SCOPE_KEY = "_scope"
+
+// RuleScope is the front matter of a rule file, or its RULE_JSON_SCOPE_KEY for JSON. Rules apply to every file when Paths and Languages are empty.
+type RuleScope struct {
+	// Globs matching the paths the rules apply to, ** matches any number of directories
+	Paths []string `yaml:"paths"`
+	// Names of the language profiles the rules apply to, like Go or TypeScript
+	Languages []string `yaml:"languages"`
+	// Severity of comments raised for breaking the rules, unless a rule says otherwise
+	Severity string `yaml:"severity"`
+}
+
+// RepositoryRule is a rule file converted to plain text, with its scope
+type RepositoryRule struct {
+	// Path of the file relative to the rules directory, with forward slashes
+	Name    string
+	Scope   RuleScope
+	Content string
+
+	paths []*regexp.Regexp
+}
+
+// RepositoryRules are the parsed rule files of a directory, main.md first and the others by path
+type RepositoryRules struct {
+	Rules []RepositoryRule
+}
+
+// Parsed rules by directory, rule files are read once per process
+var repositoryRulesCache sync.Map
+
+// RepositoryRulesFor renders the rules of RepositoryRulesDir which apply to the files of a review chunk
+// written in the language, which is empty when it is unknown
+func RepositoryRulesFor(filenames []string, language string) (string, error) {
+	rules, err := LoadRepositoryRules(RepositoryRulesDir)
+	if err != nil {
+		return "", err
+	}
+
+	return RenderRepositoryRules(rules.For(filenames, language)), nil
+}
+
+// LoadRepositoryRules parses every rule file in dir and its subdirectories, once per directory.
+// A missing directory has no rules, files of unsupported types are skipped.
+func LoadRepositoryRules(dir string) (*RepositoryRules, error) {
+	if cached, ok := repositoryRulesCache.Load(dir); ok {
+		return cached.(*RepositoryRules), nil
+	}
+
+	rules := &RepositoryRules{}
+	err := filepath.WalkDir(dir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && fullPath != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, fullPath)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if !slices.Contains(RULE_FILE_EXTENSIONS, strings.ToLower(path.Ext(name))) {
+			slog.Warn("skipping rule file of an unsupported type", "file", name, "dir", dir)
+			return nil
+		}
+
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", fullPath, err)
+		}
+		rule, err := parseRepositoryRule(name, content)
+		if err != nil {
+			return err
+		}
+		rules.Rules = append(rules.Rules, rule)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		slog.Warn("repository rules directory not found, reviewing without repository rules", "dir", dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// WalkDir visits files in lexical order, main.md goes first as it describes the repository
+	slices.SortStableFunc(rules.Rules, func(a, b RepositoryRule) int {
+		switch {
+		case a.Name == b.Name:
+			return 0
+		case a.Name == MAIN_RULE_FILE:
+			return -1
+		case b.Name == MAIN_RULE_FILE:
+			return 1
+		}
+		return 0
+	})
+
+	cached, _ := repositoryRulesCache.LoadOrStore(dir, rules)
+	return cached.(*RepositoryRules), nil
+}
+
+// For returns the rules applying to any of the files, in the language
+func (r *RepositoryRules) For(filenames []string, language string) []RepositoryRule {
+	var applicable []RepositoryRule
+	for _, rule := range r.Rules {
+		if rule.Applies(filenames, language) {
+			applicable = append(applicable, rule)
+		}
+	}
+	return applicable
+}
+
+// Applies reports whether the rule is in scope for the language and at least one of the files
+func (r RepositoryRule) Applies(filenames []string, language string) bool {
+	if len(r.Scope.Languages) > 0 && !slices.ContainsFunc(r.Scope.Languages, func(scoped string) bool {
+		return strings.EqualFold(scoped, language)
+	}) {
+		return false
+	}
+	if len(r.paths) == 0 {
+		return true
+	}
+
+	for _, filename := range filenames {
+		for _, pattern := range r.paths {
+			if pattern.MatchString(filename) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RenderRepositoryRules writes the rules as the requirements of the review prompt, each under the name of its file
+func RenderRepositoryRules(rules []RepositoryRule) string {
+	sections := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		header := "Rules from " + rule.Name
+		if rule.Scope.Severity != "" {
+			header += ", raise issues breaking them as " + rule.Scope.Severity + " unless a rule says otherwise"
+		}
+		sections = append(sections, header+":\n"+rule.Content)
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// parseRepositoryRule splits the scope from a rule file and converts the rest to plain text
+func parseRepositoryRule(name string, content []byte) (RepositoryRule, error) {
+	rule := RepositoryRule{Name: name}
+
+	var scope, body []byte
+	if strings.ToLower(path.Ext(name)) == ".json" {
+		scope, body = splitJSONScope(content)
+	} else {
+		scope, body, _ = splitFrontMatter(content)
+	}
+	if len(scope) > 0 {
+		// JSON is valid YAML, so JSON scopes are decoded the same way
+		decoder := yaml.NewDecoder(bytes.NewReader(scope))
+		decoder.KnownFields(true)
+		// An empty front matter block is an empty scope
+		if err := decoder.Decode(&rule.Scope); err != nil && !errors.Is(err, io.EOF) {
+			return RepositoryRule{}, fmt.Errorf("invalid scope in %s: %w", name, err)
+		}
+	}
+
+	if rule.Scope.Severity != "" {
+		rule.Scope.Severity = strings.ToUpper(strings.TrimSpace(rule.Scope.Severity))
+		if !slices.Contains([]string{SEVERITY_BLOCKING, SEVERITY_IMPORTANT, SEVERITY_NIT, SEVERITY_QUESTION}, rule.Scope.Severity) {
+			return RepositoryRule{}, fmt.Errorf("invalid scope in %s: unknown severity %q", name, rule.Scope.Severity)
+		}
+	}
+	for _, glob := range rule.Scope.Paths {
+		pattern, err := compilePathGlob(glob)
+		if err != nil {
+			return RepositoryRule{}, fmt.Errorf("invalid scope in %s: %w", name, err)
+		}
+		rule.paths = append(rule.paths, pattern)
+	}
+
+	text, err := ruleFileToText(name, body)
+	if err != nil {
+		return RepositoryRule{}, fmt.Errorf("failed to convert %s: %w", name, err)
+	}
+	rule.Content = strings.TrimSpace(text)
+	return rule, nil
+}
+
+// splitFrontMatter returns the block between a --- first line and the next --- line, and the content after it.
+// found is false when the content doesn't start with front matter.
+func splitFrontMatter(content []byte) (frontMatter []byte, body []byte, found bool) {
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	first, rest, ok := bytes.Cut(content, []byte("\n"))
+	if !ok || string(bytes.TrimSpace(first)) != RULE_FRONT_MATTER_DELIMITER {
+		return nil, content, false
+	}
+
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if string(bytes.TrimSpace(line)) == RULE_FRONT_MATTER_DELIMITER {
+			return rest[:offset], rest[min(offset+len(line)+1, len(rest)):], true
+		}
+		offset += len(line) + 1
+	}
+	// A YAML file can start with --- without any front matter
+	return nil, content, false
+}
+
+// splitJSONScope returns the RULE_JSON_SCOPE_KEY value of a JSON rule file, and the file without the key.
+// scope is nil when the file isn't an object with the key, invalid JSON fails when the file is converted.
+func splitJSONScope(content []byte) (scope []byte, body []byte) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, content
+	}
+
+	for first := true; decoder.More(); first = false {
+		// Keys after the first start at the comma ending the previous value
+		start := decoder.InputOffset()
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, content
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, content
+		}
+		if key != RULE_JSON_SCOPE_KEY {
+			continue
+		}
+
+		end := decoder.InputOffset()
+		if first && decoder.More() {
+			// The first key has no comma before it, the comma after it goes instead
+			end += int64(bytes.IndexByte(content[end:], ',')) + 1
+		}
+		return value, slices.Concat(content[:start], content[end:])
+	}
+	return nil, content
+}
+
+// compilePathGlob converts a glob to a regular expression matching whole paths. * and ? don't match /,
+// ** matches any number of directories, and a glob without / matches the file name in any directory.
+func compilePathGlob(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(strings.TrimSpace(glob), "/")
+	if glob == "" {
+		return nil, fmt.Errorf("empty path glob")
+	}
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			pattern.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			pattern.WriteString("[^/]*")
+		case glob[i] == '?':
+			pattern.WriteString("[^/]")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern.WriteString("$")
+
+	compiled, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path glob %q: %w", glob, err)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRuleFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func ruleNames(rules []RepositoryRule) []string {
+	names := []string{}
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+func TestLoadRepositoryRules(t *testing.T) {
+	dir := writeRuleFiles(t, map[string]string{
+		"main.md":        "Use the logger.",
+		"about.md":       "---\n---\nA billing service.",
+		"go.md":          "---\nlanguages: [go]\nseverity: important\n---\nWrap errors with %w.",
+		"api.yml":        "---\npaths: [\"api/**/*.proto\"]\n---\nfields:\n  reserved: never reuse numbers\n  naming: snake_case\n",
+		"ui/design.json": `{"_scope": {"paths": ["web/components/**", "*.css"]}, "colors": {"primary": "#0055ff"}, "spacing": 8}`,
+		"logo.png":       "not a rule",
+		".drafts/wip.md": "Not ready.",
+	})
+
+	rules, err := LoadRepositoryRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ruleNames(rules.Rules), ","); got != "main.md,about.md,api.yml,go.md,ui/design.json" {
+		t.Fatalf("expected main.md first and the others by path, got %s", got)
+	}
+
+	tests := []struct {
+		name      string
+		filenames []string
+		language  string
+		expected  string
+	}{
+		{name: "unscoped rules only", filenames: []string{"README.md"}, expected: "main.md,about.md"},
+		{name: "language", filenames: []string{"cmd/main.go"}, language: "Go", expected: "main.md,about.md,go.md"},
+		{name: "path glob", filenames: []string{"cmd/main.go", "api/v1/users.proto"}, language: "Go", expected: "main.md,about.md,api.yml,go.md"},
+		{name: "file name glob in any directory", filenames: []string{"web/styles/theme.css"}, expected: "main.md,about.md,ui/design.json"},
+		{name: "directory glob", filenames: []string{"web/components/button/Button.tsx"}, language: "TypeScript", expected: "main.md,about.md,ui/design.json"},
+		{name: "glob doesn't match a prefix", filenames: []string{"internal/api/users.proto"}, expected: "main.md,about.md"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := strings.Join(ruleNames(rules.For(test.filenames, test.language)), ","); got != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+
+	rendered := RenderRepositoryRules(rules.For([]string{"api/users.proto", "main.go"}, "Go"))
+	for _, expected := range []string{
+		"Rules from main.md:\nUse the logger.",
+		"Rules from go.md, raise issues breaking them as IMPORTANT unless a rule says otherwise:\nWrap errors with %w.",
+		// YAML keeps the order of the file
+		"## fields\n\n- **reserved**: never reuse numbers\n- **naming**: snake_case",
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("rendered rules are missing %q:\n%s", expected, rendered)
+		}
+	}
+	if strings.Contains(rendered, "paths:") {
+		t.Errorf("expected the front matter to be removed:\n%s", rendered)
+	}
+	design := RenderRepositoryRules(rules.For([]string{"theme.css"}, ""))
+	if !strings.Contains(design, "- **primary**: #0055ff") || strings.Contains(design, "_scope") || strings.Contains(design, "web/components") {
+		t.Errorf("expected the scope of the JSON rules to be removed:\n%s", design)
+	}
+
+	// Rules are read once, changes on disk are only picked up by a new process
+	if err := os.WriteFile(filepath.Join(dir, "main.md"), []byte("Changed."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadRepositoryRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != rules || again.Rules[0].Content != "Use the logger." {
+		t.Error("expected the parsed rules to be cached")
+	}
+}
+
+func TestLoadRepositoryRulesWithoutDirectory(t *testing.T) {
+	rules, err := LoadRepositoryRules(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Rules) != 0 {
+		t.Errorf("expected no rules, got %v", ruleNames(rules.Rules))
+	}
+}
+
+func TestInvalidRuleFrontMatter(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":      "---\nlanguage: Go\n---\nRules",
+		"unknown severity": "---\nseverity: critical\n---\nRules",
+		"invalid yaml":     "---\npaths: [api\n---\nRules",
+	}
+	jsonTests := map[string]string{
+		"unknown json scope key": `{"_scope": {"language": "Go"}, "rules": []}`,
+		"json front matter":      "---\nseverity: nit\n---\n{\"rules\": []}",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadRepositoryRules(writeRuleFiles(t, map[string]string{"rules.md": content})); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	for name, content := range jsonTests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadRepositoryRules(writeRuleFiles(t, map[string]string{"rules.json": content})); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSplitJSONScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		scope   string
+		body    string
+	}{
+		{name: "first key", content: `{"_scope": {"severity": "nit"}, "spacing": 8}`, scope: `{"severity": "nit"}`, body: `{ "spacing": 8}`},
+		{name: "later key", content: "{\n  \"spacing\": 8,\n  \"_scope\": {\"languages\": [\"Go\"]}\n}", scope: `{"languages": ["Go"]}`, body: "{\n  \"spacing\": 8\n}"},
+		{name: "only key", content: `{"_scope": {}}`, scope: `{}`, body: `{}`},
+		{name: "nested key", content: `{"theme": {"_scope": {}}}`, body: `{"theme": {"_scope": {}}}`},
+		{name: "array", content: `[{"_scope": {}}]`, body: `[{"_scope": {}}]`},
+		{name: "invalid json", content: `{"_scope": `, body: `{"_scope": `},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope, body := splitJSONScope([]byte(test.content))
+			if string(scope) != test.scope || string(body) != test.body {
+				t.Errorf("expected scope %q and body %q, got %q and %q", test.scope, test.body, scope, body)
+			}
+		})
+	}
+}
+
+func TestReadRepositoryRuleFileYAML(t *testing.T) {
+	dir := writeRuleFiles(t, map[string]string{"style.yaml": "naming:\n  - camelCase for variables\n  - PascalCase for types\nmax_line_length: 120\n"})
+	previous := RepositoryRulesDir
+	RepositoryRulesDir = dir
+	t.Cleanup(func() {
+		RepositoryRulesDir = previous
+	})
+
+	text, err := ReadRepositoryRuleFile("style.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "## naming\n\n- camelCase for variables\n- PascalCase for types\n\n## max_line_length\n\n120"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
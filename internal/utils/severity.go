@@ -0,0 +1,20 @@
+package utils
+
+import "strings"
+
+const (
+	SEVERITY_BLOCKING  = "BLOCKING"
+	SEVERITY_IMPORTANT = "IMPORTANT"
+	SEVERITY_NIT       = "NIT"
+	SEVERITY_QUESTION  = "QUESTION"
+)
+
+// ParseSeverity returns the severity indicator the LLM put in front of a review comment,
+// or an empty string when the comment doesn't start with one
+func ParseSeverity(body string) string {
+	matches := severityPrefixPattern.FindStringSubmatch(strings.TrimSpace(body))
+	if matches == nil {
+		return ""
+	}
+	return strings.ToUpper(matches[1])
+}
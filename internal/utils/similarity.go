@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// severityPrefixPattern matches the severity labels the LLM is asked to put in front of comments
+var severityPrefixPattern = regexp.MustCompile(`(?i)^\W*(blocking|important|nit|question)\W*`)
+
+// codeBlockPattern matches fenced code blocks, which are suggestions rather than the issue itself
+var codeBlockPattern = regexp.MustCompile("(?s)```.*?```")
+
+// stopWords are dropped before comparing comments since they carry no meaning about the issue
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "but": true, "is": true, "are": true,
+	"was": true, "be": true, "to": true, "of": true, "in": true, "on": true, "for": true, "with": true,
+	"this": true, "that": true, "it": true, "its": true, "as": true, "at": true, "by": true, "if": true,
+	"you": true, "your": true, "we": true, "i": true, "can": true, "should": true, "would": true,
+	"could": true, "here": true, "there": true, "not": true, "do": true, "does": true, "so": true,
+}
+
+// NormalizeText lowercases text, removes code blocks, severity labels and punctuation,
+// and returns the remaining meaningful words
+func NormalizeText(text string) []string {
+	text = codeBlockPattern.ReplaceAllString(text, " ")
+	text = severityPrefixPattern.ReplaceAllString(text, "")
+	text = strings.ToLower(text)
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	var normalized []string
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		normalized = append(normalized, word)
+	}
+
+	return normalized
+}
+
+// TextSimilarity returns a score between 0 and 1 describing how similar two comments are.
+// It uses the Sørensen–Dice coefficient over normalized words, and the overlap coefficient
+// when both texts are long enough, so a short human remark can still match a longer bot comment.
+func TextSimilarity(a, b string) float64 {
+	wordsA := toSet(NormalizeText(a))
+	wordsB := toSet(NormalizeText(b))
+
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+
+	dice := 2 * float64(shared) / float64(len(wordsA)+len(wordsB))
+
+	smallest := min(len(wordsA), len(wordsB))
+	if smallest < 4 {
+		return dice
+	}
+
+	// Discount the overlap coefficient slightly, it is more generous than dice
+	overlap := 0.9 * float64(shared) / float64(smallest)
+
+	return max(dice, overlap)
+}
+
+func toSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
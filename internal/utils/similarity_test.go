@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	got := NormalizeText("**NIT**: The `ctx` isn't passed to db.Query!\n```go\nrows, err := db.QueryContext(ctx)\n```")
+	expected := []string{"ctx", "isn", "t", "passed", "db", "query"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	const bugComment = "IMPORTANT: The error returned by db.Close is silently ignored, which hides failures when flushing writes."
+
+	tests := []struct {
+		name string
+		a, b string
+		// Range the similarity must fall in
+		min, max float64
+	}{
+		{
+			name: "same issue reworded",
+			a:    "**IMPORTANT**: The error returned by `db.Close` is ignored, handle it or log it.",
+			b:    "IMPORTANT: error returned by db.Close is ignored here, log it or handle it.",
+			min:  0.9, max: 1,
+		},
+		{
+			name: "severity labels and code blocks don't count",
+			a:    "NIT: Missing doc comment on exported function.\n```go\n// Foo does things\n```",
+			b:    "Missing doc comment on exported function",
+			min:  1, max: 1,
+		},
+		{
+			name: "short human remark on a long bot comment",
+			a:    "BLOCKING: This SQL query is built with string concatenation, which allows SQL injection. Use a parameterized query.",
+			b:    "sql injection here, use a parameterized query",
+			min:  0.5, max: 1,
+		},
+		{
+			name: "terse human remark",
+			a:    bugComment,
+			b:    "close error ignored?",
+			min:  0.35, max: 0.49,
+		},
+		{
+			name: "different issues",
+			a:    "IMPORTANT: The error returned by db.Close is ignored.",
+			b:    "NIT: Consider renaming this variable to something clearer.",
+			min:  0, max: 0,
+		},
+		{name: "empty comment", a: "", b: bugComment, min: 0, max: 0},
+		{name: "only stop words", a: "the and of", b: "the and of", min: 0, max: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			similarity := TextSimilarity(test.a, test.b)
+			if similarity < test.min || similarity > test.max {
+				t.Errorf("expected a similarity between %.2f and %.2f, got %.3f", test.min, test.max, similarity)
+			}
+			if reversed := TextSimilarity(test.b, test.a); reversed != similarity {
+				t.Errorf("expected the similarity to be symmetric, got %.3f and %.3f", similarity, reversed)
+			}
+		})
+	}
+}
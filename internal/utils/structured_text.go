@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of structured values, objects keep their keys in the order of the file
+const (
+	STRUCTURED_SCALAR = iota
+	STRUCTURED_OBJECT
+	STRUCTURED_ARRAY
+)
+
+// structuredValue is a JSON or YAML value with the order of its keys, so it renders the same way every time
+type structuredValue struct {
+	kind int
+	// Text of a scalar, numbers as written in the file
+	scalar string
+	// Keys of an object, values has the value of every key, or the items of an array
+	keys   []string
+	values []*structuredValue
+}
+
+// formatJSONToText converts JSON content to Markdown, keeping the order of keys and numbers as written
+func formatJSONToText(jsonContent []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.UseNumber()
+
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to parse JSON: unexpected content after the top level value")
+	}
+
+	return renderStructuredMarkdown(value), nil
+}
+
+// formatYAMLToText converts YAML content to Markdown, the same way as JSON
+func formatYAMLToText(yamlContent []byte) (string, error) {
+	var document yaml.Node
+
+	// Parse YAML
+	if err := yaml.Unmarshal(yamlContent, &document); err != nil {
+		return "", fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return "", nil
+	}
+
+	return renderStructuredMarkdown(yamlValue(document.Content[0])), nil
+}
+
+// decodeJSONValue reads the next value from the token stream of the decoder
+func decodeJSONValue(decoder *json.Decoder) (*structuredValue, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		value := &structuredValue{kind: STRUCTURED_ARRAY}
+		if token == '{' {
+			value.kind = STRUCTURED_OBJECT
+		}
+		for decoder.More() {
+			if value.kind == STRUCTURED_OBJECT {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value.keys = append(value.keys, key.(string))
+			}
+			child, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			value.values = append(value.values, child)
+		}
+		// The closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case json.Number:
+		return &structuredValue{scalar: token.String()}, nil
+	case string:
+		return &structuredValue{scalar: token}, nil
+	case bool:
+		return &structuredValue{scalar: strconv.FormatBool(token)}, nil
+	case nil:
+		return &structuredValue{scalar: "null"}, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON token %v", token)
+	}
+}
+
+// yamlValue converts a YAML node, aliases are replaced by the value they point to
+func yamlValue(node *yaml.Node) *structuredValue {
+	switch node.Kind {
+	case yaml.MappingNode:
+		value := &structuredValue{kind: STRUCTURED_OBJECT}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value.keys = append(value.keys, node.Content[i].Value)
+			value.values = append(value.values, yamlValue(node.Content[i+1]))
+		}
+		return value
+	case yaml.SequenceNode:
+		value := &structuredValue{kind: STRUCTURED_ARRAY}
+		for _, item := range node.Content {
+			value.values = append(value.values, yamlValue(item))
+		}
+		return value
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return yamlValue(node.Content[0])
+		}
+		return &structuredValue{}
+	default:
+		return &structuredValue{scalar: node.Value}
+	}
+}
+
+// renderStructuredMarkdown renders the keys of a top level object as sections, and everything below them as lists
+func renderStructuredMarkdown(value *structuredValue) string {
+	var builder strings.Builder
+
+	if value.kind != STRUCTURED_OBJECT {
+		writeMarkdownBlock(&builder, value)
+		return strings.TrimSpace(builder.String())
+	}
+
+	for i, key := range value.keys {
+		fmt.Fprintf(&builder, "## %s\n\n", key)
+		writeMarkdownBlock(&builder, value.values[i])
+		builder.WriteString("\n")
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// writeMarkdownBlock writes a value on lines of its own
+func writeMarkdownBlock(builder *strings.Builder, value *structuredValue) {
+	if value.kind == STRUCTURED_SCALAR || len(value.values) == 0 {
+		builder.WriteString(inlineStructuredValue(value, 0) + "\n")
+		return
+	}
+	writeMarkdownList(builder, value, 0)
+}
+
+// writeMarkdownList writes the keys of an object or the items of an array as a list, nested values are nested lists
+func writeMarkdownList(builder *strings.Builder, value *structuredValue, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for i, child := range value.values {
+		label := fmt.Sprintf("Item %d", i+1)
+		if value.kind == STRUCTURED_OBJECT {
+			label = "**" + value.keys[i] + "**"
+		}
+
+		switch {
+		case child.kind == STRUCTURED_SCALAR && value.kind == STRUCTURED_ARRAY:
+			fmt.Fprintf(builder, "%s- %s\n", indent, inlineStructuredValue(child, depth+1))
+		case child.kind == STRUCTURED_SCALAR || len(child.values) == 0:
+			fmt.Fprintf(builder, "%s- %s: %s\n", indent, label, inlineStructuredValue(child, depth+1))
+		default:
+			fmt.Fprintf(builder, "%s- %s:\n", indent, label)
+			writeMarkdownList(builder, child, depth+1)
+		}
+	}
+}
+
+// inlineStructuredValue is the text of a scalar or empty value, further lines of the text are indented
+// to stay in the list item at depth
+func inlineStructuredValue(value *structuredValue, depth int) string {
+	switch {
+	case value.kind == STRUCTURED_OBJECT:
+		return "{}"
+	case value.kind == STRUCTURED_ARRAY:
+		return "[]"
+	}
+
+	text := strings.TrimRight(value.scalar, "\n")
+	if depth == 0 {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", "\n"+strings.Repeat("  ", depth))
+}
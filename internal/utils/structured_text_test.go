@@ -0,0 +1,96 @@
+package utils
+
+import "testing"
+
+func TestFormatJSONToText(t *testing.T) {
+	content := `{
+		"app_context": {"type": "Mobile App", "platform": "iOS"},
+		"grid": {"columns": 3, "gutter": 1.5, "max_items": 9007199254740993, "ratio": 1e3},
+		"screens": [
+			{"name": "Home", "sections": ["hero", "courses"]},
+			{"name": "Profile", "sections": []}
+		],
+		"notes": "first line\nsecond line",
+		"dark_mode": false,
+		"deprecated": null,
+		"extras": {}
+	}`
+
+	expected := `## app_context
+
+- **type**: Mobile App
+- **platform**: iOS
+
+## grid
+
+- **columns**: 3
+- **gutter**: 1.5
+- **max_items**: 9007199254740993
+- **ratio**: 1e3
+
+## screens
+
+- Item 1:
+  - **name**: Home
+  - **sections**:
+    - hero
+    - courses
+- Item 2:
+  - **name**: Profile
+  - **sections**: []
+
+## notes
+
+first line
+second line
+
+## dark_mode
+
+false
+
+## deprecated
+
+null
+
+## extras
+
+{}`
+
+	// Rendered the same way every time, so prompts can be cached
+	for range 20 {
+		text, err := formatJSONToText([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", expected, text)
+		}
+	}
+}
+
+func TestFormatStructuredTextNesting(t *testing.T) {
+	yamlText, err := formatYAMLToText([]byte("- name: lint\n  steps:\n    - run: |\n        go vet ./...\n        go test ./...\n- 42\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonText, err := formatJSONToText([]byte(`[{"name": "lint", "steps": [{"run": "go vet ./...\ngo test ./..."}]}, 42]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "- Item 1:\n  - **name**: lint\n  - **steps**:\n    - Item 1:\n      - **run**: go vet ./...\n        go test ./...\n- 42"
+	if yamlText != expected {
+		t.Errorf("expected YAML to render as:\n%s\ngot:\n%s", expected, yamlText)
+	}
+	if jsonText != expected {
+		t.Errorf("expected JSON to render as:\n%s\ngot:\n%s", expected, jsonText)
+	}
+}
+
+func TestFormatInvalidJSON(t *testing.T) {
+	for _, content := range []string{`{"a": 1`, `{"a": 1} {"b": 2}`, `{"a" 1}`} {
+		if _, err := formatJSONToText([]byte(content)); err == nil {
+			t.Errorf("expected an error for %s", content)
+		}
+	}
+}